@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// A catalog torrent holds the segment files of a w64system catalog at its
+// root, named <prefix>000, <prefix>001 and so on. Subscribing to one
+// downloads it next to the local catalog, checks every segment, and merges
+// the items not known yet into the live catalog and its search index. The
+// torrent keeps seeding for other subscribers until the subscription ends.
+
+// defaultCatalogFetchTimeout is a variable so that tests need not wait for
+// it.
+var defaultCatalogFetchTimeout = time.Hour
+
+// CatalogFetchTimeout returns how long to wait for the catalog torrent to
+// download before giving up on the subscription.
+func CatalogFetchTimeout() time.Duration {
+	if Settings.CatalogFetchTimeoutMinutes > 0 {
+		return time.Duration(Settings.CatalogFetchTimeoutMinutes) * time.Minute
+	}
+	return defaultCatalogFetchTimeout
+}
+
+// catalogSubscription is the subscription of a server to a catalog
+// torrent.
+type catalogSubscription struct {
+	mu      sync.Mutex
+	catalog string             // the Settings.CatalogInfoHash it is for
+	cancel  context.CancelFunc // ends it, dropping its torrent
+	merged  chan struct{}      // closed once it is merged or has failed
+}
+
+// subscribeCatalog subscribes to the catalog torrent named by
+// Settings.CatalogInfoHash, if any, ending the subscription to the one
+// named before if the setting changed. It returns a channel closed once the
+// catalog is merged or the subscription has failed.
+func (s *Server) subscribeCatalog() <-chan struct{} {
+	sub := &s.subscription
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.merged != nil && sub.catalog == Settings.CatalogInfoHash {
+		return sub.merged
+	}
+	if sub.cancel != nil {
+		sub.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub.catalog, sub.cancel, sub.merged = Settings.CatalogInfoHash, cancel, make(chan struct{})
+	go s.runCatalogSubscription(ctx, sub.catalog, sub.merged)
+	return sub.merged
+}
+
+// runCatalogSubscription fetches and merges catalogInfoHash, closes merged,
+// and seeds the catalog torrent until ctx is done.
+func (s *Server) runCatalogSubscription(ctx context.Context, catalogInfoHash string, merged chan struct{}) {
+	if catalogInfoHash == "" {
+		close(merged)
+		return
+	}
+
+	spec, err := catalogTorrentSpec(catalogInfoHash)
+	if err != nil {
+		log.Printf("catalog subscription: %v", err)
+		close(merged)
+		return
+	}
+
+	dir := path.Join(s.storageDir, "subscriptions", spec.InfoHash.HexString())
+	fetchCtx, cancel := context.WithTimeout(ctx, CatalogFetchTimeout())
+	t, err := FetchCatalog(fetchCtx, s.Client, spec, dir)
+	cancel()
+	if err != nil {
+		log.Printf("catalog subscription %s: %v", spec.InfoHash.HexString(), err)
+		close(merged)
+		return
+	}
+
+	stats, err := s.MergeCatalog(dir, catalogPrefix(t.Info()))
+	if err != nil {
+		log.Printf("catalog subscription %s: %v", spec.InfoHash.HexString(), err)
+		t.Drop()
+		close(merged)
+		return
+	}
+	log.Printf("catalog subscription %s: %d items added, %d already known, %d rejected",
+		spec.InfoHash.HexString(), stats.Added, stats.Duplicates, stats.Rejected)
+
+	s.UpdateSearchIndex()
+	close(merged)
+
+	select {
+	case <-ctx.Done():
+		t.Drop()
+	case <-t.Closed():
+	}
+}
+
+// SetCatalogInfoHash saves catalogInfoHash as the catalog torrent to
+// subscribe to, and subscribes to it once the torrent client runs.
+func (s *Server) SetCatalogInfoHash(catalogInfoHash string) {
+	Settings.CatalogInfoHash = catalogInfoHash
+	SaveSettings()
+	if s.Client != nil {
+		s.subscribeCatalog()
+	}
+}
+
+// catalogTorrentSpec accepts a magnet URI or a hex encoded info hash.
+func catalogTorrentSpec(catalogInfoHash string) (*torrent.TorrentSpec, error) {
+	if strings.HasPrefix(catalogInfoHash, "magnet:") {
+		return torrent.TorrentSpecFromMagnetUri(catalogInfoHash)
+	}
+
+	var spec torrent.TorrentSpec
+	if err := spec.InfoHash.FromHexString(catalogInfoHash); err != nil {
+		return nil, fmt.Errorf("parsing catalog info hash: %v", err)
+	}
+	return &spec, nil
+}
+
+// FetchCatalog downloads the catalog torrent described by spec into dir,
+// with the torrent's files directly under dir, and returns once every piece
+// is verified. The torrent is left in the client to seed, unless ctx is
+// done first, which drops it.
+func FetchCatalog(ctx context.Context, client *torrent.Client, spec *torrent.TorrentSpec, dir string) (*torrent.Torrent, error) {
+	// The piece completion database lives in dir and needs it to exist.
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("making dir for catalog torrent: %v", err)
+	}
+
+	spec.Storage = storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir: dir,
+		FilePathMaker: func(opts storage.FilePathMakerOpts) string {
+			if len(opts.File.Path) == 0 {
+				return opts.Info.Name // single file torrent
+			}
+			return filepath.Join(opts.File.Path...)
+		},
+	})
+
+	t, _, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("adding catalog torrent: %v", err)
+	}
+
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return nil, fmt.Errorf("catalog torrent closed before getting its info")
+	case <-ctx.Done():
+		t.Drop()
+		return nil, fmt.Errorf("getting catalog torrent info: %v", ctx.Err())
+	}
+
+	if catalogPrefix(t.Info()) == "" {
+		t.Drop()
+		return nil, fmt.Errorf("catalog torrent holds no segment 000")
+	}
+
+	t.DownloadAll()
+	for t.BytesMissing() > 0 {
+		select {
+		case <-t.Closed():
+			return nil, fmt.Errorf("catalog torrent closed before completing")
+		case <-ctx.Done():
+			t.Drop()
+			return nil, fmt.Errorf("downloading catalog torrent: %v", ctx.Err())
+		case <-time.After(1 * time.Second):
+		}
+	}
+
+	return t, nil
+}
+
+// catalogPrefix returns the file name prefix of the segments of a catalog
+// torrent, "" if it has no first segment at its root.
+func catalogPrefix(info *metainfo.Info) string {
+	for _, file := range info.UpvertedFiles() {
+		name := info.Name
+		if len(file.Path) > 0 {
+			if len(file.Path) > 1 {
+				continue
+			}
+			name = file.Path[0]
+		}
+		if prefix := strings.TrimSuffix(name, "000"); prefix != name && prefix != "" {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// MergeCatalog merges the items of the catalog stored in dir into the
+// search catalog. A catalog whose segments are incomplete or corrupt is
+// rejected as a whole; within a sound catalog, items that do not decode or
+// whose magnet or signature is invalid are skipped.
+func (s *SearchManager) MergeCatalog(dir, prefix string) (catalog.ImportStats, error) {
+	src, err := chunk_storage.New(dir, prefix, chunk_storage.Options{ReadOnly: true})
+	if err != nil {
+		return catalog.ImportStats{}, fmt.Errorf("opening catalog: %v", err)
+	}
+	defer src.Close()
+
+	if recovered := src.Recovered(); len(recovered) > 0 {
+		return catalog.ImportStats{}, fmt.Errorf("corrupt catalog: %v", recovered[0])
+	}
+
+	importer, err := catalog.NewImporter(s.w64storage)
+	if err != nil {
+		return catalog.ImportStats{}, err
+	}
+	return importer.AddFrom(src)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+	"github.com/wetorrent/wetorrent/internal/search"
+)
+
+// newTestClient starts a torrent client that only reaches the peers it is
+// told about, on localhost.
+func newTestClient(t *testing.T, dataDir string) *torrent.Client {
+	t.Helper()
+
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = dataDir
+	cfg.Seed = true
+	cfg.ListenPort = 0
+	cfg.ListenHost = func(string) string { return "127.0.0.1" }
+	cfg.NoDHT = true
+	cfg.DisableTrackers = true
+	cfg.DisableIPv6 = true
+	cfg.DisableWebtorrent = true
+	cfg.NoDefaultPortForwarding = true
+
+	client, err := torrent.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// testItems returns n items with distinct names and info hashes.
+func testItems(n int) []catalog.Item {
+	items := make([]catalog.Item, n)
+	for i := range items {
+		items[i] = catalog.Item{
+			Name:        fmt.Sprintf("Subscribed.Movie.%d.1080p.x264-GRP", i),
+			Description: "from the catalog torrent",
+			Magnet:      fmt.Sprintf("magnet:?xt=urn:btih:%040x", 0x5000+i),
+		}
+	}
+	return items
+}
+
+// writeTestCatalog writes a catalog of items to dir/prefix000.
+func writeTestCatalog(t *testing.T, dir, prefix string, items []catalog.Item) {
+	t.Helper()
+
+	storage, err := chunk_storage.New(dir, prefix, chunk_storage.Options{InfoHashFunc: catalog.ChunkInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer, err := catalog.NewImporter(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if err := importer.Add(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestServer returns a server with an empty live catalog in a temporary
+// directory, fetching torrents with client.
+func newTestServer(t *testing.T, client *torrent.Client) *Server {
+	t.Helper()
+
+	s := &Server{Client: client}
+	if err := s.SearchManager.Init(t.TempDir(), "w64system"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.w64storage.Close() })
+	return s
+}
+
+// seedTestCatalog writes a catalog of items to a directory of its own and
+// seeds it from seeder, returning a magnet reaching it over localhost.
+func seedTestCatalog(t *testing.T, seeder *torrent.Client, prefix string, items []catalog.Item) metainfo.Magnet {
+	t.Helper()
+
+	catalogDir := path.Join(t.TempDir(), "catalog")
+	writeTestCatalog(t, catalogDir, prefix, items)
+
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(catalogDir); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi := metainfo.MetaInfo{InfoBytes: infoBytes}
+
+	// The seeder finds the files of the torrent, named "catalog", in the
+	// parent of catalogDir.
+	seeded, _ := seeder.AddTorrentOpt(torrent.AddTorrentOpts{
+		InfoHash: mi.HashInfoBytes(),
+		Storage:  storage.NewFile(path.Dir(catalogDir)),
+	})
+	if err := seeded.SetInfoBytes(infoBytes); err != nil {
+		t.Fatal(err)
+	}
+	seeded.VerifyData()
+	if seeded.BytesMissing() != 0 {
+		t.Fatal("seeder is missing catalog data")
+	}
+
+	magnet := mi.Magnet(nil, &info)
+	magnet.Params = map[string][]string{"x.pe": {seeder.ListenAddrs()[0].String()}}
+	return magnet
+}
+
+// setCatalog subscribes s to catalogInfoHash and waits for the catalog to
+// be merged.
+func setCatalog(t *testing.T, s *Server, catalogInfoHash string) {
+	t.Helper()
+
+	Settings.CatalogInfoHash = catalogInfoHash
+	select {
+	case <-s.subscribeCatalog():
+	case <-time.After(time.Minute):
+		t.Fatal("catalog torrent not fetched within a minute")
+	}
+}
+
+// TestSubscribeCatalog seeds a catalog torrent from one client and
+// subscribes to it by magnet from another, over localhost.
+func TestSubscribeCatalog(t *testing.T) {
+	items := testItems(20)
+	magnet := seedTestCatalog(t, newTestClient(t, t.TempDir()), "cat", items)
+
+	s := newTestServer(t, newTestClient(t, t.TempDir()))
+	s.storageDir = t.TempDir()
+
+	saved := Settings.CatalogInfoHash
+	defer func() { Settings.CatalogInfoHash = saved }()
+	setCatalog(t, s, magnet.String())
+
+	if got := s.w64storage.NumberOfChunks(); got != len(items) {
+		t.Fatalf("live catalog holds %d items, want %d", got, len(items))
+	}
+	for _, item := range items {
+		hash, err := item.InfoHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := s.w64storage.LookupByInfoHash(hash); !ok {
+			t.Errorf("%s not merged", item.Name)
+		}
+	}
+
+	query, err := search.ParseQuery("subscribed movie 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matches := s.searchIndex.Search(query); len(matches) == 0 {
+		t.Error("merged items not in the search index")
+	}
+
+	// The subscription keeps seeding, and merging again adds nothing.
+	fetched := path.Join(s.storageDir, "subscriptions", magnet.InfoHash.HexString())
+	stats, err := s.MergeCatalog(fetched, "cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Added != 0 || stats.Duplicates != len(items) {
+		t.Errorf("merging again: %+v", stats)
+	}
+}
+
+// TestResubscribeCatalog changes the catalog subscribed to, which drops
+// the torrent of the previous one.
+func TestResubscribeCatalog(t *testing.T) {
+	seeder := newTestClient(t, t.TempDir())
+	first := seedTestCatalog(t, seeder, "cat", testItems(5))
+	second := seedTestCatalog(t, seeder, "other", testItems(8))
+
+	s := newTestServer(t, newTestClient(t, t.TempDir()))
+	s.storageDir = t.TempDir()
+
+	saved := Settings.CatalogInfoHash
+	defer func() { Settings.CatalogInfoHash = saved }()
+	setCatalog(t, s, first.String())
+	merged := s.subscribeCatalog()
+	setCatalog(t, s, first.String())
+	if s.subscribeCatalog() != merged {
+		t.Error("subscribing again to the same catalog restarted the subscription")
+	}
+
+	setCatalog(t, s, second.String())
+	if got := s.w64storage.NumberOfChunks(); got != 8 {
+		t.Errorf("live catalog holds %d items, want 8", got)
+	}
+	if _, ok := s.Client.Torrent(second.InfoHash); !ok {
+		t.Error("subscribed catalog torrent not seeding")
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, ok := s.Client.Torrent(first.InfoHash); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("torrent of the previous catalog not dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	setCatalog(t, s, "")
+	deadline = time.Now().Add(10 * time.Second)
+	for {
+		if _, ok := s.Client.Torrent(second.InfoHash); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("catalog torrent not dropped after unsubscribing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestFetchCatalogTimeout subscribes to a catalog torrent without peers,
+// which must give up once CatalogFetchTimeout is over.
+func TestFetchCatalogTimeout(t *testing.T) {
+	savedTimeout, savedCatalog := defaultCatalogFetchTimeout, Settings.CatalogInfoHash
+	defer func() { defaultCatalogFetchTimeout, Settings.CatalogInfoHash = savedTimeout, savedCatalog }()
+	defaultCatalogFetchTimeout = 100 * time.Millisecond
+
+	s := newTestServer(t, newTestClient(t, t.TempDir()))
+	s.storageDir = t.TempDir()
+	infoHash := metainfo.NewHashFromHex(fmt.Sprintf("%040x", 0xdead))
+	setCatalog(t, s, infoHash.HexString())
+
+	if _, ok := s.Client.Torrent(infoHash); ok {
+		t.Error("catalog torrent left in the client after timing out")
+	}
+	if got := s.w64storage.NumberOfChunks(); got != 0 {
+		t.Errorf("live catalog holds %d items", got)
+	}
+}
+
+// TestMergeCorruptCatalog checks that a catalog with a damaged segment is
+// rejected as a whole.
+func TestMergeCorruptCatalog(t *testing.T) {
+	items := testItems(5)
+	dir := t.TempDir()
+	writeTestCatalog(t, dir, "cat", items)
+
+	segment := path.Join(dir, "cat000")
+	content, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(segment, content[:len(content)-3], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, nil)
+	if _, err := s.MergeCatalog(dir, "cat"); err == nil {
+		t.Fatal("merged a corrupt catalog")
+	}
+	if got := s.w64storage.NumberOfChunks(); got != 0 {
+		t.Fatalf("live catalog holds %d items after a rejected merge", got)
+	}
+}
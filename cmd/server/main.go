@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -8,7 +9,6 @@ import (
 	"net/url"
 	"os"
 	"path"
-	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +21,8 @@ import (
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/gorilla/websocket"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
 )
 
 
@@ -31,10 +33,13 @@ type Server struct {
 	*torrent.Client
 
 	SearchManager
+	metadataJobs chan metadataJob
 
 	MainTorrent  string
 	MainFile     string
 	AppIsClosing bool
+
+	subscription catalogSubscription
 }
 
 func main() {
@@ -58,12 +63,14 @@ func main() {
 	mainwin := server.App.NewWindow("123movies")
 	mainwin.Resize(fyne.NewSize(400, 710))
 
+	server.LoadSettings()
+	server.startMetadataWorkers()
+
 	go server.startWebsocket()
 	go server.startServer()
 	server.AppIsClosing = false
 
 	go server.initmainclient()
-	server.LoadSettings()
 
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Home", server.homeScreen(mainwin)),
@@ -135,23 +142,26 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	defer conn.Close()
 
+	// Each tab searches on its own, and its previews go with it.
+	session := s.NewSearchSession()
+	defer session.Close()
+
 	// Continuosly read and write message
 	for {
 		mt, message, err := conn.ReadMessage()
 		if err != nil {
 			log.Println("read failed:", err)
-			//break
-			//App.Quit()
+			break
 		}
 		messagestring := string(message)
 		messageArr := strings.Split(messagestring, "*")
 		log.Println("got:", messagestring)
 
-		returnmessagestring := s.runCmd(messageArr) //[]byte("return message")
+		returnmessagestring := s.runCmd(session, messageArr) //[]byte("return message")
 		err = conn.WriteMessage(mt, []byte(returnmessagestring))
 		if err != nil {
 			log.Println("write failed:", err)
-			//break
+			break
 		}
 	}
 }
@@ -159,7 +169,7 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 type ServerCommand = string
 
 const (
-	GetSearchResult    ServerCommand = "GETSEARCHRESULT"
+	GetSearchPage      ServerCommand = "GETSEARCHPAGE"
 	SetSearchQuery                   = "SETSEARCHQUERY"
 	SetMainTorrent                   = "SETMAINTORRENT"
 	SetMainFile                      = "SETMAINFILE"
@@ -167,31 +177,36 @@ const (
 	RemoveSavedItem                  = "REMOVESAVEDITEM"
 	RequestTorrentInfo               = "REQUESTTORRENTINFO"
 	RequestIsSavedItem               = "REQUESTISSAVEDITEM"
+	RequestCatalogItem               = "REQUESTCATALOGITEM"
+	SetCatalog                       = "SETCATALOG"
 )
 
-func (s *Server) runCmd(messageArr []string) string {
+func (s *Server) runCmd(session *SearchSession, messageArr []string) string {
 	if len(messageArr) == 0 {
 		return "Unkown command"
 	}
 
 	switch messageArr[0] {
-	case GetSearchResult:
-		tmpint, cierr := strconv.Atoi(messageArr[1])
-		if cierr != nil {
+	case GetSearchPage:
+		if len(messageArr) < 2 {
 			return "Unkown command"
 		}
 
-		if tmpint >= len(SearchResults) {
-			go s.MoreSearchResults(s)
-			return "SEARCHRESULTNOTFOUND"
+		page, err := session.Page(s, messageArr[1])
+		if err == ErrExpiredCursor {
+			return "SEARCHPAGEEXPIRED*" + messageArr[1]
+		} else if err != nil {
+			return "Unkown command"
 		}
 
-		return s.GetSearchResult(tmpint)
+		return s.getSearchPageResponse(page)
 	//setSearchQuery
 	case SetSearchQuery:
-		PreviewingTorrentMagnetArr = PreviewingTorrentMagnetArr[:0]
-		EmptySearchResults()
-		go s.SetSearchQuery(s, messageArr[1])
+		cursor, err := session.SetQuery(s, messageArr[1])
+		if err != nil {
+			return "SEARCHQUERYERROR*" + err.Error()
+		}
+		return "SEARCHQUERY*" + cursor
 	case SetMainTorrent:
 		s.SetMainTorrent(messageArr[1])
 		if len(messageArr) > 2 {
@@ -209,6 +224,14 @@ func (s *Server) runCmd(messageArr []string) string {
 		}
 	case RequestIsSavedItem:
 		return s.getIsSavedItemResponse(messageArr[1])
+	case RequestCatalogItem:
+		if len(messageArr) > 1 {
+			return s.getCatalogItemResponse(messageArr[1])
+		}
+	case SetCatalog:
+		if len(messageArr) > 1 {
+			s.SetCatalogInfoHash(messageArr[1])
+		}
 	default:
 		fmt.Println("Unkown command")
 	}
@@ -231,6 +254,8 @@ func (s *Server) initmainclient() (err error) {
 
 	log.Print("new torrent client INITIATED")
 
+	s.subscribeCatalog()
+
 	for !s.AppIsClosing {
 		time.Sleep(1 * time.Second)
 	}
@@ -258,15 +283,47 @@ func (s *Server) SetMainTorrent(magnet string) {
 		}
 	}
 }
-func (s *Server) addtorrent(tmpname string, tmpdescription string, tmpmagneturi string) {
+// addtorrent previews the torrent of result, a search match of session,
+// and adds the result to session once its metadata arrives, or without it
+// if MetadataTimeout elapses first. It gives up when ctx, the context of
+// the search that found the match, is done.
+func (s *Server) addtorrent(ctx context.Context, session *SearchSession, result ItemType) {
+	tmpmagneturi := result.Magnet
 	t, err := s.AddMagnet(tmpmagneturi)
 	if err != nil {
-		log.Print("new torrent error: %w", err)
+		log.Printf("new torrent error: %v", err)
+		session.SkipMatch(ctx)
+		return
+	}
+	if !session.AddPreview(ctx, tmpmagneturi) {
+		s.dropUnusedTorrent(t, tmpmagneturi)
+		return
 	}
 
-	<-t.GotInfo()
+	timeout := time.NewTimer(MetadataTimeout())
+	defer timeout.Stop()
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		session.RemovePreview(tmpmagneturi)
+		session.SkipMatch(ctx)
+		return
+	case <-ctx.Done():
+		log.Printf("gave up on magnet %s: %v", tmpmagneturi, ctx.Err())
+		session.RemovePreview(tmpmagneturi)
+		s.dropUnusedTorrent(t, tmpmagneturi)
+		return
+	case <-timeout.C:
+		log.Printf("no metadata for magnet %s", tmpmagneturi)
+		session.RemovePreview(tmpmagneturi)
+		s.dropUnusedTorrent(t, tmpmagneturi)
+		result.Unavailable = true
+		session.AddResult(ctx, result)
+		return
+	}
 
 	log.Printf("added magnet %s\n", tmpmagneturi)
+	s.IndexFiles(t.InfoHash(), t.Info())
 	files := t.Files()
 	totalsize := int64(0)
 	tmppreviewfile := ""
@@ -291,17 +348,29 @@ func (s *Server) addtorrent(tmpname string, tmpdescription string, tmpmagneturi
 		}
 	}
 
-	AddPreviewingTorrent(tmpmagneturi)
-	AddSearchResultItem(tmpname+" "+PrettyBytes(totalsize), tmpdescription, tmpmagneturi, tmppreviewfile)
+	result.Name += " " + PrettyBytes(totalsize)
+	result.PreviewFile = tmppreviewfile
+	if !session.AddResult(ctx, result) {
+		session.RemovePreview(tmpmagneturi)
+	}
 
-	for {
-		if (!IsSavedItemWithMagnet(tmpmagneturi)) && (!s.IsMainTorrent(tmpmagneturi)) && (!IsPreviewingTorrent(tmpmagneturi)) {
-			log.Println("Torrent removed", tmpmagneturi)
-			t.Drop()
-			return
+	// The worker moves on while the preview lasts.
+	go func() {
+		for !s.dropUnusedTorrent(t, tmpmagneturi) {
+			time.Sleep(8 * time.Second)
 		}
-		time.Sleep(8 * time.Second)
+	}()
+}
+
+// dropUnusedTorrent drops t, the torrent of magnet, unless it is saved,
+// playing or previewed, and reports whether it did.
+func (s *Server) dropUnusedTorrent(t *torrent.Torrent, magnet string) bool {
+	if IsSavedItemWithMagnet(magnet) || s.IsMainTorrent(magnet) || s.IsPreviewingTorrent(magnet) {
+		return false
 	}
+	log.Println("Torrent removed", magnet)
+	t.Drop()
+	return true
 }
 
 func (s *Server) getIsSavedItemResponse(tmpitemmagnet string) string {
@@ -316,6 +385,34 @@ func (s *Server) getIsSavedItemResponse(tmpitemmagnet string) string {
 	return tmpreturnstring
 
 }
+// catalogItemMessage is the JSON form of the catalog item with the info
+// hash of a magnet, sent to the webapp. Like search pages, it is not split
+// into fields, since names and descriptions may hold '*'.
+type catalogItemMessage struct {
+	Magnet      string `json:"magnet"` // as asked for
+	Found       bool   `json:"found"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ItemMagnet  string `json:"itemmagnet"` // as stored in the catalog
+}
+
+// getCatalogItemResponse sends the catalog item with the info hash of
+// tmpmagneturi as CATALOGITEM*json, see catalogItemMessage.
+func (s *Server) getCatalogItemResponse(tmpmagneturi string) string {
+	message := catalogItemMessage{Magnet: tmpmagneturi}
+	if chunkid, item, ok := s.LookupItem(tmpmagneturi); ok {
+		message.Found = true
+		message.ID = chunkid
+		message.Name = item.Name
+		message.Description = item.Description
+		message.ItemMagnet = item.Magnet
+	}
+
+	// Strings and numbers always encode.
+	messageBytes, _ := json.Marshal(message)
+	return "CATALOGITEM*" + string(messageBytes)
+}
 func (s *Server) getTorrentInfoResponse(tmpmagneturi string) string {
 	fmt.Printf("REQUESTTORRENTINFO %s \n", tmpmagneturi)
 	var tmpreturnstring = "TORRENTINFO"
@@ -376,58 +473,129 @@ func (s *Server) Prioritize(tmpmagneturi string, filepath string) {
 }
 
 func (s *Server) IsMainTorrent(magnet string) bool {
-	return s.MainTorrent == magnet
+	return SameTorrent(s.MainTorrent, magnet)
 
 }
 
+// SameTorrent reports whether two magnet URIs point to the same torrent,
+// comparing their info hashes so that differing trackers or display names
+// do not matter. Magnets that do not parse are compared as strings.
+func SameTorrent(magnet1 string, magnet2 string) bool {
+	tmpmagnet1, err1 := metainfo.ParseMagnetUri(magnet1)
+	tmpmagnet2, err2 := metainfo.ParseMagnetUri(magnet2)
+	if err1 != nil || err2 != nil {
+		return magnet1 == magnet2
+	}
+	return tmpmagnet1.InfoHash == tmpmagnet2.InfoHash
+}
+
 type SettingsType struct {
 	LocalHostPort int
 	SavedItems    []ItemType
+
+	// Publishers whose signed catalog items are shown under their alias.
+	TrustedPublishers []PublisherType
+	// Hide catalog items not signed by a trusted publisher.
+	OnlyTrustedPublishers bool
+	// Magnet URI or hex info hash of a catalog torrent to merge into the
+	// local catalog, and minutes to wait for it to download, 60 if zero.
+	CatalogInfoHash            string
+	CatalogFetchTimeoutMinutes int
+	// Number of search matches whose torrent metadata is fetched at once,
+	// and seconds to wait for it before showing a match as unavailable. Zero
+	// stands for the defaults, 4 and 30.
+	MetadataWorkers        int
+	MetadataTimeoutSeconds int
+	// Number of search results loaded at a time as the webapp scrolls, 12
+	// if zero.
+	SearchPageSize int
 }
 
 var Settings SettingsType
 
+type PublisherType struct {
+	Alias     string
+	PublicKey []byte // ed25519 public key, base64 in Settings.json
+}
+
+func TrustedPublishers() catalog.Publishers {
+	var publishers catalog.Publishers
+	for _, tmpe := range Settings.TrustedPublishers {
+		publishers = append(publishers, catalog.Publisher{Alias: tmpe.Alias, PublicKey: tmpe.PublicKey})
+	}
+	return publishers
+}
+
 type ItemType struct {
 	//Path string
 	Name        string
 	Description string
 	Magnet      string
 	PreviewFile string
-}
-
-var PreviewingTorrentMagnetArr []string
-var SearchResults []ItemType
-
-func SearchResultsFull() bool {
-	if len(SearchResults) > 5 {
-		return true
+	Channel     string
+	Details     string
+	// The metadata of the torrent could not be fetched: there is no
+	// preview, and the name misses the size.
+	Unavailable bool
+}
+
+// searchPageMessage is the JSON form of a SearchPage sent to the webapp.
+// Names and descriptions may hold any character, '*' included, so pages
+// are not split into fields like the other messages.
+type searchPageMessage struct {
+	Cursor  string                `json:"cursor"`
+	Next    string                `json:"next"`
+	Hits    int                   `json:"hits"`
+	Exact   bool                  `json:"exact"`
+	Status  string                `json:"status"` // PARTIAL, COMPLETE or END
+	Results []searchResultMessage `json:"results"`
+}
+
+type searchResultMessage struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Magnet      string `json:"magnet"`
+	PreviewFile string `json:"previewfile"`
+	Channel     string `json:"channel"`
+	Details     string `json:"details"`
+	Unavailable bool   `json:"unavailable"`
+}
+
+// getSearchPageResponse sends page as SEARCHPAGE*json, see
+// searchPageMessage.
+func (s *Server) getSearchPageResponse(page SearchPage) string {
+	message := searchPageMessage{
+		Cursor:  page.Cursor,
+		Next:    page.Next,
+		Hits:    page.Hits,
+		Exact:   page.Exact,
+		Status:  "PARTIAL",
+		Results: []searchResultMessage{},
+	}
+	switch {
+	case !page.More:
+		message.Status = "END"
+	case page.Complete:
+		message.Status = "COMPLETE"
+	}
+	for _, item := range page.Results {
+		message.Results = append(message.Results, searchResultMessage{
+			Name:        item.Name,
+			Description: item.Description,
+			Magnet:      item.Magnet,
+			PreviewFile: item.PreviewFile,
+			Channel:     item.Channel,
+			Details:     item.Details,
+			Unavailable: item.Unavailable,
+		})
 	}
-	return false
-}
-func AddSearchResultItem(tmpname string, tmpdescription string, tmpmagneturi string, tmppreviewfile string) {
-	NewItem := new(ItemType)
-	NewItem.Name = tmpname
-	NewItem.Description = tmpdescription
-	NewItem.Magnet = tmpmagneturi
-	NewItem.PreviewFile = tmppreviewfile
-	SearchResults = append(SearchResults, *NewItem)
 
-}
-func (s *Server) GetSearchResult(index int) string {
-	var tmpsearchresultstring = "SEARCHRESULT"
-	if len(SearchResults) <= index {
-		return "NOSEARCHRESULTFOUND"
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("encoding search page: %v", err)
+		return "SEARCHPAGEEXPIRED*" + page.Cursor
 	}
-
-	tmpsearchresultstring += "*" + SearchResults[index].Name
-	tmpsearchresultstring += "*" + SearchResults[index].Description
-	tmpsearchresultstring += "*" + SearchResults[index].Magnet
-	tmpsearchresultstring += "*" + SearchResults[index].PreviewFile
-
-	return tmpsearchresultstring
-}
-func EmptySearchResults() {
-	SearchResults = SearchResults[:0]
+	return "SEARCHPAGE*" + string(messageBytes)
 }
 func LoadDefaultSettings() {
 	Settings.LocalHostPort = 666
@@ -472,21 +640,9 @@ func SaveSettings() {
 
 }
 
-func AddPreviewingTorrent(tmpmagnet string) {
-	PreviewingTorrentMagnetArr = append(PreviewingTorrentMagnetArr, tmpmagnet)
-}
-func IsPreviewingTorrent(magnet string) bool {
-	for _, tmpe := range PreviewingTorrentMagnetArr {
-		if tmpe == magnet {
-			return true
-		}
-	}
-	return false
-}
-
 func IsSavedItemWithMagnet(magnet string) bool {
 	for _, tmpe := range Settings.SavedItems {
-		if tmpe.Magnet == magnet {
+		if SameTorrent(tmpe.Magnet, magnet) {
 			return true
 		}
 	}
@@ -494,6 +650,10 @@ func IsSavedItemWithMagnet(magnet string) bool {
 }
 
 func (s *Server) AddSavedItem(itemname string, itemdescription string, itemmagnet string, itempreviewfile string) {
+	if IsSavedItemWithMagnet(itemmagnet) {
+		return
+	}
+
 	var tmpsaveditem ItemType
 
 	tmpsaveditem.Name = itemname
@@ -509,7 +669,7 @@ func (s *Server) RemoveSavedItem(itemmagnet string) {
 }
 func (s *Server) removefromsaveditems(slice []ItemType, itemmagnet string) []ItemType {
 	for i, tmpe := range slice {
-		if tmpe.Magnet == itemmagnet {
+		if SameTorrent(tmpe.Magnet, itemmagnet) {
 			return append(slice[:i], slice[i+1:]...)
 		}
 	}
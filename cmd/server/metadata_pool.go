@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const defaultMetadataWorkers = 4
+
+// defaultMetadataTimeout is a variable so that tests need not wait for it.
+var defaultMetadataTimeout = 30 * time.Second
+
+// metadataJob is a search match of session waiting for the metadata of
+// its torrent, found by the search of ctx.
+type metadataJob struct {
+	ctx     context.Context
+	session *SearchSession
+	result  ItemType
+}
+
+// startMetadataWorkers starts the workers fetching the metadata of search
+// matches, MetadataWorkers of them, so that a few magnets without peers
+// cannot hold up every search.
+func (s *Server) startMetadataWorkers() {
+	s.metadataJobs = make(chan metadataJob)
+	for i := 0; i < MetadataWorkers(); i++ {
+		go func() {
+			for job := range s.metadataJobs {
+				if job.ctx.Err() != nil {
+					continue
+				}
+				s.addtorrent(job.ctx, job.session, job.result)
+			}
+		}()
+	}
+}
+
+// queueMetadataJob waits for a worker to take job, reporting false if the
+// search of the job ends first.
+func (s *Server) queueMetadataJob(job metadataJob) bool {
+	select {
+	case s.metadataJobs <- job:
+		return true
+	case <-job.ctx.Done():
+		return false
+	}
+}
+
+// MetadataWorkers returns how many search matches have their torrent
+// metadata fetched at once.
+func MetadataWorkers() int {
+	if Settings.MetadataWorkers > 0 {
+		return Settings.MetadataWorkers
+	}
+	return defaultMetadataWorkers
+}
+
+// MetadataTimeout returns how long to wait for the metadata of a search
+// match before showing it as unavailable.
+func MetadataTimeout() time.Duration {
+	if Settings.MetadataTimeoutSeconds > 0 {
+		return time.Duration(Settings.MetadataTimeoutSeconds) * time.Second
+	}
+	return defaultMetadataTimeout
+}
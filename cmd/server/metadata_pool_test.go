@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// setMetadataPool sets the number of metadata workers and their timeout
+// for the length of a test.
+func setMetadataPool(t *testing.T, workers int, timeout time.Duration) {
+	savedWorkers, savedSeconds, savedTimeout := Settings.MetadataWorkers, Settings.MetadataTimeoutSeconds, defaultMetadataTimeout
+	t.Cleanup(func() {
+		Settings.MetadataWorkers, Settings.MetadataTimeoutSeconds, defaultMetadataTimeout = savedWorkers, savedSeconds, savedTimeout
+	})
+	Settings.MetadataWorkers, Settings.MetadataTimeoutSeconds, defaultMetadataTimeout = workers, 0, timeout
+}
+
+// previewCount returns the number of torrents session previews.
+func (session *SearchSession) previewCount() int {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return len(session.previews)
+}
+
+func TestQueueMetadataJob(t *testing.T) {
+	s := &Server{metadataJobs: make(chan metadataJob)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.queueMetadataJob(metadataJob{ctx: ctx}) {
+		t.Error("job of a finished search queued")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	taken := make(chan metadataJob, 1)
+	go func() { taken <- <-s.metadataJobs }()
+	if !s.queueMetadataJob(metadataJob{ctx: ctx, result: ItemType{Name: "taken"}}) {
+		t.Fatal("job not queued")
+	}
+	if job := <-taken; job.result.Name != "taken" {
+		t.Errorf("worker took %q", job.result.Name)
+	}
+
+	// A job waiting for a worker gives up when its search ends.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if s.queueMetadataJob(metadataJob{ctx: ctx}) {
+		t.Error("job queued without a worker")
+	}
+}
+
+// TestMetadataWorkersBound looks for matches whose magnets have no peers
+// and checks that no more than MetadataWorkers of them wait for their
+// metadata at once, and that each shows up as unavailable once
+// MetadataTimeout elapses.
+func TestMetadataWorkersBound(t *testing.T) {
+	const workers, timeout = 2, 200 * time.Millisecond
+	setMetadataPool(t, workers, timeout)
+
+	s := newTestServer(t, newTestClient(t, t.TempDir()))
+	s.startMetadataWorkers()
+	items := namedItems("Dead.Movie", 0x300, 3*workers)
+	addTestItems(t, s, items)
+
+	session := s.NewSearchSession()
+	defer session.Close()
+	start := time.Now()
+	cursor, err := session.SetQuery(s, "dead movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	running := 0
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if n := session.previewCount(); n > running {
+			running = n
+		}
+		page, err := session.Page(s, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !page.More {
+			if len(page.Results) != len(items) {
+				t.Fatalf("%d results, want %d", len(page.Results), len(items))
+			}
+			for _, result := range page.Results {
+				if !result.Unavailable {
+					t.Errorf("%s not shown as unavailable", result.Name)
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("results still coming: %+v", page)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if running != workers {
+		t.Errorf("%d magnets fetched at once, want %d", running, workers)
+	}
+	if elapsed := time.Since(start); elapsed < 3*timeout {
+		t.Errorf("%d magnets timed out after %v with %d workers", len(items), elapsed, workers)
+	}
+	if n := session.previewCount(); n != 0 {
+		t.Errorf("%d previews left of torrents without metadata", n)
+	}
+}
+
+// TestMetadataTimeoutDoesNotStall checks that a match whose magnet has no
+// peers does not hold up one whose torrent is seeded.
+func TestMetadataTimeoutDoesNotStall(t *testing.T) {
+	const timeout = 3 * time.Second
+	setMetadataPool(t, 2, timeout)
+
+	seedDir := t.TempDir()
+	file := path.Join(seedDir, "Sintel.Live.mp4")
+	if err := os.WriteFile(file, bytes.Repeat([]byte("sintel"), 10000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(file); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi := metainfo.MetaInfo{InfoBytes: infoBytes}
+	seeder := newTestClient(t, seedDir)
+	seeded, err := seeder.AddTorrent(&mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeded.VerifyData()
+
+	magnet := mi.Magnet(nil, &info)
+	magnet.Params = map[string][]string{"x.pe": {seeder.ListenAddrs()[0].String()}}
+
+	s := newTestServer(t, newTestClient(t, t.TempDir()))
+	s.startMetadataWorkers()
+	addTestItems(t, s, []catalog.Item{
+		{Name: "Sintel.Dead.1080p", Magnet: "magnet:?xt=urn:btih:0000000000000000000000000000000000000400"},
+		{Name: "Sintel.Live.1080p", Magnet: magnet.String()},
+	})
+
+	session := s.NewSearchSession()
+	defer session.Close()
+	start := time.Now()
+	cursor, err := session.SetQuery(s, "sintel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var page SearchPage
+	for len(page.Results) == 0 {
+		if time.Since(start) > timeout {
+			t.Fatal("seeded match not shown before the other timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+		if page, err = session.Page(s, cursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	live := page.Results[0]
+	if !strings.HasPrefix(live.Name, "Sintel.Live.1080p") || live.Unavailable || live.PreviewFile != "Sintel.Live.mp4" {
+		t.Fatalf("first result %+v, want the seeded one with its preview", live)
+	}
+
+	page = waitPage(t, s, session, cursor)
+	if len(page.Results) != 2 || page.Results[1].Name != "Sintel.Dead.1080p" || !page.Results[1].Unavailable {
+		t.Fatalf("results %+v, want the seeded one then the unavailable one", page.Results)
+	}
+	if elapsed := time.Since(start); elapsed < timeout {
+		t.Errorf("match without peers shown as unavailable after %v, before MetadataTimeout", elapsed)
+	}
+}
@@ -1,119 +1,143 @@
 package main
 
 import (
-	"encoding/binary"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
 	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+	"github.com/wetorrent/wetorrent/internal/release"
+	"github.com/wetorrent/wetorrent/internal/search"
 )
 
 type SearchManager struct {
-	w64storage      *chunk_storage.ChunkStorage
-	MainSearchQuery string
-	MainSearchIndex int
+	w64storage  *chunk_storage.ChunkStorage
+	searchIndex *search.Index
+	storageDir  string
+
+	// The search sessions of the open webapp tabs.
+	sessionsMu sync.Mutex
+	sessions   map[*SearchSession]bool
 }
 
 func (s *SearchManager) Init(storageDir, fileNamePrefix string) (err error) {
-	if s.w64storage, err = chunk_storage.New(storageDir, fileNamePrefix); err != nil {
+	if s.w64storage, err = chunk_storage.New(storageDir, fileNamePrefix, chunk_storage.Options{Mmap: true, InfoHashFunc: catalog.ChunkInfoHash}); err != nil {
 		return fmt.Errorf("creating w64 storage: %v", err)
 	}
 	fmt.Println("SearchManger Init at storageDir",storageDir)
-	s.MainSearchQuery = ""
-	s.MainSearchIndex = s.w64storage.NumberOfChunks() - 1
+	s.storageDir = storageDir
+	for _, r := range s.w64storage.Recovered() {
+		log.Printf("recovered w64 storage: %v", r)
+	}
+
+	if s.searchIndex, err = search.Open(s.w64storage); err != nil {
+		return fmt.Errorf("opening search index: %v", err)
+	}
+	if err := s.searchIndex.Save(); err != nil {
+		log.Printf("saving search index: %v", err)
+	}
 
 	return nil
 }
 
-func (s *SearchManager) SetSearchQuery(server *Server, query string) {
-	if query == "" {
-		query = "sexy"
+// UpdateSearchIndex indexes the items added to the catalog since the search
+// index was last brought up to date.
+func (s *SearchManager) UpdateSearchIndex() {
+	if _, err := s.searchIndex.Update(); err != nil {
+		log.Printf("updating search index: %v", err)
 		return
 	}
-
-	if query != s.MainSearchQuery {
-		fmt.Println("New searchquery**", s.w64storage.NumberOfChunks())
-		s.MainSearchQuery = query
-		s.MainSearchIndex = s.w64storage.NumberOfChunks() - 1
-		EmptySearchResults()
+	if err := s.searchIndex.Save(); err != nil {
+		log.Printf("saving search index: %v", err)
 	}
-
-	go s.MoreSearchResults(server)
 }
 
-func (s *SearchManager) MoreSearchResults(server *Server) {
-	if s.MainSearchQuery == "" {
-		return
+// IndexFiles makes the file paths of the torrent with info hash hash
+// searchable, once its metadata is known.
+func (s *SearchManager) IndexFiles(hash metainfo.Hash, info *metainfo.Info) {
+	var paths []string
+	for _, file := range info.UpvertedFiles() {
+		paths = append(paths, file.DisplayPath(info))
 	}
+	if err := s.searchIndex.AddFiles(hash, paths); err != nil {
+		log.Printf("indexing files: %v", err)
+	}
+}
 
-	for s.MainSearchIndex >= 0 {
-		chunkBytes := s.w64storage.GetChunkById(s.MainSearchIndex)
-		name, description, magnet := s.ReadItemBytes(chunkBytes)
-
-		s.MainSearchIndex--
-
-		if name == "" {
-			continue
-		}
-
-		if SearchResultsFull() {
-			return
-		}
-
-		if strings.Contains(strings.ToLower(name), strings.ToLower(s.MainSearchQuery)) {
-			_ = name
-			_ = description
-			_ = magnet
-
-			server.addtorrent(name, description, magnet)
+// ReleaseDetails summarizes what the name of a release tells about it, for
+// display along with the name, as in "S02E05 · 720p · WEB-DL · H.265 · GRP".
+func ReleaseDetails(info release.Info) string {
+	var details []string
+	switch {
+	case info.Season > 0 && info.Episode > 0:
+		details = append(details, fmt.Sprintf("S%02dE%02d", info.Season, info.Episode))
+	case info.Season > 0:
+		details = append(details, fmt.Sprintf("Season %d", info.Season))
+	case info.Episode > 0:
+		details = append(details, fmt.Sprintf("Episode %d", info.Episode))
+	}
+	if info.Year > 0 {
+		details = append(details, strconv.Itoa(info.Year))
+	}
+	for _, detail := range []string{info.Resolution, info.Source, info.VideoCodec, info.AudioCodec} {
+		if detail != "" {
+			details = append(details, detail)
 		}
 	}
+	details = append(details, info.Languages...)
+	if info.Group != "" {
+		details = append(details, info.Group)
+	}
+	return strings.Join(details, " · ")
 }
 
-func (s *SearchManager) ReadItemBytes(brContent []byte) (string, string, string) {
-	maxCounter := len(brContent)
-	counter := 1
-
-	if counter > maxCounter {
-		return "", "", "" // unexpected end of content
+// PublisherAlias returns the alias under which the publisher who signed item
+// is trusted, or "" for items without a trusted signature. It reports false
+// for items to hide: those whose signature does not verify, which were
+// tampered with, and untrusted ones when only trusted publishers are shown.
+func PublisherAlias(item catalog.Item) (string, bool) {
+	publisher, trusted, err := TrustedPublishers().Lookup(item)
+	if err != nil {
+		return "", false
 	}
-
-	lenName := int(brContent[counter-1])
-	counter += lenName
-
-	if counter > maxCounter {
-		return "", "", "" // unexpected end of content
+	if !trusted {
+		return "", !Settings.OnlyTrustedPublishers
 	}
+	return publisher.Alias, true
+}
 
-	bytesName := brContent[counter-lenName : counter]
-	counter += 2
-
-	if counter > maxCounter {
+func (s *SearchManager) ReadItemBytes(brContent []byte) (string, string, string) {
+	item, err := catalog.DecodeItem(brContent)
+	if err != nil {
 		return "", "", "" // unexpected end of content
 	}
 
-	lenDescription := int(binary.LittleEndian.Uint16(brContent[counter-2 : counter]))
-	counter += lenDescription
+	return item.Name, item.Description, item.Magnet
+}
 
-	if counter > maxCounter {
-		return "", "", "" // unexpected end of content
+// LookupItem returns the catalog item with the same info hash as magnet.
+func (s *SearchManager) LookupItem(magnet string) (chunkid int, item catalog.Item, ok bool) {
+	hash, err := catalog.Item{Magnet: magnet}.InfoHash()
+	if err != nil {
+		return 0, catalog.Item{}, false
 	}
 
-	bytesDecription := brContent[counter-lenDescription : counter]
-	counter += 2
-
-	if counter > maxCounter {
-		return "", "", "" // unexpected end of content
+	chunkid, ok = s.w64storage.LookupByInfoHash(hash)
+	if !ok {
+		return 0, catalog.Item{}, false
 	}
 
-	lenMagnet := int(binary.LittleEndian.Uint16(brContent[counter-2 : counter]))
-	counter += lenMagnet
-
-	if counter > maxCounter {
-		return "", "", "" // unexpected end of content
+	chunk, err := s.w64storage.GetChunkById(chunkid)
+	if err != nil {
+		return 0, catalog.Item{}, false
 	}
-
-	bytesMagnet := brContent[counter-lenMagnet : counter]
-
-	return string(bytesName), string(bytesDecription), string(bytesMagnet)
+	if item, err = catalog.DecodeItem(chunk); err != nil {
+		return 0, catalog.Item{}, false
+	}
+	return chunkid, item, true
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// TestReadItemBytes checks that the items written by the catalog encoders,
+// in either layout, read back through ReadItemBytes.
+func TestReadItemBytes(t *testing.T) {
+	var s SearchManager
+	for _, item := range []catalog.Item{
+		{Name: "Big.Buck.Bunny.2008.1080p", Description: "Blender open movie", Magnet: "magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c"},
+		{Name: "No description", Magnet: "magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c"},
+		{Name: strings.Repeat("n", catalog.MaxNameLength), Description: "*separators* in*text", Magnet: "magnet:?xt=urn:btih:0000000000000000000000000000000000000001"},
+	} {
+		for _, encode := range []func(catalog.Item) ([]byte, error){catalog.EncodeItem, catalog.EncodeLegacyItem} {
+			b, err := encode(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, description, magnet := s.ReadItemBytes(b)
+			if name != item.Name || description != item.Description || magnet != item.Magnet {
+				t.Errorf("%q read back as %q, %q, %q", item.Name, name, description, magnet)
+			}
+			if name, _, _ := s.ReadItemBytes(b[:len(b)-1]); name != "" {
+				t.Errorf("%q read from a truncated item", name)
+			}
+		}
+	}
+}
+
+func TestPublisherAlias(t *testing.T) {
+	key := func(seed byte) ed25519.PrivateKey {
+		s := make([]byte, ed25519.SeedSize)
+		s[0] = seed
+		return ed25519.NewKeyFromSeed(s)
+	}
+	sign := func(seed byte) catalog.Item {
+		item := catalog.Item{Name: "Item", Magnet: "magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c"}
+		if err := item.Sign(key(seed)); err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+	tampered := sign(1)
+	tampered.Description = "injected"
+
+	saved := Settings.TrustedPublishers
+	savedOnly := Settings.OnlyTrustedPublishers
+	defer func() { Settings.TrustedPublishers, Settings.OnlyTrustedPublishers = saved, savedOnly }()
+	Settings.TrustedPublishers = []PublisherType{{Alias: "Blender", PublicKey: key(1).Public().(ed25519.PublicKey)}}
+
+	for _, only := range []bool{false, true} {
+		Settings.OnlyTrustedPublishers = only
+		for _, test := range []struct {
+			name  string
+			item  catalog.Item
+			alias string
+			shown bool
+		}{
+			{"trusted", sign(1), "Blender", true},
+			{"untrusted", sign(2), "", !only},
+			{"unsigned", catalog.Item{Name: "Item"}, "", !only},
+			{"tampered", tampered, "", false},
+		} {
+			alias, shown := PublisherAlias(test.item)
+			if alias != test.alias || shown != test.shown {
+				t.Errorf("%s, only trusted %v: got %q, %v; want %q, %v", test.name, only, alias, shown, test.alias, test.shown)
+			}
+		}
+	}
+}
+
+// TestCatalogItemResponse checks that catalog items reach the webapp
+// whole, whatever characters their names hold.
+func TestCatalogItemResponse(t *testing.T) {
+	s := newTestServer(t, nil)
+	item := catalog.Item{
+		Name:        "Show*S01E02*720p",
+		Description: "stars * and more *stars*",
+		Magnet:      "magnet:?xt=urn:btih:dd8255ecdc7ca55fb0bbf81323d87062db1f6d1c&dn=Show",
+	}
+	addTestItems(t, s, append(namedItems("Other", 0x100, 2), item))
+
+	for _, test := range []struct {
+		magnet string
+		want   catalogItemMessage
+	}{
+		// Items are found by info hash, whatever else the magnet holds.
+		{"magnet:?xt=urn:btih:DD8255ECDC7CA55FB0BBF81323D87062DB1F6D1C", catalogItemMessage{Found: true, ID: 2, Name: item.Name, Description: item.Description, ItemMagnet: item.Magnet}},
+		{"magnet:?xt=urn:btih:0000000000000000000000000000000000000001", catalogItemMessage{}},
+		{"not a magnet", catalogItemMessage{}},
+	} {
+		response := s.getCatalogItemResponse(test.magnet)
+		if !strings.HasPrefix(response, "CATALOGITEM*") {
+			t.Fatalf("%s: response %q", test.magnet, response)
+		}
+		var got catalogItemMessage
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(response, "CATALOGITEM*")), &got); err != nil {
+			t.Fatalf("%s: %v", test.magnet, err)
+		}
+		test.want.Magnet = test.magnet
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.magnet, got, test.want)
+		}
+	}
+}
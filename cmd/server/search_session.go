@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/release"
+	"github.com/wetorrent/wetorrent/internal/search"
+)
+
+const (
+	// searchTimeout bounds the time a query keeps looking for results.
+	searchTimeout = 5 * time.Minute
+
+	defaultSearchPageSize = 12
+)
+
+// SearchSession is the search of one webapp tab: its query, the matches
+// left to look at, the results found so far and the torrents previewed for
+// them. Each websocket connection owns one, so that tabs search
+// independently.
+//
+// Each query is looked for under its own context, cancelled when the query
+// changes, when searchTimeout elapses or when the session closes, which
+// gives up fetching the metadata of its matches.
+//
+// Results are read by pages, from cursors that only hold for the query
+// they were handed out for. The session looks for results a page ahead of
+// the last page asked for.
+type SearchSession struct {
+	manager *SearchManager
+	ctx     context.Context // cancelled by Close
+	close   context.CancelFunc
+
+	mu         sync.Mutex
+	query      string
+	generation int             // of query, counting the queries of the session
+	search     context.Context // of query
+	cancel     context.CancelFunc
+	// Chunk IDs matching query, best first, and the next one to look at.
+	matches  []int
+	next     int
+	results  []ItemType
+	wanted   int      // results to look for
+	pending  int      // matches waiting for their metadata
+	skipped  int      // matches that could not be shown
+	previews []string // magnets of the torrents previewed or being fetched for results
+}
+
+// SearchPage is a page of the results of a query.
+type SearchPage struct {
+	Results []ItemType
+	// The cursor the page was asked for, and the one to ask for next: the
+	// rest of the same page if it is not Complete, or the following page.
+	Cursor, Next string
+	Complete     bool
+	// Whether results may follow the page.
+	More bool
+	// The number of matches of the query, which is Exact once every match
+	// has been looked at; matches are dropped when their publisher is not
+	// trusted.
+	Hits  int
+	Exact bool
+}
+
+// ErrExpiredCursor is returned for cursors of a query since replaced.
+var ErrExpiredCursor = errors.New("search cursor expired")
+
+// searchCursor is an opaque cursor: the results of the query of generation
+// from offset to end, exclusive.
+type searchCursor struct {
+	generation, offset, end int
+}
+
+func (c searchCursor) String() string {
+	return strconv.FormatInt(int64(c.generation), 36) + "." +
+		strconv.FormatInt(int64(c.offset), 36) + "." +
+		strconv.FormatInt(int64(c.end), 36)
+}
+
+func parseSearchCursor(s string) (searchCursor, error) {
+	fields := strings.Split(s, ".")
+	if len(fields) != 3 {
+		return searchCursor{}, fmt.Errorf("invalid search cursor %q", s)
+	}
+	var numbers [3]int
+	for i, field := range fields {
+		n, err := strconv.ParseInt(field, 36, 32)
+		if err != nil || n < 0 {
+			return searchCursor{}, fmt.Errorf("invalid search cursor %q", s)
+		}
+		numbers[i] = int(n)
+	}
+	if numbers[1] > numbers[2] {
+		return searchCursor{}, fmt.Errorf("invalid search cursor %q", s)
+	}
+	return searchCursor{numbers[0], numbers[1], numbers[2]}, nil
+}
+
+// SearchPageSize returns the number of results of a page.
+func SearchPageSize() int {
+	if Settings.SearchPageSize > 0 {
+		return Settings.SearchPageSize
+	}
+	return defaultSearchPageSize
+}
+
+// NewSearchSession starts a session without a query. It must be closed
+// once the connection owning it closes.
+func (s *SearchManager) NewSearchSession() *SearchSession {
+	session := &SearchSession{manager: s}
+	session.ctx, session.close = context.WithCancel(context.Background())
+	session.search, session.cancel = context.WithCancel(session.ctx)
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[*SearchSession]bool)
+	}
+	s.sessions[session] = true
+	return session
+}
+
+// Close ends the session, cancelling its search, and forgets its previews,
+// so that their torrents are dropped unless another session previews them
+// or they are playing or saved.
+func (session *SearchSession) Close() {
+	s := session.manager
+	s.sessionsMu.Lock()
+	delete(s.sessions, session)
+	s.sessionsMu.Unlock()
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.close()
+	session.query, session.matches, session.next = "", nil, 0
+	session.results, session.previews = nil, nil
+	session.wanted, session.pending, session.skipped = 0, 0, 0
+}
+
+// IsPreviewingTorrent reports whether a session previews the torrent of
+// magnet.
+func (s *SearchManager) IsPreviewingTorrent(magnet string) bool {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	for session := range s.sessions {
+		if session.IsPreviewing(magnet) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetQuery cancels the search under way, empties the results and starts
+// filling them with the matches of query. It returns the cursor of the
+// first page of results, or a *search.QueryError if the query is
+// malformed.
+func (session *SearchSession) SetQuery(server *Server, query string) (string, error) {
+	session.mu.Lock()
+	session.cancel()
+	session.generation++
+	session.query, session.matches, session.next = "", nil, 0
+	session.results, session.previews = nil, nil
+	session.wanted, session.pending, session.skipped = 0, 0, 0
+	first := searchCursor{session.generation, 0, SearchPageSize()}
+	if query == "" {
+		session.mu.Unlock()
+		return first.String(), nil
+	}
+
+	parsed, err := search.ParseQuery(query)
+	if err != nil {
+		session.mu.Unlock()
+		return "", err
+	}
+
+	session.query = query
+	session.search, session.cancel = context.WithTimeout(session.ctx, searchTimeout)
+	session.matches = session.manager.searchIndex.Search(parsed)
+	session.wanted = first.end
+	session.mu.Unlock()
+
+	go session.MoreResults(server)
+	return first.String(), nil
+}
+
+// Page returns the results from cursor to the end of its page found so
+// far, and looks for those of the following page. It returns
+// ErrExpiredCursor if the query changed since cursor was handed out.
+func (session *SearchSession) Page(server *Server, cursor string) (SearchPage, error) {
+	c, err := parseSearchCursor(cursor)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	session.mu.Lock()
+	if c.generation != session.generation {
+		session.mu.Unlock()
+		return SearchPage{}, ErrExpiredCursor
+	}
+
+	page := SearchPage{Cursor: cursor, Hits: len(session.matches) - session.skipped}
+	if c.offset < len(session.results) {
+		end := c.end
+		if end > len(session.results) {
+			end = len(session.results)
+		}
+		page.Results = append(page.Results, session.results[c.offset:end]...)
+	}
+
+	next := searchCursor{c.generation, c.offset + len(page.Results), c.end}
+	page.Complete = next.offset == c.end
+	if page.Complete {
+		next.end += SearchPageSize()
+	}
+	page.Next = next.String()
+
+	lookedAtAll := session.next >= len(session.matches) && session.pending == 0
+	page.Exact = lookedAtAll
+	page.More = !lookedAtAll && session.search.Err() == nil || next.offset < len(session.results)
+	if page.Complete && next.end > session.wanted {
+		session.wanted = next.end
+	}
+	session.mu.Unlock()
+
+	go session.MoreResults(server)
+	return page, nil
+}
+
+// MoreResults queues the next matches of the query for their metadata,
+// until they would fill the results or run out. Matches join the results
+// as their metadata arrives.
+func (session *SearchSession) MoreResults(server *Server) {
+	for {
+		ctx, chunkid, ok := session.nextMatch()
+		if !ok {
+			return
+		}
+
+		chunk, err := session.manager.w64storage.GetChunkById(chunkid)
+		if err != nil {
+			log.Printf("reading search item: %v", err)
+			session.SkipMatch(ctx)
+			continue
+		}
+
+		item, err := catalog.DecodeItem(chunk)
+		if err != nil || item.Name == "" {
+			session.SkipMatch(ctx)
+			continue
+		}
+
+		channel, ok := PublisherAlias(item)
+		if !ok {
+			session.SkipMatch(ctx)
+			continue
+		}
+
+		result := ItemType{
+			Name:        item.Name,
+			Description: item.Description,
+			Magnet:      item.Magnet,
+			Channel:     channel,
+			Details:     ReleaseDetails(release.Parse(item.Name)),
+		}
+		if !server.queueMetadataJob(metadataJob{ctx, session, result}) {
+			return
+		}
+	}
+}
+
+// nextMatch returns the chunk ID of the next match to look at, with the
+// context of the query, reporting false once the results are full, the
+// matches run out or the search is over. The match is pending until
+// AddResult or SkipMatch is called for it.
+func (session *SearchSession) nextMatch() (context.Context, int, bool) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.search.Err() != nil || session.query == "" || session.full() || session.next >= len(session.matches) {
+		return nil, 0, false
+	}
+	chunkid := session.matches[session.next]
+	session.next++
+	session.pending++
+	return session.search, chunkid, true
+}
+
+// full reports whether the results, counting the pending ones, are as
+// many as wanted.
+func (session *SearchSession) full() bool {
+	return len(session.results)+session.pending >= session.wanted
+}
+
+// SkipMatch gives up a pending match of the search of ctx, leaving room
+// for another.
+func (session *SearchSession) SkipMatch(ctx context.Context) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if ctx == session.search {
+		session.pending--
+		session.skipped++
+	}
+}
+
+// AddResult appends item, a pending match, to the results, unless ctx,
+// the context of the search item was found by, is done. It reports whether
+// it did.
+func (session *SearchSession) AddResult(ctx context.Context, item ItemType) bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if ctx != session.search || ctx.Err() != nil {
+		return false
+	}
+	session.pending--
+	session.results = append(session.results, item)
+	return true
+}
+
+// AddPreview records that the session previews the torrent of magnet,
+// unless ctx, the context of the search it was found by, is done. It
+// reports whether it did.
+func (session *SearchSession) AddPreview(ctx context.Context, magnet string) bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if ctx.Err() != nil {
+		return false
+	}
+	session.previews = append(session.previews, magnet)
+	return true
+}
+
+// RemovePreview records that the session no longer previews the torrent of
+// magnet.
+func (session *SearchSession) RemovePreview(magnet string) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for i, tmpe := range session.previews {
+		if SameTorrent(tmpe, magnet) {
+			session.previews = append(session.previews[:i], session.previews[i+1:]...)
+			return
+		}
+	}
+}
+
+// IsPreviewing reports whether the session previews the torrent of magnet.
+func (session *SearchSession) IsPreviewing(magnet string) bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for _, tmpe := range session.previews {
+		if SameTorrent(tmpe, magnet) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// addTestItems adds items to the live catalog of s and indexes them.
+func addTestItems(t *testing.T, s *Server, items []catalog.Item) {
+	t.Helper()
+
+	for _, item := range items {
+		b, err := catalog.EncodeItem(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.w64storage.AddChunk(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.UpdateSearchIndex()
+}
+
+// namedItems returns n items named after prefix, with info hashes from
+// first on.
+func namedItems(prefix string, first, n int) []catalog.Item {
+	items := make([]catalog.Item, n)
+	for i := range items {
+		items[i] = catalog.Item{
+			Name:   fmt.Sprintf("%s.%d.1080p.x264-GRP", prefix, i),
+			Magnet: fmt.Sprintf("magnet:?xt=urn:btih:%040x", first+i),
+		}
+	}
+	return items
+}
+
+// serveMetadataJobs stands in for the metadata workers of s: it previews
+// the torrent of each match and adds the match to its session at once, as
+// addtorrent does when the metadata arrives.
+func serveMetadataJobs(t *testing.T, s *Server) {
+	s.metadataJobs = make(chan metadataJob)
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+
+	go func() {
+		for {
+			select {
+			case job := <-s.metadataJobs:
+				if !job.session.AddPreview(job.ctx, job.result.Magnet) {
+					continue
+				}
+				if !job.session.AddResult(job.ctx, job.result) {
+					job.session.RemovePreview(job.result.Magnet)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
+
+// waitPage asks session for the page of cursor until no more results can
+// join it.
+func waitPage(t *testing.T, s *Server, session *SearchSession, cursor string) SearchPage {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		page, err := session.Page(s, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Complete || !page.More {
+			return page
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("page %s still filling: %+v", cursor, page)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// TestSearchSessionsIndependent runs a query in each of two sessions of
+// one server and checks that neither sees the results or the previews of
+// the other, before and after one of them closes.
+func TestSearchSessionsIndependent(t *testing.T) {
+	s := newTestServer(t, nil)
+	serveMetadataJobs(t, s)
+	alpha, beta := namedItems("Alpha.Movie", 0x100, 5), namedItems("Beta.Show", 0x200, 7)
+	addTestItems(t, s, append(append([]catalog.Item(nil), alpha...), beta...))
+
+	a, b := s.NewSearchSession(), s.NewSearchSession()
+	defer b.Close()
+	cursorA, err := a.SetQuery(s, "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursorB, err := b.SetQuery(s, "beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name           string
+		session, other *SearchSession
+		cursor         string
+		items          []catalog.Item
+		prefix         string
+	}{
+		{"a", a, b, cursorA, alpha, "Alpha.Movie."},
+		{"b", b, a, cursorB, beta, "Beta.Show."},
+	} {
+		page := waitPage(t, s, test.session, test.cursor)
+		if len(page.Results) != len(test.items) || page.Hits != len(test.items) || !page.Exact {
+			t.Errorf("session %s: %d results of %d hits, exact %v; want %d", test.name, len(page.Results), page.Hits, page.Exact, len(test.items))
+		}
+		for _, result := range page.Results {
+			if !strings.HasPrefix(result.Name, test.prefix) {
+				t.Errorf("session %s got %q", test.name, result.Name)
+			}
+		}
+		for _, item := range test.items {
+			if !test.session.IsPreviewing(item.Magnet) {
+				t.Errorf("session %s does not preview %s", test.name, item.Name)
+			}
+			if test.other.IsPreviewing(item.Magnet) {
+				t.Errorf("%s previewed by the other session", item.Name)
+			}
+		}
+	}
+
+	a.Close()
+	s.sessionsMu.Lock()
+	_, open := s.sessions[a]
+	sessions := len(s.sessions)
+	s.sessionsMu.Unlock()
+	if open || sessions != 1 {
+		t.Errorf("closed session still listed, %d sessions", sessions)
+	}
+	for _, item := range alpha {
+		if s.IsPreviewingTorrent(item.Magnet) {
+			t.Errorf("%s still previewed after its session closed", item.Name)
+		}
+	}
+	for _, item := range beta {
+		if !s.IsPreviewingTorrent(item.Magnet) {
+			t.Errorf("%s no longer previewed after the other session closed", item.Name)
+		}
+	}
+	if page := waitPage(t, s, b, cursorB); len(page.Results) != len(beta) {
+		t.Errorf("session b has %d results after a closed, want %d", len(page.Results), len(beta))
+	}
+}
+
+// receiveJob returns the next job queued by s.
+func receiveJob(t *testing.T, s *Server) metadataJob {
+	t.Helper()
+
+	select {
+	case job := <-s.metadataJobs:
+		return job
+	case <-time.After(10 * time.Second):
+		t.Fatal("no match queued for its metadata")
+		return metadataJob{}
+	}
+}
+
+// counts returns the results and the pending and skipped matches of
+// session.
+func (session *SearchSession) counts() (results, pending, skipped int) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return len(session.results), session.pending, session.skipped
+}
+
+// TestSetQueryCancelsSearch checks that a new query cancels the search of
+// the previous one, whose late matches then leave the new query alone,
+// and that closing the session cancels the rest.
+func TestSetQueryCancelsSearch(t *testing.T) {
+	saved := Settings.SearchPageSize
+	defer func() { Settings.SearchPageSize = saved }()
+	// With pages of one result, a single match is pending at a time.
+	Settings.SearchPageSize = 1
+
+	s := newTestServer(t, nil)
+	s.metadataJobs = make(chan metadataJob)
+	addTestItems(t, s, append(namedItems("Alpha.Movie", 0x100, 3), namedItems("Beta.Show", 0x200, 3)...))
+
+	session := s.NewSearchSession()
+	defer session.Close()
+	if _, err := session.SetQuery(s, "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	stale := receiveJob(t, s)
+
+	if _, err := session.SetQuery(s, "beta"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stale.ctx.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("first query not cancelled by the second")
+	}
+	job := receiveJob(t, s)
+	if job.ctx == stale.ctx || !strings.HasPrefix(job.result.Name, "Beta.Show.") {
+		t.Fatalf("queued %q under the context of the first query", job.result.Name)
+	}
+	if results, pending, skipped := session.counts(); results != 0 || pending != 1 || skipped != 0 {
+		t.Fatalf("%d results, %d pending, %d skipped; want 0, 1, 0", results, pending, skipped)
+	}
+
+	// The match of the first query comes back late.
+	if session.AddResult(stale.ctx, stale.result) {
+		t.Error("result of the first query added")
+	}
+	session.SkipMatch(stale.ctx)
+	if session.AddPreview(stale.ctx, stale.result.Magnet) {
+		t.Error("preview of the first query added")
+	}
+	if results, pending, skipped := session.counts(); results != 0 || pending != 1 || skipped != 0 {
+		t.Errorf("after the first query's match: %d results, %d pending, %d skipped; want 0, 1, 0", results, pending, skipped)
+	}
+
+	if !session.AddResult(job.ctx, job.result) {
+		t.Fatal("result of the current query not added")
+	}
+	if results, pending, _ := session.counts(); results != 1 || pending != 0 {
+		t.Errorf("%d results, %d pending; want 1, 0", results, pending)
+	}
+
+	session.Close()
+	if session.ctx.Err() == nil || job.ctx.Err() == nil {
+		t.Errorf("closing the session left its contexts running: %v, %v", session.ctx.Err(), job.ctx.Err())
+	}
+	if session.AddResult(job.ctx, job.result) {
+		t.Error("result added to a closed session")
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	for _, c := range []searchCursor{
+		{0, 0, 0},
+		{1, 0, 12},
+		{35, 36, 1000},
+		{1<<31 - 1, 5, 1<<31 - 1},
+	} {
+		got, err := parseSearchCursor(c.String())
+		if err != nil || got != c {
+			t.Errorf("%+v read back from %q as %+v, %v", c, c.String(), got, err)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"1.0",
+		"1.0.c.0",
+		"1..c",
+		"!.0.c",
+		"-1.0.c",
+		"1.-1.c",
+		"1.d.c", // offset past the end
+		"zzzzzzzzzz.0.c",
+		"1.0.c ",
+	} {
+		if c, err := parseSearchCursor(s); err == nil {
+			t.Errorf("%q read as %+v", s, c)
+		}
+	}
+}
+
+func TestSearchPageSize(t *testing.T) {
+	saved := Settings.SearchPageSize
+	defer func() { Settings.SearchPageSize = saved }()
+
+	s := newTestServer(t, nil)
+	session := s.NewSearchSession()
+	defer session.Close()
+	for _, test := range []struct {
+		setting, want int
+	}{
+		{0, defaultSearchPageSize},
+		{-1, defaultSearchPageSize},
+		{1, 1},
+		{50, 50},
+	} {
+		Settings.SearchPageSize = test.setting
+		if got := SearchPageSize(); got != test.want {
+			t.Errorf("setting %d: pages of %d, want %d", test.setting, got, test.want)
+		}
+		cursor, err := session.SetQuery(s, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c, err := parseSearchCursor(cursor); err != nil || c.offset != 0 || c.end != test.want {
+			t.Errorf("setting %d: first cursor %+v, %v", test.setting, c, err)
+		}
+	}
+}
+
+// TestSearchPages reads the results of a query page by page.
+func TestSearchPages(t *testing.T) {
+	saved := Settings.SearchPageSize
+	defer func() { Settings.SearchPageSize = saved }()
+	Settings.SearchPageSize = 3
+
+	s := newTestServer(t, nil)
+	serveMetadataJobs(t, s)
+	items := namedItems("Paged.Movie", 0x100, 7)
+	addTestItems(t, s, items)
+
+	session := s.NewSearchSession()
+	defer session.Close()
+	first, err := session.SetQuery(s, "paged movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseSearchCursor(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := func(offset, end int) string {
+		return searchCursor{c.generation, offset, end}.String()
+	}
+
+	var names []string
+	for _, test := range []struct {
+		cursor         string
+		results        int
+		next           string
+		complete, more bool
+		exact          bool
+	}{
+		{cursor(0, 3), 3, cursor(3, 6), true, true, false},
+		{cursor(3, 6), 3, cursor(6, 9), true, true, false},
+		// The last page is not complete, and only ends the results
+		// once every match has been looked at.
+		{cursor(6, 9), 1, cursor(7, 9), false, false, true},
+		{cursor(7, 9), 0, cursor(7, 9), false, false, true},
+		// Pages may be asked for again.
+		{cursor(1, 3), 2, cursor(3, 6), true, true, true},
+	} {
+		page := waitPage(t, s, session, test.cursor)
+		if len(page.Results) != test.results || page.Cursor != test.cursor || page.Next != test.next ||
+			page.Complete != test.complete || page.More != test.more || page.Exact != test.exact || page.Hits != len(items) {
+			t.Errorf("page %s: %d results, cursor %s, next %s, complete %v, more %v, exact %v, %d hits; want %d results, next %s, complete %v, more %v, exact %v",
+				test.cursor, len(page.Results), page.Cursor, page.Next, page.Complete, page.More, page.Exact, page.Hits,
+				test.results, test.next, test.complete, test.more, test.exact)
+		}
+		if test.cursor != cursor(1, 3) {
+			for _, result := range page.Results {
+				names = append(names, result.Name)
+			}
+		}
+	}
+	if len(names) != len(items) {
+		t.Errorf("pages held %v", names)
+	}
+	for i, name := range names {
+		for _, other := range names[:i] {
+			if name == other {
+				t.Errorf("%s on two pages", name)
+			}
+		}
+	}
+
+	// Cursors only hold for the query they were handed out for.
+	if _, err := session.SetQuery(s, "paged"); err != nil {
+		t.Fatal(err)
+	}
+	for _, expired := range []string{cursor(0, 3), cursor(6, 9), searchCursor{c.generation + 5, 0, 3}.String()} {
+		if _, err := session.Page(s, expired); !errors.Is(err, ErrExpiredCursor) {
+			t.Errorf("page %s after a new query: got %v, want ErrExpiredCursor", expired, err)
+		}
+	}
+	if _, err := session.Page(s, "not a cursor"); err == nil || errors.Is(err, ErrExpiredCursor) {
+		t.Errorf("malformed cursor: got %v", err)
+	}
+}
+
+// TestSearchPagePending asks for a page while its matches wait for their
+// metadata.
+func TestSearchPagePending(t *testing.T) {
+	saved := Settings.SearchPageSize
+	defer func() { Settings.SearchPageSize = saved }()
+	Settings.SearchPageSize = 3
+
+	s := newTestServer(t, nil)
+	s.metadataJobs = make(chan metadataJob) // no worker takes the jobs
+	addTestItems(t, s, namedItems("Paged.Movie", 0x100, 7))
+
+	session := s.NewSearchSession()
+	defer session.Close()
+	first, err := session.SetQuery(s, "paged movie")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := session.Page(s, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 0 || page.Next != first || page.Complete || !page.More || page.Exact || page.Hits != 7 {
+		t.Errorf("pending page: %+v", page)
+	}
+}
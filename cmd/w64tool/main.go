@@ -0,0 +1,574 @@
+// Command w64tool inspects, verifies and repairs a w64system catalog.
+//
+// Usage:
+//
+//	w64tool stat   [-dir dir] [-prefix prefix]
+//	w64tool dump   [-dir dir] [-prefix prefix]
+//	w64tool verify [-dir dir] [-prefix prefix]
+//	w64tool repair [-dir dir] [-prefix prefix]
+//	w64tool import [-dir dir] [-prefix prefix] [-key keyfile] file.jsonl|file.csv...
+//	w64tool merge  [-dir dir] [-prefix prefix] [-policy policy] [-report file] otherdir outdir
+//	w64tool search [-dir dir] [-prefix prefix] [-bench] query...
+//	w64tool compress [-dir dir] [-prefix prefix] [-dict size] [-samples n] outdir
+//	w64tool keygen keyfile
+//
+// stat, dump, verify, merge, search and compress open the catalog read-only
+// and never modify it.
+// import appends the items of JSONL files, in the format written by dump, or
+// of CSV files with a header row, skipping items already in the catalog and
+// signing them with the publisher key in keyfile if given. keygen writes a
+// new publisher key to keyfile and prints its public half, to be listed in
+// the TrustedPublishers of Settings.json. merge combines the catalog with the
+// one of the same prefix in otherdir into a new catalog in outdir, choosing
+// between items sharing an info hash by policy (newest, longest or
+// prefer-a), and writes a JSON report of what it did to file, or to stdout.
+// search runs a query, in the syntax of search.ParseQuery, against the
+// search index, built in memory; with -bench it also times the index
+// against a linear scan of the catalog. compress copies the catalog to a new
+// one of the same prefix in outdir with every chunk compressed, using a
+// dictionary of up to size bytes trained on n chunks sampled from the
+// catalog, or none if size is 0; legacy segments come out in the current
+// format and deleted chunks are left out.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+	"github.com/wetorrent/wetorrent/internal/search"
+)
+
+var commands = map[string]func(storage *chunk_storage.ChunkStorage, args []string) error{
+	"stat":     stat,
+	"dump":     dump,
+	"verify":   verify,
+	"repair":   repair,
+	"import":   importItems,
+	"merge":    merge,
+	"search":   searchItems,
+	"compress": compress,
+}
+
+// signingKey signs imported items when set with -key.
+var signingKey ed25519.PrivateKey
+
+// Settings of merge, from -prefix, -policy and -report.
+var (
+	mergePrefix string
+	mergePolicy catalog.MergePolicy
+	reportFile  string
+)
+
+// bench makes search compare the index with a linear scan.
+var bench bool
+
+// Settings of compress, from -dict and -samples.
+var (
+	dictSize    int
+	sampleCount int
+)
+
+// writers lists the commands that modify the catalog.
+var writers = map[string]bool{"repair": true, "import": true}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) == 3 && os.Args[1] == "keygen" {
+		if err := keygen(os.Args[2]); err != nil {
+			log.Fatalf("keygen: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: w64tool stat|dump|verify|repair|import|merge|search|compress [-dir dir] [-prefix prefix] [flags] [args...]")
+		fmt.Fprintln(os.Stderr, "       w64tool keygen keyfile")
+		os.Exit(2)
+	}
+	name := os.Args[1]
+
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	storageDir := flags.String("dir", path.Join("internal", "w64system"), "directory holding the catalog segments")
+	fileNamePrefix := flags.String("prefix", "w64system", "file name prefix of the catalog segments")
+	keyFile := flags.String("key", "", "import: file holding the publisher key to sign imported items with")
+	policy := flags.String("policy", string(catalog.MergeNewest), "merge: item kept on conflicts, newest, longest or prefer-a")
+	flags.StringVar(&reportFile, "report", "", "merge: file to write the JSON report to, stdout if empty")
+	flags.BoolVar(&bench, "bench", false, "search: time the search index against a linear scan")
+	flags.IntVar(&dictSize, "dict", 16*1024, "compress: size of the trained compression dictionary in bytes, 0 for none")
+	flags.IntVar(&sampleCount, "samples", 10000, "compress: number of chunks sampled to train the dictionary")
+	_ = flags.Parse(os.Args[2:])
+
+	mergePrefix = *fileNamePrefix
+	var err error
+	if mergePolicy, err = catalog.ParseMergePolicy(*policy); err != nil {
+		log.Fatal(err)
+	}
+
+	if *keyFile != "" {
+		key, err := readKey(*keyFile)
+		if err != nil {
+			log.Fatalf("reading publisher key: %v", err)
+		}
+		signingKey = key
+	}
+
+	options := chunk_storage.Options{ReadOnly: !writers[name], InfoHashFunc: catalog.ChunkInfoHash}
+	storage, err := chunk_storage.New(*storageDir, *fileNamePrefix, options)
+	if err != nil {
+		log.Fatalf("opening catalog: %v", err)
+	}
+	defer storage.Close()
+
+	if err := commands[name](storage, flags.Args()); err != nil {
+		storage.Close()
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+func stat(storage *chunk_storage.ChunkStorage, _ []string) error {
+	var chunks, deleted int
+	var size int64
+
+	for _, segment := range storage.Segments() {
+		format := "v2"
+		if segment.Legacy {
+			format = "legacy"
+		}
+		fmt.Printf("%s\t%s\t%d bytes\t%d chunks\t%d deleted", segment.Path, format, segment.Size, segment.Chunks, segment.Deleted)
+		if segment.Dictionary > 0 {
+			fmt.Printf("\tdictionary of %d bytes", segment.Dictionary)
+		}
+		fmt.Println()
+
+		chunks += segment.Chunks
+		deleted += segment.Deleted
+		size += segment.Size
+	}
+
+	for _, recovery := range storage.Recovered() {
+		fmt.Println(recovery)
+	}
+	fmt.Printf("total\t%d bytes\t%d chunks\t%d deleted\n", size, chunks, deleted)
+	return nil
+}
+
+// dumpLine is one line of dump output: the decoded item, or the reason it
+// could not be decoded.
+type dumpLine struct {
+	ID int `json:"id"`
+	*catalog.Item
+	Error string `json:"error,omitempty"`
+}
+
+func dump(storage *chunk_storage.ChunkStorage, _ []string) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+
+	for chunkid, n := 0, storage.NumberOfChunks(); chunkid < n; chunkid++ {
+		line := dumpLine{ID: chunkid}
+
+		item, err := readItem(storage, chunkid)
+		if errors.Is(err, chunk_storage.ErrDeleted) {
+			continue
+		} else if err != nil {
+			line.Error = err.Error()
+		} else {
+			line.Item = &item
+		}
+
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verify(storage *chunk_storage.ChunkStorage, _ []string) error {
+	problems := 0
+	for _, recovery := range storage.Recovered() {
+		fmt.Println(recovery)
+		problems++
+	}
+
+	for chunkid, n := 0, storage.NumberOfChunks(); chunkid < n; chunkid++ {
+		if err := checkItem(storage, chunkid); err != nil && !errors.Is(err, chunk_storage.ErrDeleted) {
+			fmt.Printf("chunk %d: %v\n", chunkid, err)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found, run w64tool repair to fix them", problems)
+	}
+	fmt.Printf("%d chunks ok\n", storage.NumberOfChunks())
+	return nil
+}
+
+// repair relies on opening the catalog writable to truncate broken tails,
+// moving damaged ones to quarantine files, then deletes every chunk that
+// does not hold a valid item and compacts the catalog to drop them. Legacy
+// segments are never truncated, so their broken tails are saved to
+// quarantine files here and compaction rewrites the segments without them.
+func repair(storage *chunk_storage.ChunkStorage, _ []string) error {
+	legacyTails := 0
+	for _, recovery := range storage.Recovered() {
+		fmt.Println(recovery)
+		if recovery.Truncated {
+			continue
+		}
+		quarantine, err := quarantineTail(recovery)
+		if err != nil {
+			return fmt.Errorf("saving the tail of '%s': %v", recovery.Path, err)
+		}
+		fmt.Printf("%s: saved %d bytes at offset %d to '%s'\n", recovery.Path, recovery.Dropped, recovery.Offset, quarantine)
+		legacyTails++
+	}
+
+	dropped := 0
+	for chunkid, n := 0, storage.NumberOfChunks(); chunkid < n; chunkid++ {
+		err := checkItem(storage, chunkid)
+		if err == nil || errors.Is(err, chunk_storage.ErrDeleted) {
+			continue
+		}
+
+		fmt.Printf("dropping chunk %d: %v\n", chunkid, err)
+		if err := storage.DeleteChunk(chunkid); err != nil {
+			return fmt.Errorf("deleting chunk %d: %v", chunkid, err)
+		}
+		dropped++
+	}
+
+	if dropped > 0 || legacyTails > 0 {
+		if err := storage.Compact(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("%d chunks dropped, %d chunks left\n", dropped, storage.NumberOfChunks())
+	return nil
+}
+
+// quarantineTail copies the bytes recovery left out of the index to a new
+// "<segment>.N.quarantine" file next to the segment, as the storage does
+// for the versioned segments it truncates, and returns its path.
+func quarantineTail(recovery chunk_storage.Recovery) (string, error) {
+	f, err := os.Open(recovery.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	q, err := os.CreateTemp(path.Dir(recovery.Path), path.Base(recovery.Path)+".*.quarantine")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(q, io.NewSectionReader(f, recovery.Offset, recovery.Dropped))
+	if err == nil {
+		err = q.Sync()
+	}
+	if closeErr := q.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(q.Name())
+		return "", err
+	}
+	return q.Name(), nil
+}
+
+func importItems(storage *chunk_storage.ChunkStorage, files []string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no file to import")
+	}
+
+	importer, err := catalog.NewImporter(storage)
+	if err != nil {
+		return err
+	}
+	importer.Key = signingKey
+
+	for _, file := range files {
+		if err := importFile(importer, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importFile(importer *catalog.Importer, file string) error {
+	var read func(io.Reader, func(int, catalog.Item, error) error) error
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".jsonl", ".ndjson", ".json":
+		read = catalog.ReadJSONL
+	case ".csv":
+		read = catalog.ReadCSV
+	default:
+		return fmt.Errorf("%s: unknown format, expected .jsonl or .csv", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var added, duplicates, rejected int
+	err = read(f, func(line int, item catalog.Item, err error) error {
+		if err == nil {
+			err = importer.Add(item)
+			if err != nil && !errors.Is(err, catalog.ErrDuplicate) &&
+				!errors.Is(err, catalog.ErrInvalidMagnet) && !errors.Is(err, catalog.ErrFieldTooLong) &&
+				!errors.Is(err, catalog.ErrBadSignature) {
+				return err
+			}
+		}
+
+		switch {
+		case err == nil:
+			added++
+		case errors.Is(err, catalog.ErrDuplicate):
+			duplicates++
+		default:
+			fmt.Printf("%s:%d: %v\n", file, line, err)
+			rejected++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+
+	fmt.Printf("%s: %d added, %d duplicates, %d rejected\n", file, added, duplicates, rejected)
+	return nil
+}
+
+func merge(storage *chunk_storage.ChunkStorage, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected the directories of the other catalog and of the output")
+	}
+
+	other, err := chunk_storage.New(args[0], mergePrefix, chunk_storage.Options{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", args[0], err)
+	}
+	defer other.Close()
+
+	out, err := chunk_storage.New(args[1], mergePrefix, chunk_storage.Options{InfoHashFunc: catalog.ChunkInfoHash})
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", args[1], err)
+	}
+	defer out.Close()
+
+	report, err := catalog.Merge(storage, other, out, mergePolicy)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if reportFile == "" {
+		_, err = os.Stdout.Write(b)
+	} else {
+		err = os.WriteFile(reportFile, b, 0644)
+		fmt.Printf("%d merged, %d skipped, %d conflicts\n", len(report.Merged), len(report.Skipped), len(report.Conflicts))
+	}
+	return err
+}
+
+func searchItems(storage *chunk_storage.ChunkStorage, args []string) error {
+	query := strings.Join(args, " ")
+	if query == "" {
+		return fmt.Errorf("no query")
+	}
+	parsed, err := search.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("parsing query: %v", err)
+	}
+
+	start := time.Now()
+	index, err := search.Open(storage)
+	if err != nil {
+		return err
+	}
+	built := time.Since(start)
+
+	start = time.Now()
+	matches := index.Rank(parsed)
+	searched := time.Since(start)
+
+	for i, match := range matches {
+		if i == 20 {
+			fmt.Printf("... %d more\n", len(matches)-i)
+			break
+		}
+		if item, err := readItem(storage, match.ID); err == nil {
+			fmt.Printf("%d\t%.2f\t%s\n", match.ID, match.Score, item.Name)
+		}
+	}
+
+	if !bench {
+		return nil
+	}
+
+	// The scan is the substring match the search index replaced.
+	start = time.Now()
+	scanned := 0
+	lowerQuery := strings.ToLower(query)
+	for it := storage.Scan(0, -1, true); it.Next(); {
+		item, err := catalog.DecodeItem(it.Bytes())
+		if err == nil && strings.Contains(strings.ToLower(item.Name), lowerQuery) {
+			scanned++
+		}
+	}
+	scan := time.Since(start)
+
+	fmt.Printf("index: built in %v, %d matches in %v\n", built, len(matches), searched)
+	fmt.Printf("scan:  %d matches in %v\n", scanned, scan)
+	return nil
+}
+
+func compress(storage *chunk_storage.ChunkStorage, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected the directory of the output")
+	}
+
+	var dict []byte
+	if dictSize > 0 {
+		dict = chunk_storage.TrainDictionary(sampleChunks(storage, sampleCount), dictSize)
+	}
+
+	options := chunk_storage.Options{Compress: true, Dictionary: dict, InfoHashFunc: catalog.ChunkInfoHash}
+	out, err := chunk_storage.New(args[0], mergePrefix, options)
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", args[0], err)
+	}
+	defer out.Close()
+	if out.NumberOfChunks() > 0 {
+		return fmt.Errorf("%s already holds a catalog", args[0])
+	}
+
+	for chunkid, n := 0, storage.NumberOfChunks(); chunkid < n; chunkid++ {
+		chunk, err := storage.GetChunkById(chunkid)
+		if errors.Is(err, chunk_storage.ErrDeleted) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("chunk %d: %v, run w64tool repair first", chunkid, err)
+		}
+		if err := out.AddChunk(chunk); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("%d chunks, dictionary of %d bytes\n", out.NumberOfChunks(), len(dict))
+	fmt.Printf("%d bytes -> %d bytes\n", segmentsSize(storage), segmentsSize(out))
+	return nil
+}
+
+// sampleChunks reads up to n chunks spread evenly over the storage.
+func sampleChunks(storage *chunk_storage.ChunkStorage, n int) [][]byte {
+	total := storage.NumberOfChunks()
+	if n <= 0 || total == 0 {
+		return nil
+	}
+
+	step := total / n
+	if step < 1 {
+		step = 1
+	}
+
+	var samples [][]byte
+	for chunkid := 0; chunkid < total && len(samples) < n; chunkid += step {
+		if chunk, err := storage.GetChunkById(chunkid); err == nil {
+			samples = append(samples, chunk)
+		}
+	}
+	return samples
+}
+
+func segmentsSize(storage *chunk_storage.ChunkStorage) int64 {
+	var total int64
+	for _, segment := range storage.Segments() {
+		total += segment.Size
+	}
+	return total
+}
+
+func readItem(storage *chunk_storage.ChunkStorage, chunkid int) (catalog.Item, error) {
+	chunk, err := storage.GetChunkById(chunkid)
+	if err != nil {
+		return catalog.Item{}, err
+	}
+	return catalog.DecodeItem(chunk)
+}
+
+// checkItem reports why a chunk does not hold a usable item.
+func checkItem(storage *chunk_storage.ChunkStorage, chunkid int) error {
+	item, err := readItem(storage, chunkid)
+	if err != nil {
+		return err
+	}
+	if _, err := item.InfoHash(); err != nil {
+		return err
+	}
+	if err := item.Verify(); err != nil && !errors.Is(err, catalog.ErrUnsigned) {
+		return err
+	}
+	return nil
+}
+
+// keygen writes a new publisher key to keyFile, as the base64 encoded seed
+// of an ed25519 key, and prints the public key.
+func keygen(keyFile string) error {
+	publicKey, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return err
+	}
+
+	seed := base64.StdEncoding.EncodeToString(key.Seed())
+	f, err := os.OpenFile(keyFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, seed); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Println(base64.StdEncoding.EncodeToString(publicKey))
+	return nil
+}
+
+func readKey(keyFile string) (ed25519.PrivateKey, error) {
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key of %d bytes, expected %d", len(seed), ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// testItem returns an item with a name and info hash derived from i.
+func testItem(i int) catalog.Item {
+	return catalog.Item{
+		Name:   fmt.Sprintf("Movie.%d.1080p", i),
+		Magnet: fmt.Sprintf("magnet:?xt=urn:btih:%040x", i+1),
+	}
+}
+
+// writeDamagedCatalog writes the catalog "w" to dir: a legacy segment with
+// items 0 to 2, a chunk that is no item and a torn tail, then a versioned
+// segment with items 3 and 4, the first of them corrupt. It returns the
+// torn tail.
+func writeDamagedCatalog(t *testing.T, dir string) []byte {
+	t.Helper()
+
+	var legacy []byte
+	appendRecord := func(payload []byte) {
+		sizeWord := make([]byte, 4)
+		binary.LittleEndian.PutUint32(sizeWord, uint32(len(payload)))
+		legacy = append(append(legacy, sizeWord...), payload...)
+	}
+	for i := 0; i < 3; i++ {
+		b, err := catalog.EncodeLegacyItem(testItem(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		appendRecord(b)
+	}
+	appendRecord([]byte("not an item"))
+	tail := []byte{100, 0, 0, 0, 'c', 'u', 't', ' ', 's', 'h', 'o', 'r', 't'}
+	legacy = append(legacy, tail...)
+	if err := os.WriteFile(path.Join(dir, "w000"), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	storage, err := chunk_storage.New(dir, "w", chunk_storage.Options{InfoHashFunc: catalog.ChunkInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer, err := catalog.NewImporter(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i < 5; i++ {
+		if err := importer.Add(testItem(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a byte of the payload of the first record after the header.
+	segment := path.Join(dir, "w001")
+	content, err := os.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content[8+8+2] ^= 1
+	if err := os.WriteFile(segment, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tail
+}
+
+// run runs command name with args on the catalog "w" in dir, opened as
+// main opens it, and returns what it printed.
+func run(t *testing.T, dir, name string, args ...string) (string, error) {
+	t.Helper()
+
+	options := chunk_storage.Options{ReadOnly: !writers[name], InfoHashFunc: catalog.ChunkInfoHash}
+	storage, err := chunk_storage.New(dir, "w", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout := os.Stdout
+	os.Stdout = out
+	err = commands[name](storage, args)
+	os.Stdout = stdout
+
+	printed, readErr := os.ReadFile(out.Name())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(printed), err
+}
+
+// catalogFiles returns the content of the files of dir, by name.
+func catalogFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		content, err := os.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
+
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	writeDamagedCatalog(t, dir)
+	before := catalogFiles(t, dir)
+
+	out, err := run(t, dir, "stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"w000\tlegacy\t", "w001\tv2\t", "ignored 13 trailing bytes", "total\t"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stat printed no %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out, "\t6 chunks\t0 deleted\n") {
+		t.Errorf("stat did not count 6 chunks:\n%s", out)
+	}
+
+	out, err = run(t, dir, "verify")
+	if err == nil || !strings.Contains(err.Error(), "3 problems found") {
+		t.Errorf("verify returned %v, want 3 problems", err)
+	}
+	for _, want := range []string{"ignored 13 trailing bytes", "chunk 3: ", "chunk 4: chunk ID 4: " + chunk_storage.ErrChecksum.Error()} {
+		if !strings.Contains(out, want) {
+			t.Errorf("verify printed no %q:\n%s", want, out)
+		}
+	}
+
+	if after := catalogFiles(t, dir); len(after) != len(before) {
+		t.Errorf("read-only commands left files %v", after)
+	} else {
+		for name, content := range before {
+			if after[name] != content {
+				t.Errorf("read-only commands modified %s", name)
+			}
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	dir := t.TempDir()
+	tail := writeDamagedCatalog(t, dir)
+
+	out, err := run(t, dir, "repair")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"saved 13 bytes at offset", "dropping chunk 3: ", "dropping chunk 4: ", "2 chunks dropped, 4 chunks left"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("repair printed no %q:\n%s", want, out)
+		}
+	}
+
+	quarantines, err := filepath.Glob(path.Join(dir, "w000.*.quarantine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarantines) != 1 {
+		t.Fatalf("quarantine files %v, want one for the legacy tail", quarantines)
+	}
+	if content, err := os.ReadFile(quarantines[0]); err != nil || !bytes.Equal(content, tail) {
+		t.Errorf("quarantined %q, want the legacy tail %q", content, tail)
+	}
+
+	// The catalog is sound and in the current format now.
+	out, err = run(t, dir, "verify")
+	if err != nil {
+		t.Fatalf("verify after repair: %v\n%s", err, out)
+	}
+	storage, err := chunk_storage.New(dir, "w", chunk_storage.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	for _, segment := range storage.Segments() {
+		if segment.Legacy {
+			t.Errorf("%s still legacy", segment.Path)
+		}
+	}
+	for chunkid, i := range []int{0, 1, 2, 4} {
+		item, err := readItem(storage, chunkid)
+		if err != nil || item.Name != testItem(i).Name {
+			t.Errorf("chunk %d holds %q, %v, want %s", chunkid, item.Name, err, testItem(i).Name)
+		}
+	}
+
+	out, err = run(t, dir, "repair")
+	if err != nil || !strings.Contains(out, "0 chunks dropped, 4 chunks left") {
+		t.Errorf("repairing again: %v\n%s", err, out)
+	}
+}
+
+// TestRepairLegacyTail repairs a catalog whose only damage is the torn tail
+// of a legacy segment, which opening it writable leaves in place.
+func TestRepairLegacyTail(t *testing.T) {
+	dir := t.TempDir()
+	var legacy []byte
+	for i := 0; i < 3; i++ {
+		b, err := catalog.EncodeLegacyItem(testItem(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizeWord := make([]byte, 4)
+		binary.LittleEndian.PutUint32(sizeWord, uint32(len(b)))
+		legacy = append(append(legacy, sizeWord...), b...)
+	}
+	legacy = append(legacy, 100, 0)
+	if err := os.WriteFile(path.Join(dir, "w000"), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run(t, dir, "repair")
+	if err != nil || !strings.Contains(out, "0 chunks dropped, 3 chunks left") {
+		t.Fatalf("repair: %v\n%s", err, out)
+	}
+	if out, err := run(t, dir, "verify"); err != nil {
+		t.Fatalf("verify after repair: %v\n%s", err, out)
+	}
+}
+
+// compressibleItem returns an item with a name and info hash derived from
+// i, with the tracker parameters magnets usually share.
+func compressibleItem(i int) catalog.Item {
+	item := testItem(i)
+	item.Description = fmt.Sprintf("Movie number %d of the collection, remastered", i)
+	item.Magnet += "&tr=udp%3A%2F%2Ftracker.opentrackr.org%3A1337%2Fannounce&tr=udp%3A%2F%2Fopen.stealth.si%3A80%2Fannounce"
+	return item
+}
+
+// writeLegacyCatalog writes the catalog "w" to dir as a legacy segment of
+// items.
+func writeLegacyCatalog(t *testing.T, dir string, items []catalog.Item) {
+	t.Helper()
+
+	var legacy []byte
+	for _, item := range items {
+		b, err := catalog.EncodeLegacyItem(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizeWord := make([]byte, 4)
+		binary.LittleEndian.PutUint32(sizeWord, uint32(len(b)))
+		legacy = append(append(legacy, sizeWord...), b...)
+	}
+	if err := os.WriteFile(path.Join(dir, "w000"), legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkSameChunks checks that storages a and b hold the same chunks.
+func checkSameChunks(t *testing.T, a, b *chunk_storage.ChunkStorage) {
+	t.Helper()
+
+	if a.NumberOfChunks() != b.NumberOfChunks() {
+		t.Fatalf("%d chunks, want %d", b.NumberOfChunks(), a.NumberOfChunks())
+	}
+	for chunkid := 0; chunkid < a.NumberOfChunks(); chunkid++ {
+		want, err := a.GetChunkById(chunkid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := b.GetChunkById(chunkid); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("chunk %d read as %q, %v, want %q", chunkid, got, err, want)
+		}
+	}
+}
+
+// TestCompress migrates a legacy catalog to a compressed one, with and
+// without a dictionary, and checks that the source is left alone and that
+// the copy holds the same chunks in fewer bytes.
+func TestCompress(t *testing.T) {
+	const items = 200
+
+	src := t.TempDir()
+	var all []catalog.Item
+	for i := 0; i < items; i++ {
+		all = append(all, compressibleItem(i))
+	}
+	writeLegacyCatalog(t, src, all)
+	before := catalogFiles(t, src)
+	source, err := chunk_storage.New(src, "w", chunk_storage.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	sourceSize := segmentsSize(source)
+
+	savedDict, savedSamples, savedPrefix := dictSize, sampleCount, mergePrefix
+	defer func() { dictSize, sampleCount, mergePrefix = savedDict, savedSamples, savedPrefix }()
+	mergePrefix, sampleCount = "w", 50
+
+	var sizes []int64
+	for _, dict := range []int{0, 1024} {
+		dictSize = dict
+		dst := t.TempDir()
+		out, err := run(t, src, "compress", dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, fmt.Sprintf("%d chunks, dictionary of ", items)) {
+			t.Errorf("compress printed:\n%s", out)
+		}
+
+		compressed, err := chunk_storage.New(dst, "w", chunk_storage.Options{ReadOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSameChunks(t, source, compressed)
+		segments := compressed.Segments()
+		if len(segments) != 1 || segments[0].Legacy {
+			t.Errorf("dictionary of %d: segments %+v, want one in the current format", dict, segments)
+		} else if dict == 0 && segments[0].Dictionary != 0 || dict > 0 && (segments[0].Dictionary == 0 || segments[0].Dictionary > dict) {
+			t.Errorf("dictionary of %d bytes trained, want up to %d", segments[0].Dictionary, dict)
+		}
+		sizes = append(sizes, segmentsSize(compressed))
+		if err := compressed.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if sizes[1] >= sizes[0] || sizes[1] >= sourceSize {
+		t.Errorf("%d bytes with a dictionary, %d without, %d before", sizes[1], sizes[0], sourceSize)
+	}
+	after := catalogFiles(t, src)
+	for name, content := range before {
+		if after[name] != content {
+			t.Errorf("compress modified %s", name)
+		}
+	}
+	if len(after) != len(before) {
+		t.Errorf("compress left files %v in the source", after)
+	}
+
+	if _, err := run(t, src, "compress", t.TempDir(), "extra"); err == nil {
+		t.Error("compress took two output directories")
+	}
+}
+
+// TestMixedSegments reads a catalog with a legacy segment, followed by
+// compressed and uncompressed records in a versioned segment, and
+// compresses it.
+func TestMixedSegments(t *testing.T) {
+	dir := t.TempDir()
+	var all []catalog.Item
+	for i := 0; i < 10; i++ {
+		all = append(all, compressibleItem(i))
+	}
+	writeLegacyCatalog(t, dir, all[:4])
+
+	for i, options := range []chunk_storage.Options{{Compress: true}, {}} {
+		options.InfoHashFunc = catalog.ChunkInfoHash
+		storage, err := chunk_storage.New(dir, "w", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		importer, err := catalog.NewImporter(storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range all[4+3*i : 7+3*i] {
+			if err := importer.Add(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := storage.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if out, err := run(t, dir, "verify"); err != nil {
+		t.Fatalf("verify: %v\n%s", err, out)
+	}
+	storage, err := chunk_storage.New(dir, "w", chunk_storage.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	if segments := storage.Segments(); len(segments) != 2 || !segments[0].Legacy || segments[1].Legacy {
+		t.Fatalf("segments %+v, want a legacy one then a versioned one", segments)
+	}
+	for chunkid, item := range all {
+		if got, err := readItem(storage, chunkid); err != nil || got.Name != item.Name || got.Magnet != item.Magnet {
+			t.Errorf("chunk %d holds %+v, %v, want %s", chunkid, got, err, item.Name)
+		}
+	}
+
+	savedDict, savedSamples, savedPrefix := dictSize, sampleCount, mergePrefix
+	defer func() { dictSize, sampleCount, mergePrefix = savedDict, savedSamples, savedPrefix }()
+	dictSize, sampleCount, mergePrefix = 1024, 10, "w"
+	dst := t.TempDir()
+	if out, err := run(t, dir, "compress", dst); err != nil {
+		t.Fatalf("compress: %v\n%s", err, out)
+	}
+	compressed, err := chunk_storage.New(dst, "w", chunk_storage.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compressed.Close()
+	checkSameChunks(t, storage, compressed)
+}
@@ -0,0 +1,232 @@
+package catalog
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// ErrDuplicate is returned by Importer.Add for an item whose info hash is
+// already in the storage.
+var ErrDuplicate = errors.New("duplicate info hash")
+
+// Importer appends items to a ChunkStorage, skipping those whose info hash
+// is already stored.
+type Importer struct {
+	storage *chunk_storage.ChunkStorage
+
+	// Key, when set, signs every item added. See Item.Sign.
+	Key ed25519.PrivateKey
+}
+
+// NewImporter returns an Importer adding to storage, which must have been
+// opened with ChunkInfoHash as its InfoHashFunc.
+func NewImporter(storage *chunk_storage.ChunkStorage) (*Importer, error) {
+	if !storage.HasInfoHashIndex() {
+		return nil, errors.New("importing needs a storage with an info hash index")
+	}
+	return &Importer{storage: storage}, nil
+}
+
+// Add appends item to the storage in the current layout. It returns
+// ErrDuplicate if an item with the same info hash was stored before,
+// ErrInvalidMagnet, ErrBadSignature or ErrFieldTooLong if the item cannot be
+// stored, and any other error if writing failed. Items keep the signature
+// they carry unless the Importer has a Key.
+func (im *Importer) Add(item Item) error {
+	hash, err := item.InfoHash()
+	if err != nil {
+		return err
+	}
+	// Spare signing known duplicates; AddChunkIfAbsent checks again.
+	if _, ok := im.storage.LookupByInfoHash(hash); ok {
+		return ErrDuplicate
+	}
+
+	if im.Key != nil {
+		if err := item.Sign(im.Key); err != nil {
+			return err
+		}
+	} else if err := item.Verify(); err != nil && !errors.Is(err, ErrUnsigned) {
+		return err
+	}
+
+	b, err := EncodeItem(item)
+	if err != nil {
+		return err
+	}
+	_, added, err := im.storage.AddChunkIfAbsent(b)
+	if err != nil {
+		return fmt.Errorf("adding item: %v", err)
+	}
+	if !added {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+// ImportStats counts the outcome of importing a batch of items.
+type ImportStats struct {
+	Added      int
+	Duplicates int
+	Rejected   int // unreadable chunks and items Add refused as invalid
+}
+
+// AddFrom adds every item of src that is not in the storage yet. Chunks of
+// src that cannot be read or decoded, and items with an invalid magnet or
+// signature, are counted as rejected; any other error stops the import.
+func (im *Importer) AddFrom(src *chunk_storage.ChunkStorage) (ImportStats, error) {
+	var stats ImportStats
+
+	it := src.Scan(0, -1, false)
+	for {
+		for it.Next() {
+			item, err := DecodeItem(it.Bytes())
+			if err != nil {
+				stats.Rejected++
+				continue
+			}
+
+			switch err := im.Add(item); {
+			case err == nil:
+				stats.Added++
+			case errors.Is(err, ErrDuplicate):
+				stats.Duplicates++
+			case errors.Is(err, ErrInvalidMagnet), errors.Is(err, ErrBadSignature),
+				errors.Is(err, ErrFieldTooLong):
+				stats.Rejected++
+			default:
+				it.Stop()
+				return stats, err
+			}
+		}
+
+		err := it.Err()
+		if err == nil {
+			return stats, nil
+		} else if errors.Is(err, chunk_storage.ErrCompacted) {
+			return stats, fmt.Errorf("reading items: %v", err)
+		}
+		stats.Rejected++
+		it = src.Scan(it.ID()+1, -1, false)
+	}
+}
+
+// maxJSONLine bounds the length of a JSONL line.
+const maxJSONLine = 1 << 20
+
+// ReadJSONL calls fn for each line of r holding a JSON object with the
+// fields of Item. Blank lines are skipped. A line that cannot be parsed is
+// passed to fn with its error instead of stopping the read; reading stops at
+// the first error returned by fn.
+func ReadJSONL(r io.Reader, fn func(line int, item Item, err error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxJSONLine)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var item Item
+		err := json.Unmarshal([]byte(text), &item)
+		if err := fn(line, item, err); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// ReadCSV calls fn for each row of r. The first row names the columns, in
+// any order and case, after the JSON names of the fields of Item; "name" and
+// "magnet" are required and the others optional. Malformed rows are handled
+// as in ReadJSONL.
+func ReadCSV(r io.Reader, fn func(line int, item Item, err error) error) error {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "magnet"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("CSV header has no '%s' column", name)
+		}
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var line int
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		} else if err != nil {
+			return err
+		} else {
+			line, _ = reader.FieldPos(0)
+		}
+
+		var item Item
+		if err == nil {
+			item, err = csvItem(row, columns)
+		}
+
+		if err := fn(line, item, err); err != nil {
+			return err
+		}
+	}
+}
+
+func csvItem(row []string, columns map[string]int) (Item, error) {
+	column := func(name string) string {
+		if i, ok := columns[name]; ok {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	item := Item{
+		Name:        column("name"),
+		Description: column("description"),
+		Magnet:      column("magnet"),
+		Category:    column("category"),
+		Poster:      column("poster"),
+		Channel:     column("channel"),
+	}
+
+	for name, v := range map[string]*int64{"size": &item.Size, "added": &item.Added} {
+		if s := column(name); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return Item{}, fmt.Errorf("column '%s': %v", name, err)
+			}
+			*v = n
+		}
+	}
+	if s := column("file_count"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return Item{}, fmt.Errorf("column 'file_count': %v", err)
+		}
+		item.FileCount = n
+	}
+	return item, nil
+}
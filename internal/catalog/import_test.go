@@ -0,0 +1,374 @@
+package catalog
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// testMagnet returns a magnet URI whose info hash is derived from i.
+func testMagnet(i int) string {
+	return fmt.Sprintf("magnet:?xt=urn:btih:%040x", i+0xabc)
+}
+
+// newTestStorage returns an empty storage, in a temporary directory, that
+// items can be imported into.
+func newTestStorage(t *testing.T) *chunk_storage.ChunkStorage {
+	t.Helper()
+
+	storage, err := chunk_storage.New(t.TempDir(), "w64system", chunk_storage.Options{InfoHashFunc: ChunkInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+// storedItems decodes every item of storage.
+func storedItems(t *testing.T, storage *chunk_storage.ChunkStorage) []Item {
+	t.Helper()
+
+	var items []Item
+	it := storage.Scan(0, -1, false)
+	for it.Next() {
+		item, err := DecodeItem(it.Bytes())
+		if err != nil {
+			t.Fatalf("chunk %d: %v", it.ID(), err)
+		}
+		items = append(items, item)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func TestImporterAdd(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	signed := Item{Name: "Signed", Magnet: testMagnet(5)}
+	if err := signed.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	tampered := signed
+	tampered.Magnet = testMagnet(6)
+
+	storage := newTestStorage(t)
+	importer, err := NewImporter(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []Item
+	for _, test := range []struct {
+		name string
+		item Item
+		err  error
+	}{
+		{"new item", Item{Name: "Big.Buck.Bunny.2008.1080p", Magnet: testMagnet(0)}, nil},
+		{"every field", Item{
+			Name: "Sintel.2010.720p", Description: "Blender open movie", Magnet: testMagnet(1),
+			Size: 1 << 30, FileCount: 3, Added: 1700000000, Category: "movies",
+			Poster: "https://example.org/sintel.jpg", Channel: "blender",
+		}, nil},
+		{"same info hash", Item{Name: "Big Buck Bunny", Magnet: testMagnet(0)}, ErrDuplicate},
+		{"same info hash, other magnet", Item{Name: "Big Buck Bunny", Magnet: testMagnet(0) + "&dn=bbb&tr=udp://tracker.example.org:80"}, ErrDuplicate},
+		{"same info hash, upper case", Item{Name: "Sintel", Magnet: "magnet:?xt=urn:btih:" + strings.ToUpper(testMagnet(1)[len("magnet:?xt=urn:btih:"):])}, ErrDuplicate},
+		{"no info hash", Item{Name: "No hash", Magnet: "magnet:?dn=nothing"}, ErrInvalidMagnet},
+		{"not a magnet", Item{Name: "Web page", Magnet: "https://example.org/"}, ErrInvalidMagnet},
+		{"short info hash", Item{Name: "Short", Magnet: "magnet:?xt=urn:btih:1234"}, ErrInvalidMagnet},
+		{"empty magnet", Item{Name: "Empty"}, ErrInvalidMagnet},
+		{"long name", Item{Name: strings.Repeat("n", 1000), Magnet: testMagnet(2)}, nil},
+		{"over a chunk", Item{Name: "Huge", Description: strings.Repeat("d", chunk_storage.MaxChunkSize), Magnet: testMagnet(3)}, ErrFieldTooLong},
+		{"signed", signed, nil},
+		{"tampered", tampered, ErrBadSignature},
+	} {
+		err := importer.Add(test.item)
+		if !errors.Is(err, test.err) || (err != nil && test.err == nil) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+		if err == nil {
+			want = append(want, test.item)
+		}
+	}
+
+	// The items read back as they were added.
+	if got := storedItems(t, storage); !reflect.DeepEqual(got, want) {
+		t.Fatalf("stored items:\n%+v\nwant:\n%+v", got, want)
+	}
+	for i, item := range want {
+		hash, err := item.InfoHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunkid, ok := storage.LookupByInfoHash(hash); !ok || chunkid != i {
+			t.Errorf("%s: looked up at %d, %v, want %d", item.Name, chunkid, ok, i)
+		}
+	}
+}
+
+// TestConcurrentImporters adds the same items from several importers at
+// once and checks that each is stored once; run it with -race.
+func TestConcurrentImporters(t *testing.T) {
+	const importers, items = 4, 50
+
+	storage := newTestStorage(t)
+	var added, duplicates int64
+	var wg sync.WaitGroup
+	for i := 0; i < importers; i++ {
+		importer, err := NewImporter(storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < items; j++ {
+				err := importer.Add(Item{Name: fmt.Sprintf("Item.%d", j), Magnet: testMagnet(j)})
+				switch {
+				case err == nil:
+					atomic.AddInt64(&added, 1)
+				case errors.Is(err, ErrDuplicate):
+					atomic.AddInt64(&duplicates, 1)
+				default:
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if added != items || duplicates != (importers-1)*items {
+		t.Errorf("%d added and %d duplicates, want %d and %d", added, duplicates, items, (importers-1)*items)
+	}
+	if n := storage.NumberOfChunks(); n != items {
+		t.Errorf("%d chunks stored, want %d", n, items)
+	}
+}
+
+func TestImporterSigns(t *testing.T) {
+	storage := newTestStorage(t)
+	importer, err := NewImporter(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer.Key = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+	if err := importer.Add(Item{Name: "Unsigned", Magnet: testMagnet(0)}); err != nil {
+		t.Fatal(err)
+	}
+	items := storedItems(t, storage)
+	if len(items) != 1 {
+		t.Fatalf("got %d items", len(items))
+	}
+	if err := items[0].Verify(); err != nil {
+		t.Fatalf("imported item does not verify: %v", err)
+	}
+}
+
+func TestNewImporterNeedsInfoHashIndex(t *testing.T) {
+	storage, err := chunk_storage.New(t.TempDir(), "w64system", chunk_storage.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	if _, err := NewImporter(storage); err == nil {
+		t.Fatal("importer created on a storage without an info hash index")
+	}
+}
+
+// readResult is what ReadJSONL or ReadCSV passes for a line.
+type readResult struct {
+	line int
+	item Item
+	err  bool
+}
+
+func TestReadJSONL(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		want  []readResult
+	}{
+		{"items", `{"name":"A","magnet":"` + testMagnet(0) + `"}
+{"name":"B","description":"b","magnet":"` + testMagnet(1) + `","size":100,"file_count":2,"added":1600000000,"category":"tv","poster":"p","channel":"c"}
+`, []readResult{
+			{1, Item{Name: "A", Magnet: testMagnet(0)}, false},
+			{2, Item{Name: "B", Description: "b", Magnet: testMagnet(1), Size: 100, FileCount: 2, Added: 1600000000, Category: "tv", Poster: "p", Channel: "c"}, false},
+		}},
+		{"blank lines", "\n  \n" + `{"name":"A","magnet":"m"}` + "\n\n", []readResult{
+			{3, Item{Name: "A", Magnet: "m"}, false},
+		}},
+		{"no final newline", `{"name":"A"}`, []readResult{{1, Item{Name: "A"}, false}}},
+		{"malformed line", `{"name":"A"}` + "\n{name\n" + `{"name":"C"}`, []readResult{
+			{1, Item{Name: "A"}, false},
+			{2, Item{}, true},
+			{3, Item{Name: "C"}, false},
+		}},
+		{"wrong type", `{"name":"A","size":"big"}`, []readResult{{1, Item{}, true}}},
+		{"unknown keys", `{"name":"A","seeders":12}`, []readResult{{1, Item{Name: "A"}, false}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got []readResult
+			err := ReadJSONL(strings.NewReader(test.input), func(line int, item Item, err error) error {
+				if err != nil {
+					item = Item{}
+				}
+				got = append(got, readResult{line, item, err != nil})
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		input string
+		want  []readResult
+		err   bool // the whole read fails
+	}{
+		{"items", "name,magnet\nA," + testMagnet(0) + "\nB," + testMagnet(1) + "\n", []readResult{
+			{2, Item{Name: "A", Magnet: testMagnet(0)}, false},
+			{3, Item{Name: "B", Magnet: testMagnet(1)}, false},
+		}, false},
+		{"every column, any order and case", " Magnet ,SIZE,name,Description,file_count,added,category,poster,channel\n" +
+			"m, 100 ,A,\"a, quoted\",2,1600000000,tv,p,c\n", []readResult{
+			{2, Item{Name: "A", Description: "a, quoted", Magnet: "m", Size: 100, FileCount: 2, Added: 1600000000, Category: "tv", Poster: "p", Channel: "c"}, false},
+		}, false},
+		{"bad number", "name,magnet,size\nA,m,big\nB,m,1\n", []readResult{
+			{2, Item{}, true},
+			{3, Item{Name: "B", Magnet: "m", Size: 1}, false},
+		}, false},
+		{"bad file count", "name,magnet,file_count\nA,m,1.5\n", []readResult{{2, Item{}, true}}, false},
+		{"wrong number of fields", "name,magnet\nA,m,extra\nB,m\n", []readResult{
+			{2, Item{}, true},
+			{3, Item{Name: "B", Magnet: "m"}, false},
+		}, false},
+		{"no magnet column", "name,description\nA,a\n", nil, true},
+		{"no name column", "magnet\nm\n", nil, true},
+		{"empty", "", nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var got []readResult
+			err := ReadCSV(strings.NewReader(test.input), func(line int, item Item, err error) error {
+				if err != nil {
+					item = Item{}
+				}
+				got = append(got, readResult{line, item, err != nil})
+				return nil
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("got error %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestImportFiles builds a catalog from JSONL and CSV the way w64tool
+// import does, and reads it back.
+func TestImportFiles(t *testing.T) {
+	jsonl := `{"name":"Night.of.the.Living.Dead.1968","description":"public domain","magnet":"` + testMagnet(0) + `","size":734003200}
+{"name":"Duplicate in the same file","magnet":"` + testMagnet(0) + `"}
+{"name":"Bad magnet","magnet":"magnet:?xt=urn:btih:xyz"}
+not json
+`
+	csvInput := "name,magnet,category\n" +
+		"Plan.9.from.Outer.Space.1959," + testMagnet(1) + ",movies\n" +
+		"Duplicate across files," + testMagnet(0) + ",movies\n"
+
+	storage := newTestStorage(t)
+	importer, err := NewImporter(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var added, duplicates, rejected int
+	add := func(line int, item Item, err error) error {
+		if err == nil {
+			err = importer.Add(item)
+		}
+		switch {
+		case err == nil:
+			added++
+		case errors.Is(err, ErrDuplicate):
+			duplicates++
+		default:
+			rejected++
+		}
+		return nil
+	}
+	if err := ReadJSONL(strings.NewReader(jsonl), add); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadCSV(strings.NewReader(csvInput), add); err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 || duplicates != 2 || rejected != 2 {
+		t.Errorf("added %d, duplicates %d, rejected %d; want 2, 2, 2", added, duplicates, rejected)
+	}
+
+	want := []Item{
+		{Name: "Night.of.the.Living.Dead.1968", Description: "public domain", Magnet: testMagnet(0), Size: 734003200},
+		{Name: "Plan.9.from.Outer.Space.1959", Magnet: testMagnet(1), Category: "movies"},
+	}
+	if got := storedItems(t, storage); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestAddFrom(t *testing.T) {
+	src := newTestStorage(t)
+	for i, chunk := range [][]byte{
+		mustEncode(t, Item{Name: "A", Magnet: testMagnet(0)}),
+		[]byte("\x00TLV\x01\x01\x7f"), // truncated
+		mustEncode(t, Item{Name: "B", Magnet: testMagnet(1)}),
+		mustEncode(t, Item{Name: "Bad magnet", Magnet: "magnet:?dn=x"}),
+		mustEncode(t, Item{Name: "Known", Magnet: testMagnet(2)}),
+	} {
+		if err := src.AddChunk(chunk); err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+
+	dst := newTestStorage(t)
+	importer, err := NewImporter(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := importer.Add(Item{Name: "Known", Magnet: testMagnet(2)}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := importer.AddFrom(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ImportStats{Added: 2, Duplicates: 1, Rejected: 2}); stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+func mustEncode(t *testing.T, item Item) []byte {
+	t.Helper()
+
+	b, err := EncodeItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,244 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// Item is a catalog entry as stored in a w64system chunk. Items are encoded
+// as a versioned list of fields:
+//
+//	marker "\x00TLV" | version uint8 | field...
+//	field: type uvarint | length uvarint | value[length]
+//
+// Strings and bytes are stored as is and integers as varints. Fields are
+// written in increasing type order and left out at their zero value. A
+// decoder keeps the fields it does not know in Unknown and EncodeItem
+// writes them back, so rewriting an item with an older build does not lose
+// data added by a newer one. The version only changes when existing fields
+// change meaning.
+//
+// Chunks written before this format hold the legacy layout described at
+// DecodeLegacyItem. A legacy item starting with the marker would have an
+// empty name, which the catalog never held.
+type Item struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Magnet      string `json:"magnet"`
+
+	Size      int64  `json:"size,omitempty"`       // total size of the torrent's files in bytes
+	FileCount int    `json:"file_count,omitempty"` // number of files in the torrent
+	Added     int64  `json:"added,omitempty"`      // Unix time in seconds the item was published
+	Category  string `json:"category,omitempty"`
+	Poster    string `json:"poster,omitempty"` // URL of a poster image
+	Channel   string `json:"channel,omitempty"`
+
+	PublisherKey []byte `json:"publisher_key,omitempty"` // ed25519 public key of the signer, see Sign
+	Signature    []byte `json:"signature,omitempty"`
+
+	Unknown []Field `json:"unknown,omitempty"`
+}
+
+// Field is an encoded field of an Item.
+type Field struct {
+	Type  uint64 `json:"type"`
+	Value []byte `json:"value"`
+}
+
+// Field types of the current item format.
+const (
+	FieldName        = 1
+	FieldDescription = 2
+	FieldMagnet      = 3
+	FieldSize        = 4
+	FieldFileCount   = 5
+	FieldAdded       = 6
+	FieldCategory    = 7
+	FieldPoster      = 8
+	FieldChannel     = 9
+	FieldPublisher   = 10
+	FieldSignature   = 11
+)
+
+const (
+	itemMarker  = "\x00TLV"
+	ItemVersion = 1
+)
+
+var (
+	// ErrTruncated is returned when a chunk ends before the item it holds.
+	ErrTruncated = errors.New("unexpected end of item")
+
+	// ErrFieldTooLong is returned when encoding an item that does not fit
+	// in its layout.
+	ErrFieldTooLong = errors.New("item field too long")
+
+	// ErrInvalidMagnet is returned when an item's magnet URI does not parse
+	// or carries no info hash.
+	ErrInvalidMagnet = errors.New("invalid magnet URI")
+
+	// ErrUnsupportedVersion is returned when decoding an item written in a
+	// newer, incompatible version of the format.
+	ErrUnsupportedVersion = errors.New("unsupported item version")
+)
+
+// DecodeItem decodes the item held by a chunk, in the current or the legacy
+// layout.
+func DecodeItem(b []byte) (Item, error) {
+	if !bytes.HasPrefix(b, []byte(itemMarker)) {
+		return DecodeLegacyItem(b)
+	}
+
+	b = b[len(itemMarker):]
+	if len(b) < 1 {
+		return Item{}, ErrTruncated
+	}
+	if b[0] != ItemVersion {
+		return Item{}, fmt.Errorf("version %d: %w", b[0], ErrUnsupportedVersion)
+	}
+	b = b[1:]
+
+	var item Item
+	for len(b) > 0 {
+		fieldType, n := binary.Uvarint(b)
+		if n <= 0 {
+			return Item{}, ErrTruncated
+		}
+		b = b[n:]
+
+		length, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < length {
+			return Item{}, ErrTruncated
+		}
+		value := b[n : n+int(length)]
+		b = b[n+int(length):]
+
+		if err := item.setField(fieldType, value); err != nil {
+			return Item{}, err
+		}
+	}
+	return item, nil
+}
+
+func (item *Item) setField(fieldType uint64, value []byte) error {
+	switch fieldType {
+	case FieldName:
+		item.Name = string(value)
+	case FieldDescription:
+		item.Description = string(value)
+	case FieldMagnet:
+		item.Magnet = string(value)
+	case FieldCategory:
+		item.Category = string(value)
+	case FieldPoster:
+		item.Poster = string(value)
+	case FieldChannel:
+		item.Channel = string(value)
+	case FieldPublisher:
+		item.PublisherKey = append([]byte(nil), value...)
+	case FieldSignature:
+		item.Signature = append([]byte(nil), value...)
+	case FieldSize, FieldFileCount, FieldAdded:
+		v, n := binary.Varint(value)
+		if n <= 0 || n != len(value) {
+			return fmt.Errorf("field %d: invalid varint", fieldType)
+		}
+		switch fieldType {
+		case FieldSize:
+			item.Size = v
+		case FieldFileCount:
+			item.FileCount = int(v)
+		case FieldAdded:
+			item.Added = v
+		}
+	default:
+		item.Unknown = append(item.Unknown, Field{Type: fieldType, Value: append([]byte(nil), value...)})
+	}
+	return nil
+}
+
+// EncodeItem encodes item in the current layout.
+func EncodeItem(item Item) ([]byte, error) {
+	b := append([]byte(itemMarker), ItemVersion)
+
+	b = appendString(b, FieldName, item.Name)
+	b = appendString(b, FieldDescription, item.Description)
+	b = appendString(b, FieldMagnet, item.Magnet)
+	b = appendInt(b, FieldSize, item.Size)
+	b = appendInt(b, FieldFileCount, int64(item.FileCount))
+	b = appendInt(b, FieldAdded, item.Added)
+	b = appendString(b, FieldCategory, item.Category)
+	b = appendString(b, FieldPoster, item.Poster)
+	b = appendString(b, FieldChannel, item.Channel)
+	b = appendBytes(b, FieldPublisher, item.PublisherKey)
+	b = appendBytes(b, FieldSignature, item.Signature)
+
+	// Unknown fields come from newer versions, whose types follow the ones
+	// above; sorting them keeps the encoding canonical for signatures.
+	unknown := append([]Field(nil), item.Unknown...)
+	sort.SliceStable(unknown, func(i, j int) bool { return unknown[i].Type < unknown[j].Type })
+	for _, field := range unknown {
+		b = appendField(b, field.Type, field.Value)
+	}
+
+	if len(b) > chunk_storage.MaxChunkSize {
+		return nil, fmt.Errorf("item of %d bytes: %w", len(b), ErrFieldTooLong)
+	}
+	return b, nil
+}
+
+func appendField(b []byte, fieldType uint64, value []byte) []byte {
+	var buf [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], fieldType)
+	n += binary.PutUvarint(buf[n:], uint64(len(value)))
+	return append(append(b, buf[:n]...), value...)
+}
+
+func appendString(b []byte, fieldType uint64, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendField(b, fieldType, []byte(s))
+}
+
+func appendBytes(b []byte, fieldType uint64, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	return appendField(b, fieldType, value)
+}
+
+func appendInt(b []byte, fieldType uint64, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return appendField(b, fieldType, buf[:n])
+}
+
+// InfoHash returns the info hash of the item's magnet URI.
+func (item Item) InfoHash() (metainfo.Hash, error) {
+	magnet, err := metainfo.ParseMagnetUri(item.Magnet)
+	if err != nil {
+		return metainfo.Hash{}, fmt.Errorf("%w: %v", ErrInvalidMagnet, err)
+	}
+	return magnet.InfoHash, nil
+}
+
+// ChunkInfoHash returns the info hash of the item held by a chunk. It is
+// meant for chunk_storage.Options.InfoHashFunc.
+func ChunkInfoHash(chunk []byte) (metainfo.Hash, bool) {
+	item, err := DecodeItem(chunk)
+	if err != nil {
+		return metainfo.Hash{}, false
+	}
+	hash, err := item.InfoHash()
+	return hash, err == nil
+}
@@ -0,0 +1,212 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fullItem sets every field of Item.
+var fullItem = Item{
+	Name:         "Sintel.2010.1080p.BluRay.x264-GRP",
+	Description:  "A girl searches for her dragon",
+	Magnet:       "magnet:?xt=urn:btih:08ada5a7a6183aae1e09d831df6748d566095a10&dn=Sintel",
+	Size:         1 << 32,
+	FileCount:    12,
+	Added:        -1, // negative values survive too
+	Category:     "movies",
+	Poster:       "https://example.org/sintel.jpg",
+	Channel:      "blender",
+	PublisherKey: bytes.Repeat([]byte{0xaa}, 32),
+	Signature:    bytes.Repeat([]byte{0x55}, 64),
+	Unknown:      []Field{{Type: 40, Value: []byte("newer")}, {Type: 1000, Value: nil}},
+}
+
+func TestItemRoundTrip(t *testing.T) {
+	for _, item := range []Item{
+		fullItem,
+		{Name: "Name only"},
+		{Magnet: "magnet:?xt=urn:btih:08ada5a7a6183aae1e09d831df6748d566095a10"},
+		{Name: "Ünïcödé 名前", Description: "\x00binary\xff"},
+	} {
+		b, err := EncodeItem(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b, []byte(itemMarker+"\x01")) {
+			t.Errorf("%q: encoding starts with %q", item.Name, b[:5])
+		}
+		got, err := DecodeItem(b)
+		if err != nil {
+			t.Fatalf("%q: %v", item.Name, err)
+		}
+		// Empty unknown values decode as empty, not nil.
+		for i := range got.Unknown {
+			if len(got.Unknown[i].Value) == 0 {
+				got.Unknown[i].Value = nil
+			}
+		}
+		if !reflect.DeepEqual(got, item) {
+			t.Errorf("decoded as %+v, want %+v", got, item)
+		}
+	}
+
+	// Zero fields are left out.
+	b, err := EncodeItem(Item{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != itemMarker+"\x01" {
+		t.Errorf("empty item encoded as %q", b)
+	}
+}
+
+func TestDecodeLegacyItem(t *testing.T) {
+	legacy := func(name, description, magnet string) []byte {
+		b := []byte{byte(len(name))}
+		b = append(b, name...)
+		b = appendUint16(b, len(description))
+		b = append(b, description...)
+		b = appendUint16(b, len(magnet))
+		return append(b, magnet...)
+	}
+
+	for _, test := range []struct {
+		name string
+		b    []byte
+		want Item
+	}{
+		{"every field", legacy("The.Matrix.1999", "Wake up", "magnet:?xt=urn:btih:1"), Item{Name: "The.Matrix.1999", Description: "Wake up", Magnet: "magnet:?xt=urn:btih:1"}},
+		{"empty description", legacy("A", "", "m"), Item{Name: "A", Magnet: "m"}},
+		{"long description", legacy("A", string(bytes.Repeat([]byte("d"), 300)), "m"), Item{Name: "A", Description: string(bytes.Repeat([]byte("d"), 300)), Magnet: "m"}},
+		{"trailing bytes ignored", append(legacy("A", "d", "m"), "junk"...), Item{Name: "A", Description: "d", Magnet: "m"}},
+	} {
+		got, err := DecodeItem(test.b)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestUnknownFieldPassthrough decodes an item written by a newer version,
+// with fields this one does not know, and checks that encoding it again
+// gives back the same bytes.
+func TestUnknownFieldPassthrough(t *testing.T) {
+	newer := append([]byte(itemMarker), ItemVersion)
+	newer = appendString(newer, FieldName, "Newer")
+	newer = appendString(newer, FieldMagnet, "magnet:?xt=urn:btih:08ada5a7a6183aae1e09d831df6748d566095a10")
+	newer = appendInt(newer, FieldAdded, 1700000000)
+	newer = appendField(newer, 12, []byte("rating 9/10"))
+	newer = appendField(newer, 300, []byte{0, 1, 2, 0xff})
+	newer = appendField(newer, 1<<40, nil)
+
+	item, err := DecodeItem(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.Unknown) != 3 || item.Unknown[0].Type != 12 || item.Unknown[1].Type != 300 || item.Unknown[2].Type != 1<<40 {
+		t.Fatalf("unknown fields %+v", item.Unknown)
+	}
+	b, err := EncodeItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, newer) {
+		t.Errorf("re-encoded as\n%q\nwant\n%q", b, newer)
+	}
+
+	// Unknown fields are put back in type order whatever order they are
+	// given in.
+	item.Unknown[0], item.Unknown[2] = item.Unknown[2], item.Unknown[0]
+	if b, err := EncodeItem(item); err != nil || !bytes.Equal(b, newer) {
+		t.Errorf("reordered unknown fields re-encoded as %q, %v", b, err)
+	}
+}
+
+func TestDecodeInvalidItem(t *testing.T) {
+	valid, err := EncodeItem(fullItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []byte(itemMarker + "\x01")
+
+	for _, test := range []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"marker only", []byte(itemMarker), ErrTruncated},
+		{"newer version", []byte(itemMarker + "\x02"), ErrUnsupportedVersion},
+		{"type cut short", append(header, 0x80), ErrTruncated},
+		{"no length", append(header, FieldName), ErrTruncated},
+		{"length cut short", append(header, FieldName, 0x80), ErrTruncated},
+		{"value cut short", append(header, FieldName, 5, 'a', 'b'), ErrTruncated},
+		{"length past the end", append(header, FieldName, 0xff, 0xff, 0xff, 0xff, 0x0f, 'a'), ErrTruncated},
+		{"largest length", append(header, FieldName, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01), ErrTruncated},
+		{"overlong uvarint", append(header, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01), ErrTruncated},
+		{"legacy name cut short", []byte{5, 'a'}, ErrTruncated},
+		{"legacy description cut short", []byte{1, 'a', 3, 0, 'd'}, ErrTruncated},
+		{"legacy magnet length cut short", []byte{1, 'a', 0, 0, 1}, ErrTruncated},
+		{"empty", nil, ErrTruncated},
+	} {
+		if _, err := DecodeItem(test.b); !errors.Is(err, test.err) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+		}
+	}
+
+	// A varint field whose value is not a single varint.
+	for _, value := range [][]byte{{0x80}, {0x01, 0x02}, nil} {
+		if _, err := DecodeItem(appendField(append([]byte(nil), header...), FieldSize, value)); err == nil {
+			t.Errorf("size field %x decoded", value)
+		}
+	}
+
+	// An item cut within a field fails; one cut between fields decodes as
+	// the fields before the cut.
+	for n := len(header); n < len(valid); n++ {
+		item, err := DecodeItem(valid[:n])
+		if err != nil {
+			continue
+		}
+		if b, err := EncodeItem(item); err != nil || !bytes.Equal(b, valid[:n]) {
+			t.Errorf("item cut to %d of %d bytes decoded as %+v", n, len(valid), item)
+		}
+	}
+}
+
+func FuzzDecodeItem(f *testing.F) {
+	valid, err := EncodeItem(fullItem)
+	if err != nil {
+		f.Fatal(err)
+	}
+	legacy, err := EncodeLegacyItem(Item{Name: "A", Description: "d", Magnet: "m"})
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+	f.Add(legacy)
+	f.Add([]byte(itemMarker + "\x01\x01\xff\xff\xff\xff\x0f"))
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		item, err := DecodeItem(b)
+		if err != nil || !bytes.HasPrefix(b, []byte(itemMarker)) {
+			return
+		}
+		// What decodes encodes canonically: decoding and encoding again
+		// gives the same bytes.
+		encoded, err := EncodeItem(item)
+		if err != nil {
+			return
+		}
+		again, err := DecodeItem(encoded)
+		if err != nil {
+			t.Fatalf("%q re-encoded as %q, which does not decode: %v", b, encoded, err)
+		}
+		if reencoded, err := EncodeItem(again); err != nil || !bytes.Equal(reencoded, encoded) {
+			t.Fatalf("%q re-encoded as %q, then as %q, %v", b, encoded, reencoded, err)
+		}
+	})
+}
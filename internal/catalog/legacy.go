@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Largest field lengths the legacy layout can express.
+const (
+	MaxNameLength        = 1<<8 - 1
+	MaxDescriptionLength = 1<<16 - 1
+	MaxMagnetLength      = 1<<16 - 1
+)
+
+// ErrNotLegacy is returned when encoding an item with fields the legacy
+// layout cannot hold.
+var ErrNotLegacy = errors.New("item has fields the legacy layout cannot hold")
+
+// DecodeLegacyItem decodes an item stored in the layout of catalogs written
+// before the versioned format:
+//
+//	name length uint8 | name | description length uint16 | description |
+//	magnet length uint16 | magnet
+//
+// with lengths in little endian.
+func DecodeLegacyItem(b []byte) (Item, error) {
+	var item Item
+
+	name, rest, ok := readField(b, 1)
+	if !ok {
+		return Item{}, ErrTruncated
+	}
+	description, rest, ok := readField(rest, 2)
+	if !ok {
+		return Item{}, ErrTruncated
+	}
+	magnet, _, ok := readField(rest, 2)
+	if !ok {
+		return Item{}, ErrTruncated
+	}
+
+	item.Name, item.Description, item.Magnet = string(name), string(description), string(magnet)
+	return item, nil
+}
+
+// EncodeLegacyItem encodes item in the legacy layout, for catalogs read by
+// older clients. Only the name, description and magnet can be stored, so
+// signed items cannot be.
+func EncodeLegacyItem(item Item) ([]byte, error) {
+	switch {
+	case len(item.Name) > MaxNameLength:
+		return nil, fmt.Errorf("name of %d bytes: %w", len(item.Name), ErrFieldTooLong)
+	case len(item.Description) > MaxDescriptionLength:
+		return nil, fmt.Errorf("description of %d bytes: %w", len(item.Description), ErrFieldTooLong)
+	case len(item.Magnet) > MaxMagnetLength:
+		return nil, fmt.Errorf("magnet of %d bytes: %w", len(item.Magnet), ErrFieldTooLong)
+	}
+
+	if item.Size != 0 || item.FileCount != 0 || item.Added != 0 || item.Category != "" ||
+		item.Poster != "" || item.Channel != "" || len(item.Unknown) > 0 ||
+		len(item.PublisherKey) > 0 || len(item.Signature) > 0 {
+		return nil, ErrNotLegacy
+	}
+
+	b := make([]byte, 0, 1+len(item.Name)+2+len(item.Description)+2+len(item.Magnet))
+	b = append(b, byte(len(item.Name)))
+	b = append(b, item.Name...)
+	b = appendUint16(b, len(item.Description))
+	b = append(b, item.Description...)
+	b = appendUint16(b, len(item.Magnet))
+	b = append(b, item.Magnet...)
+	return b, nil
+}
+
+// readField reads a field prefixed by its length on lengthSize bytes.
+func readField(b []byte, lengthSize int) (field, rest []byte, ok bool) {
+	if len(b) < lengthSize {
+		return nil, nil, false
+	}
+
+	var length int
+	if lengthSize == 1 {
+		length = int(b[0])
+	} else {
+		length = int(binary.LittleEndian.Uint16(b))
+	}
+
+	b = b[lengthSize:]
+	if len(b) < length {
+		return nil, nil, false
+	}
+	return b[:length], b[length:], true
+}
+
+func appendUint16(b []byte, v int) []byte {
+	var buf [2]byte
+	binary.LittleEndian.PutUint16(buf[:], uint16(v))
+	return append(b, buf[:]...)
+}
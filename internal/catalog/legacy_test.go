@@ -0,0 +1,51 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeLegacyItem(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		item Item
+		err  error
+	}{
+		{"empty description", Item{Name: "A", Magnet: testMagnet(0)}, nil},
+		{"every field", Item{Name: "The.Matrix.1999", Description: "Wake up, Neo", Magnet: testMagnet(1)}, nil},
+		{"longest fields", Item{
+			Name:        strings.Repeat("n", MaxNameLength),
+			Description: strings.Repeat("d", MaxDescriptionLength),
+			Magnet:      strings.Repeat("m", MaxMagnetLength),
+		}, nil},
+		{"name over 255 bytes", Item{Name: strings.Repeat("n", MaxNameLength+1), Magnet: testMagnet(0)}, ErrFieldTooLong},
+		{"multibyte name over 255 bytes", Item{Name: strings.Repeat("é", 128), Magnet: testMagnet(0)}, ErrFieldTooLong},
+		{"description too long", Item{Name: "A", Description: strings.Repeat("d", MaxDescriptionLength+1)}, ErrFieldTooLong},
+		{"magnet too long", Item{Name: "A", Magnet: strings.Repeat("m", MaxMagnetLength+1)}, ErrFieldTooLong},
+		{"size", Item{Name: "A", Size: 1}, ErrNotLegacy},
+		{"category", Item{Name: "A", Category: "tv"}, ErrNotLegacy},
+		{"unknown field", Item{Name: "A", Unknown: []Field{{Type: 99, Value: []byte{1}}}}, ErrNotLegacy},
+		{"publisher key", Item{Name: "A", PublisherKey: make([]byte, 32)}, ErrNotLegacy},
+		{"signature", Item{Name: "A", Signature: make([]byte, 64)}, ErrNotLegacy},
+	} {
+		b, err := EncodeLegacyItem(test.item)
+		if !errors.Is(err, test.err) || (err != nil && test.err == nil) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if want := 1 + len(test.item.Name) + 2 + len(test.item.Description) + 2 + len(test.item.Magnet); len(b) != want {
+			t.Errorf("%s: encoded in %d bytes, want %d", test.name, len(b), want)
+		}
+		got, err := DecodeItem(b)
+		if err != nil {
+			t.Errorf("%s: decoding: %v", test.name, err)
+		} else if got.Name != test.item.Name || got.Description != test.item.Description || got.Magnet != test.item.Magnet {
+			t.Errorf("%s: decoded as %+v", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// MergePolicy chooses between two items with the same info hash but a
+// different name or description. The chosen item is kept whole, so its
+// signature stays valid.
+type MergePolicy string
+
+const (
+	// MergeNewest keeps the item added last, A on a tie.
+	MergeNewest MergePolicy = "newest"
+	// MergeLongest keeps the item with the longer name and description, A
+	// on a tie.
+	MergeLongest MergePolicy = "longest"
+	// MergePreferA always keeps the item from A.
+	MergePreferA MergePolicy = "prefer-a"
+)
+
+// ParseMergePolicy checks that s names a MergePolicy.
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	switch policy := MergePolicy(s); policy {
+	case MergeNewest, MergeLongest, MergePreferA:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown merge policy '%s'", s)
+}
+
+// MergeReport records what Merge did with every record of both catalogs.
+type MergeReport struct {
+	Policy    MergePolicy     `json:"policy"`
+	Merged    []MergedRecord  `json:"merged"`
+	Skipped   []SkippedRecord `json:"skipped"`
+	Conflicts []Conflict      `json:"conflicts"`
+}
+
+// MergedRecord is a record written to the merged catalog.
+type MergedRecord struct {
+	ID       int    `json:"id"` // chunk ID in the merged catalog
+	Source   string `json:"source"`
+	SourceID int    `json:"source_id"`
+	InfoHash string `json:"info_hash"`
+}
+
+// SkippedRecord is a record left out of the merged catalog.
+type SkippedRecord struct {
+	Source   string `json:"source"`
+	SourceID int    `json:"source_id"`
+	Reason   string `json:"reason"`
+}
+
+// Conflict is a pair of items sharing an info hash but not their name or
+// description.
+type Conflict struct {
+	InfoHash string          `json:"info_hash"`
+	A        ConflictingItem `json:"a"`
+	B        ConflictingItem `json:"b"`
+	Kept     string          `json:"kept"`
+}
+
+// ConflictingItem is one side of a Conflict.
+type ConflictingItem struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Added       int64  `json:"added,omitempty"`
+}
+
+// mergeEntry is an item of a source catalog along with its raw chunk.
+type mergeEntry struct {
+	source string
+	id     int
+	item   Item
+	chunk  []byte
+}
+
+// Merge writes the items of catalogs a and b to the empty storage out,
+// once per info hash. Items of a come first, in order, followed by the items
+// only b has. Records that cannot be read, that have an invalid magnet or
+// signature, or that repeat an info hash within their own catalog are
+// skipped. Chunks are copied as stored, without re-encoding.
+func Merge(a, b, out *chunk_storage.ChunkStorage, policy MergePolicy) (MergeReport, error) {
+	report := MergeReport{Policy: policy, Merged: []MergedRecord{}, Skipped: []SkippedRecord{}, Conflicts: []Conflict{}}
+
+	if out.NumberOfChunks() > 0 {
+		return report, errors.New("merge output is not empty")
+	}
+
+	entriesA, err := readMergeEntries(a, "a", &report)
+	if err != nil {
+		return report, err
+	}
+	entriesB, err := readMergeEntries(b, "b", &report)
+	if err != nil {
+		return report, err
+	}
+
+	byHash := make(map[metainfo.Hash]*mergeEntry, len(entriesB))
+	for i := range entriesB {
+		hash, _ := entriesB[i].item.InfoHash()
+		byHash[hash] = &entriesB[i]
+	}
+
+	write := func(hash metainfo.Hash, entry *mergeEntry) error {
+		if err := out.AddChunk(entry.chunk); err != nil {
+			return fmt.Errorf("writing merged catalog: %v", err)
+		}
+		report.Merged = append(report.Merged, MergedRecord{
+			ID:       out.NumberOfChunks() - 1,
+			Source:   entry.source,
+			SourceID: entry.id,
+			InfoHash: hash.HexString(),
+		})
+		return nil
+	}
+
+	for i := range entriesA {
+		entryA := &entriesA[i]
+		hash, _ := entryA.item.InfoHash()
+
+		entryB, ok := byHash[hash]
+		if !ok {
+			if err := write(hash, entryA); err != nil {
+				return report, err
+			}
+			continue
+		}
+		delete(byHash, hash)
+
+		kept, dropped := entryA, entryB
+		if entryA.item.Name != entryB.item.Name || entryA.item.Description != entryB.item.Description {
+			if policy.prefersB(entryA.item, entryB.item) {
+				kept, dropped = entryB, entryA
+			}
+			report.Conflicts = append(report.Conflicts, Conflict{
+				InfoHash: hash.HexString(),
+				A:        entryA.conflicting(),
+				B:        entryB.conflicting(),
+				Kept:     kept.source,
+			})
+		}
+
+		if err := write(hash, kept); err != nil {
+			return report, err
+		}
+		report.Skipped = append(report.Skipped, SkippedRecord{
+			Source:   dropped.source,
+			SourceID: dropped.id,
+			Reason:   fmt.Sprintf("same info hash as %s:%d", kept.source, kept.id),
+		})
+	}
+
+	for i := range entriesB {
+		hash, _ := entriesB[i].item.InfoHash()
+		if _, ok := byHash[hash]; ok {
+			if err := write(hash, &entriesB[i]); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func (policy MergePolicy) prefersB(a, b Item) bool {
+	switch policy {
+	case MergeNewest:
+		return b.Added > a.Added
+	case MergeLongest:
+		return len(b.Name)+len(b.Description) > len(a.Name)+len(a.Description)
+	}
+	return false
+}
+
+func (entry *mergeEntry) conflicting() ConflictingItem {
+	return ConflictingItem{ID: entry.id, Name: entry.item.Name, Description: entry.item.Description, Added: entry.item.Added}
+}
+
+// readMergeEntries reads the valid items of storage, first one per info
+// hash, reporting the others as skipped.
+func readMergeEntries(storage *chunk_storage.ChunkStorage, source string, report *MergeReport) ([]mergeEntry, error) {
+	var entries []mergeEntry
+	seen := make(map[metainfo.Hash]int)
+
+	skip := func(id int, reason string) {
+		report.Skipped = append(report.Skipped, SkippedRecord{Source: source, SourceID: id, Reason: reason})
+	}
+
+	it := storage.Scan(0, -1, false)
+	for {
+		for it.Next() {
+			item, err := DecodeItem(it.Bytes())
+			var hash metainfo.Hash
+			if err == nil {
+				hash, err = item.InfoHash()
+			}
+			if err == nil {
+				if err = item.Verify(); errors.Is(err, ErrUnsigned) {
+					err = nil
+				}
+			}
+			if err != nil {
+				skip(it.ID(), err.Error())
+				continue
+			}
+
+			if first, ok := seen[hash]; ok {
+				skip(it.ID(), fmt.Sprintf("same info hash as %s:%d", source, first))
+				continue
+			}
+			seen[hash] = it.ID()
+
+			chunk := append([]byte(nil), it.Bytes()...)
+			entries = append(entries, mergeEntry{source: source, id: it.ID(), item: item, chunk: chunk})
+		}
+
+		err := it.Err()
+		if err == nil {
+			return entries, nil
+		} else if errors.Is(err, chunk_storage.ErrCompacted) {
+			return nil, fmt.Errorf("reading catalog %s: %v", source, err)
+		}
+		skip(it.ID(), err.Error())
+		it = storage.Scan(it.ID()+1, -1, false)
+	}
+}
@@ -0,0 +1,325 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// newTestCatalog returns a storage holding chunks, each an encoded Item or
+// raw bytes, as given.
+func newTestCatalog(t *testing.T, chunks ...interface{}) *chunk_storage.ChunkStorage {
+	t.Helper()
+
+	storage := newTestStorage(t)
+	for _, chunk := range chunks {
+		b, ok := chunk.([]byte)
+		if !ok {
+			b = mustEncode(t, chunk.(Item))
+		}
+		if err := storage.AddChunk(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return storage
+}
+
+// testHashHex returns the hex info hash of testMagnet(i).
+func testHashHex(t *testing.T, i int) string {
+	t.Helper()
+
+	hash, err := Item{Magnet: testMagnet(i)}.InfoHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.HexString()
+}
+
+func TestMergePolicies(t *testing.T) {
+	short := Item{Name: "Sintel", Magnet: testMagnet(0), Added: 100}
+	long := Item{Name: "Sintel.2010.1080p", Description: "Blender open movie", Magnet: testMagnet(0), Added: 100}
+	newer := Item{Name: "Sintel 2010", Magnet: testMagnet(0), Added: 200}
+	older := Item{Name: "Sintel (2010)", Magnet: testMagnet(0), Added: 50}
+
+	for _, test := range []struct {
+		name     string
+		policy   MergePolicy
+		a, b     Item
+		kept     string
+		conflict bool
+	}{
+		{"newest, B newer", MergeNewest, short, newer, "b", true},
+		{"newest, A newer", MergeNewest, newer, short, "a", true},
+		{"newest, tie", MergeNewest, short, long, "a", true},
+		{"newest, no dates", MergeNewest, Item{Name: "x", Magnet: testMagnet(0)}, Item{Name: "y", Magnet: testMagnet(0)}, "a", true},
+		{"longest, B longer", MergeLongest, short, long, "b", true},
+		{"longest, A longer", MergeLongest, long, short, "a", true},
+		{"longest, tie", MergeLongest, Item{Name: "abc", Magnet: testMagnet(0)}, Item{Name: "a", Description: "bc", Magnet: testMagnet(0)}, "a", true},
+		{"prefer-a, B newer and longer", MergePreferA, older, long, "a", true},
+		{"prefer-a, A newer", MergePreferA, newer, short, "a", true},
+
+		// Items differing only in fields other than the name and
+		// description do not conflict; A is kept whatever the policy.
+		{"same text, newest", MergeNewest, short, Item{Name: "Sintel", Magnet: testMagnet(0), Added: 999}, "a", false},
+		{"same text, longest", MergeLongest, short, Item{Name: "Sintel", Magnet: testMagnet(0), Category: "movies"}, "a", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a, b, out := newTestCatalog(t, test.a), newTestCatalog(t, test.b), newTestStorage(t)
+			report, err := Merge(a, b, out, test.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]Item{"a": test.a, "b": test.b}[test.kept]
+			if got := storedItems(t, out); len(got) != 1 || !reflect.DeepEqual(got[0], want) {
+				t.Fatalf("merged %+v, want %+v", got, want)
+			}
+			if len(report.Merged) != 1 || report.Merged[0].Source != test.kept || report.Merged[0].SourceID != 0 {
+				t.Errorf("merged records %+v", report.Merged)
+			}
+			dropped := map[string]string{"a": "b", "b": "a"}[test.kept]
+			if len(report.Skipped) != 1 || report.Skipped[0].Source != dropped ||
+				report.Skipped[0].Reason != "same info hash as "+test.kept+":0" {
+				t.Errorf("skipped records %+v", report.Skipped)
+			}
+
+			if !test.conflict {
+				if len(report.Conflicts) != 0 {
+					t.Errorf("conflicts %+v, want none", report.Conflicts)
+				}
+				return
+			}
+			wantConflict := Conflict{
+				InfoHash: testHashHex(t, 0),
+				A:        ConflictingItem{Name: test.a.Name, Description: test.a.Description, Added: test.a.Added},
+				B:        ConflictingItem{Name: test.b.Name, Description: test.b.Description, Added: test.b.Added},
+				Kept:     test.kept,
+			}
+			if len(report.Conflicts) != 1 || report.Conflicts[0] != wantConflict {
+				t.Errorf("conflicts %+v, want %+v", report.Conflicts, wantConflict)
+			}
+		})
+	}
+}
+
+// TestMergeOrder checks that the items of A come first, in order, followed
+// by those only B has, in order.
+func TestMergeOrder(t *testing.T) {
+	a := newTestCatalog(t,
+		Item{Name: "a0", Magnet: testMagnet(0)},
+		Item{Name: "a1", Magnet: testMagnet(1)},
+		Item{Name: "a2", Magnet: testMagnet(2)},
+	)
+	b := newTestCatalog(t,
+		Item{Name: "b0", Magnet: testMagnet(3)},
+		Item{Name: "a1", Magnet: testMagnet(1)},
+		Item{Name: "b2", Magnet: testMagnet(4)},
+		Item{Name: "b3", Magnet: testMagnet(0)},
+	)
+	out := newTestStorage(t)
+
+	report, err := Merge(a, b, out, MergeNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, item := range storedItems(t, out) {
+		names = append(names, item.Name)
+	}
+	if want := []string{"a0", "a1", "a2", "b0", "b2"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("merged %v, want %v", names, want)
+	}
+
+	want := []MergedRecord{
+		{ID: 0, Source: "a", SourceID: 0, InfoHash: testHashHex(t, 0)},
+		{ID: 1, Source: "a", SourceID: 1, InfoHash: testHashHex(t, 1)},
+		{ID: 2, Source: "a", SourceID: 2, InfoHash: testHashHex(t, 2)},
+		{ID: 3, Source: "b", SourceID: 0, InfoHash: testHashHex(t, 3)},
+		{ID: 4, Source: "b", SourceID: 2, InfoHash: testHashHex(t, 4)},
+	}
+	if !reflect.DeepEqual(report.Merged, want) {
+		t.Errorf("merged records %+v, want %+v", report.Merged, want)
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].B.ID != 3 {
+		t.Errorf("conflicts %+v, want a0 against b3", report.Conflicts)
+	}
+
+	// Merged chunks are copied as stored.
+	for _, record := range report.Merged {
+		src := map[string]*chunk_storage.ChunkStorage{"a": a, "b": b}[record.Source]
+		want, err := src.GetChunkById(record.SourceID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := out.GetChunkById(record.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("chunk %d differs from %s:%d", record.ID, record.Source, record.SourceID)
+		}
+	}
+}
+
+func TestMergeSkipped(t *testing.T) {
+	signed := Item{Name: "Signed", Magnet: testMagnet(5)}
+	if err := signed.Sign(testKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	tampered := signed
+	tampered.Name = "Tampered"
+
+	a := newTestCatalog(t,
+		Item{Name: "first", Magnet: testMagnet(0)},
+		Item{Name: "again", Magnet: testMagnet(0) + "&dn=again"},
+		Item{Name: "bad magnet", Magnet: "magnet:?dn=x"},
+		[]byte(itemMarker+"\x01\x01\x09short"),
+		tampered,
+		signed,
+	)
+	b := newTestCatalog(t,
+		Item{Name: "b", Magnet: testMagnet(1)},
+		Item{Name: "b again", Magnet: testMagnet(1)},
+	)
+	out := newTestStorage(t)
+
+	report, err := Merge(a, b, out, MergeNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, item := range storedItems(t, out) {
+		names = append(names, item.Name)
+	}
+	if want := []string{"first", "Signed", "b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("merged %v, want %v", names, want)
+	}
+
+	for _, want := range []SkippedRecord{
+		{Source: "a", SourceID: 1, Reason: "same info hash as a:0"},
+		{Source: "a", SourceID: 2, Reason: ErrInvalidMagnet.Error()},
+		{Source: "a", SourceID: 3, Reason: ErrTruncated.Error()},
+		{Source: "a", SourceID: 4, Reason: ErrBadSignature.Error()},
+		{Source: "b", SourceID: 1, Reason: "same info hash as b:0"},
+	} {
+		found := false
+		for _, skipped := range report.Skipped {
+			if skipped.Source == want.Source && skipped.SourceID == want.SourceID && strings.Contains(skipped.Reason, want.Reason) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s:%d not skipped for %q, got %+v", want.Source, want.SourceID, want.Reason, report.Skipped)
+		}
+	}
+	if len(report.Skipped) != 5 {
+		t.Errorf("%d records skipped, want 5: %+v", len(report.Skipped), report.Skipped)
+	}
+}
+
+func TestMergeNonEmptyOutput(t *testing.T) {
+	a := newTestCatalog(t, Item{Name: "a", Magnet: testMagnet(0)})
+	b := newTestCatalog(t, Item{Name: "b", Magnet: testMagnet(1)})
+	out := newTestCatalog(t, Item{Name: "already there", Magnet: testMagnet(2)})
+
+	if _, err := Merge(a, b, out, MergeNewest); err == nil {
+		t.Fatal("merged into a catalog that is not empty")
+	}
+	if n := out.NumberOfChunks(); n != 1 {
+		t.Errorf("output holds %d chunks after a refused merge", n)
+	}
+}
+
+func TestMergeReportJSON(t *testing.T) {
+	a := newTestCatalog(t, Item{Name: "Sintel", Magnet: testMagnet(0), Added: 100})
+	b := newTestCatalog(t,
+		Item{Name: "Sintel.2010", Description: "d", Magnet: testMagnet(0), Added: 200},
+		Item{Name: "Other", Magnet: testMagnet(1)},
+	)
+	report, err := Merge(a, b, newTestStorage(t), MergeNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"policy": "newest",
+	"merged": [
+		{
+			"id": 0,
+			"source": "b",
+			"source_id": 0,
+			"info_hash": "` + testHashHex(t, 0) + `"
+		},
+		{
+			"id": 1,
+			"source": "b",
+			"source_id": 1,
+			"info_hash": "` + testHashHex(t, 1) + `"
+		}
+	],
+	"skipped": [
+		{
+			"source": "a",
+			"source_id": 0,
+			"reason": "same info hash as b:0"
+		}
+	],
+	"conflicts": [
+		{
+			"info_hash": "` + testHashHex(t, 0) + `",
+			"a": {
+				"id": 0,
+				"name": "Sintel",
+				"description": "",
+				"added": 100
+			},
+			"b": {
+				"id": 0,
+				"name": "Sintel.2010",
+				"description": "d",
+				"added": 200
+			},
+			"kept": "b"
+		}
+	]
+}`
+	if string(got) != want {
+		t.Errorf("report:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Empty lists are written as such, not as null.
+	empty, err := Merge(newTestStorage(t), newTestStorage(t), newTestStorage(t), MergePreferA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = json.Marshal(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"policy":"prefer-a","merged":[],"skipped":[],"conflicts":[]}`; string(got) != want {
+		t.Errorf("empty report %s, want %s", got, want)
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	for _, s := range []string{"newest", "longest", "prefer-a"} {
+		if policy, err := ParseMergePolicy(s); err != nil || string(policy) != s {
+			t.Errorf("%q: got %q, %v", s, policy, err)
+		}
+	}
+	for _, s := range []string{"", "Newest", "prefer-b", "oldest"} {
+		if _, err := ParseMergePolicy(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
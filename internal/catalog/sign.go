@@ -0,0 +1,82 @@
+package catalog
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsigned is returned by Verify for an item without a signature.
+	ErrUnsigned = errors.New("item is not signed")
+
+	// ErrBadSignature is returned by Verify for an item whose signature does
+	// not match its content or its publisher key.
+	ErrBadSignature = errors.New("invalid item signature")
+)
+
+// Sign sets the item's publisher key to the public half of key and signs
+// the item. The signature covers the encoding of every other field, unknown
+// ones included, so it must be redone after any change to the item.
+func (item *Item) Sign(key ed25519.PrivateKey) error {
+	item.PublisherKey = append([]byte(nil), key.Public().(ed25519.PublicKey)...)
+	item.Signature = nil
+
+	message, err := EncodeItem(*item)
+	if err != nil {
+		return err
+	}
+	item.Signature = ed25519.Sign(key, message)
+	return nil
+}
+
+// Verify checks the item's signature against its publisher key. It returns
+// ErrUnsigned if the item carries neither and ErrBadSignature if it does not
+// verify.
+func (item Item) Verify() error {
+	if len(item.PublisherKey) == 0 && len(item.Signature) == 0 {
+		return ErrUnsigned
+	}
+	if len(item.PublisherKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("publisher key of %d bytes: %w", len(item.PublisherKey), ErrBadSignature)
+	}
+
+	signature := item.Signature
+	item.Signature = nil
+	message, err := EncodeItem(item)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(item.PublisherKey, message, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Publisher is a publisher key trusted under an alias.
+type Publisher struct {
+	Alias     string
+	PublicKey ed25519.PublicKey
+}
+
+// Publishers is a trust store of publisher keys.
+type Publishers []Publisher
+
+// Lookup returns the trusted publisher that signed item. It returns false
+// for unsigned items and items signed by an unknown key, and an error for
+// items whose signature does not verify.
+func (publishers Publishers) Lookup(item Item) (Publisher, bool, error) {
+	if err := item.Verify(); errors.Is(err, ErrUnsigned) {
+		return Publisher{}, false, nil
+	} else if err != nil {
+		return Publisher{}, false, err
+	}
+
+	for _, publisher := range publishers {
+		if bytes.Equal(publisher.PublicKey, item.PublisherKey) {
+			return publisher, true, nil
+		}
+	}
+	return Publisher{}, false, nil
+}
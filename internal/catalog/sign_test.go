@@ -0,0 +1,127 @@
+package catalog
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+// testKey returns the publisher key derived from seed.
+func testKey(seed byte) ed25519.PrivateKey {
+	s := make([]byte, ed25519.SeedSize)
+	s[0] = seed
+	return ed25519.NewKeyFromSeed(s)
+}
+
+func TestSignVerify(t *testing.T) {
+	item := fullItem
+	item.Unknown = append([]Field(nil), fullItem.Unknown...)
+	if err := item.Sign(testKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := item.Verify(); err != nil {
+		t.Fatalf("signed item does not verify: %v", err)
+	}
+
+	// The signature survives storage.
+	b, err := EncodeItem(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeItem(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify(); err != nil {
+		t.Fatalf("decoded item does not verify: %v", err)
+	}
+
+	// Signing again with another key replaces the signature.
+	resigned := item
+	if err := resigned.Sign(testKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := resigned.Verify(); err != nil {
+		t.Fatalf("re-signed item does not verify: %v", err)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	signed := Item{Name: "Sintel", Description: "d", Magnet: "magnet:?xt=urn:btih:08ada5a7a6183aae1e09d831df6748d566095a10"}
+	if err := signed.Sign(testKey(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		tamper func(item *Item)
+	}{
+		{"name", func(item *Item) { item.Name += "!" }},
+		{"description", func(item *Item) { item.Description = "" }},
+		{"magnet", func(item *Item) { item.Magnet = "magnet:?xt=urn:btih:0000000000000000000000000000000000000001" }},
+		{"size", func(item *Item) { item.Size = 1 }},
+		{"file count", func(item *Item) { item.FileCount = 1 }},
+		{"added", func(item *Item) { item.Added = 1 }},
+		{"category", func(item *Item) { item.Category = "x" }},
+		{"poster", func(item *Item) { item.Poster = "x" }},
+		{"channel", func(item *Item) { item.Channel = "x" }},
+		{"unknown field", func(item *Item) { item.Unknown = []Field{{Type: 50, Value: []byte("x")}} }},
+		{"publisher key", func(item *Item) { item.PublisherKey = testKey(2).Public().(ed25519.PublicKey) }},
+		{"flipped signature bit", func(item *Item) {
+			item.Signature = append([]byte(nil), item.Signature...)
+			item.Signature[10] ^= 1
+		}},
+		{"short publisher key", func(item *Item) { item.PublisherKey = item.PublisherKey[:31] }},
+		{"no signature", func(item *Item) { item.Signature = nil }},
+		{"no publisher key", func(item *Item) { item.PublisherKey = nil }},
+	} {
+		item := signed
+		test.tamper(&item)
+		if err := item.Verify(); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%s: got %v, want ErrBadSignature", test.name, err)
+		}
+	}
+
+	if err := (Item{Name: "Unsigned"}).Verify(); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("unsigned item: got %v, want ErrUnsigned", err)
+	}
+}
+
+func TestPublishersLookup(t *testing.T) {
+	publishers := Publishers{
+		{Alias: "first", PublicKey: testKey(1).Public().(ed25519.PublicKey)},
+		{Alias: "second", PublicKey: testKey(2).Public().(ed25519.PublicKey)},
+	}
+	sign := func(key ed25519.PrivateKey) Item {
+		item := Item{Name: "Item", Magnet: "magnet:?xt=urn:btih:08ada5a7a6183aae1e09d831df6748d566095a10"}
+		if err := item.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+	tampered := sign(testKey(2))
+	tampered.Name = "Other"
+
+	for _, test := range []struct {
+		name    string
+		item    Item
+		alias   string
+		trusted bool
+		err     error
+	}{
+		{"first publisher", sign(testKey(1)), "first", true, nil},
+		{"second publisher", sign(testKey(2)), "second", true, nil},
+		{"unknown publisher", sign(testKey(3)), "", false, nil},
+		{"unsigned", Item{Name: "Item"}, "", false, nil},
+		{"tampered", tampered, "", false, ErrBadSignature},
+	} {
+		publisher, trusted, err := publishers.Lookup(test.item)
+		if publisher.Alias != test.alias || trusted != test.trusted || !errors.Is(err, test.err) || (err != nil && test.err == nil) {
+			t.Errorf("%s: got %q, %v, %v; want %q, %v, %v", test.name, publisher.Alias, trusted, err, test.alias, test.trusted, test.err)
+		}
+	}
+
+	if _, trusted, err := Publishers(nil).Lookup(sign(testKey(1))); trusted || err != nil {
+		t.Errorf("empty trust store: got %v, %v", trusted, err)
+	}
+}
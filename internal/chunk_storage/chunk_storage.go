@@ -1,171 +1,716 @@
 package chunk_storage
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
-	"log"
 	"os"
 	"path"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/edsrzf/mmap-go"
 )
 
-func New(storageDir, fileNamePrefix string) (*ChunkStorage, error) {
-	var storage ChunkStorage
+func New(storageDir, fileNamePrefix string, options Options) (*ChunkStorage, error) {
+	storage := ChunkStorage{options: options}
 
 	storageDirWithFilePrefix := path.Join(storageDir, fileNamePrefix)
 
-	if err := os.MkdirAll(storageDir, os.ModePerm); err != nil {
-		return nil, fmt.Errorf("making dir for w64system files: %v", err)
+	storage.Path = storageDirWithFilePrefix
+
+	if options.ReadOnly {
+		if _, err := os.Stat(compactionMarkerPath(storage.Path)); err == nil {
+			return nil, fmt.Errorf("storage '%s' has an unfinished compaction, open it writable first", storage.Path)
+		}
+	} else {
+		if err := os.MkdirAll(storageDir, os.ModePerm); err != nil {
+			return nil, fmt.Errorf("making dir for w64system files: %v", err)
+		}
+
+		if err := finishCompaction(storage.Path); err != nil {
+			return nil, err
+		}
 	}
 
-	storage.Path = storageDirWithFilePrefix
+	if err := storage.load(); err != nil {
+		return nil, err
+	}
+
+	if options.InfoHashFunc != nil {
+		index, err := storage.openHashIndex()
+		if err != nil {
+			_ = storage.closeSegments()
+			return nil, err
+		}
+		storage.hashes = index
+	} else if !options.ReadOnly {
+		// Chunks added now would not be indexed, and the index could not
+		// tell when the ones it covers get replaced.
+		if err := removeIfExists(hashIndexPath(storage.Path)); err != nil {
+			_ = storage.closeSegments()
+			return nil, fmt.Errorf("removing stale info hash index: %v", err)
+		}
+	}
+
+	return &storage, nil
+}
+
+func segmentPath(storagePath string, fileID int) string {
+	return fmt.Sprintf("%s%03d", storagePath, fileID)
+}
+
+// load opens every segment of the storage and rebuilds the index from them.
+func (cs *ChunkStorage) load() error {
+	cs.deleted = make(map[int]struct{})
 	activeChunkFileID := 0
 
 	for {
-		filepath := fmt.Sprintf("%s%03d", storageDirWithFilePrefix, activeChunkFileID)
+		filepath := segmentPath(cs.Path, activeChunkFileID)
 
-		f, err := os.OpenFile(filepath, os.O_CREATE|os.O_RDWR, 0644)
+		seg, dropped, err := openSegment(filepath, cs.options.Dictionary, cs.options.ReadOnly)
 		if err != nil {
-			return nil, fmt.Errorf("opening file '%s': %v", filepath, err)
+			cs.closeSegments()
+			return err
 		}
+		if dropped > 0 {
+			cs.recovered = append(cs.recovered, Recovery{Path: filepath, Dropped: dropped, Truncated: !cs.options.ReadOnly})
+		}
+
+		cs.segments = append(cs.segments, seg)
 
-		storage.file = append(storage.file, f)
+		_, err = os.Stat(segmentPath(cs.Path, activeChunkFileID+1))
+		last := os.IsNotExist(err)
 
-		var position int64 = -4
-		var chunkSize uint32 = 0
+		if err := cs.indexSegment(filepath, activeChunkFileID, last); err != nil {
+			cs.closeSegments()
+			return err
+		}
 
-		for {
-			position += int64(chunkSize + 4)
-			if _, errSeek := storage.file[activeChunkFileID].Seek(position, 0); errSeek != nil {
-				_ = storage.file[activeChunkFileID].Close() // ignore error; Write error takes precedence
-				return nil, fmt.Errorf("seeking in file '%s' at chunk ID %d: %v", filepath, activeChunkFileID, errSeek)
+		if last {
+			if seg.legacy {
+				cs.mapSegment(seg)
 			}
+			break
+		}
+
+		cs.mapSegment(seg)
+		activeChunkFileID++
+	}
+
+	return nil
+}
 
-			bufferChunkSize := make([]byte, 4)
+// indexSegment records the position and size of every chunk of segment
+// fileID, taking them from the sidecar index when it is up to date and
+// scanning the segment otherwise.
+func (cs *ChunkStorage) indexSegment(filepath string, fileID int, last bool) error {
+	seg := cs.segments[fileID]
 
-			if _, errRead := storage.file[activeChunkFileID].Read(bufferChunkSize); errRead == io.EOF {
-				break
-			} else if errRead != nil {
-				storage.file[activeChunkFileID].Close() // ignore error; Write error takes precedence
-				return nil, fmt.Errorf("reading file '%s' at chunk ID %d: %v", filepath, activeChunkFileID, errRead)
+	fileinfo, err := seg.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat of file '%s': %v", filepath, err)
+	}
+	seg.size = fileinfo.Size()
+
+	entries, acked, ok := readSidecar(sidecarPath(filepath))
+	fresh := ok && acked == seg.size
+	if fresh && last && !seg.legacy && len(entries) > 0 {
+		// Nothing orders the writes of a record and of its sidecar entry on
+		// disk, so the final record of the active segment is checked too.
+		final := entries[len(entries)-1]
+		intact, err := seg.recordIntact(final)
+		if err != nil {
+			return fmt.Errorf("reading file '%s': %v", filepath, err)
+		}
+		if !intact {
+			entries, fresh = entries[:len(entries)-1], false
+			if err := cs.recoverTail(filepath, fileID, int64(final.position), seg.size, false); err != nil {
+				return err
 			}
+		}
+	} else if !fresh {
+		if !ok {
+			acked = -1
+		}
+		if entries, err = cs.scanSegment(filepath, fileID, last, acked); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		if seg.legacy || e.sizeWord&recordFlagTombstone == 0 {
+			cs.position = append(cs.position, int64(e.position))
+			cs.size = append(cs.size, int64(e.chunkSize(seg.legacy)))
+			cs.fileID = append(cs.fileID, fileID)
+			continue
+		}
+
+		record := make([]byte, recordHeaderSize+tombstoneSize)
+		if _, err := seg.file.ReadAt(record, int64(e.position)); err != nil {
+			return fmt.Errorf("reading tombstone of '%s' at offset %d: %v", filepath, e.position, err)
+		}
+		// A damaged tombstone deletes nothing.
+		if payload, err := decodeRecord(record); err == nil {
+			cs.deleted[int(binary.LittleEndian.Uint32(payload))] = struct{}{}
+		}
+	}
+
+	// The sidecar only speeds up the next open; failing to write it just
+	// means scanning again.
+	switch {
+	case cs.options.ReadOnly:
+	case last && !seg.legacy:
+		seg.sidecar, _ = createSidecar(sidecarPath(filepath), entries, seg.size)
+	case !fresh:
+		_ = writeSidecar(sidecarPath(filepath), entries, seg.size)
+	}
+
+	return nil
+}
 
-			readerChunkSize := bytes.NewReader(bufferChunkSize)
+// scanSegment walks the records of segment fileID one by one. A record
+// running past the end of the file ends the segment: it is left out of the
+// index along with what follows, see recoverTail. So does a record of the
+// last segment whose checksum does not match, if it is the final one or
+// lies past acked, the segment size recorded by the sidecar, -1 without a
+// usable sidecar: such records may be what a crash left of an append.
+func (cs *ChunkStorage) scanSegment(filepath string, fileID int, last bool, acked int64) ([]sidecarEntry, error) {
+	seg := cs.segments[fileID]
+	fileSize := seg.size
+
+	headerSize := int64(recordHeaderSize)
+	position := seg.dataStart
+	if seg.legacy {
+		headerSize = legacyRecordHeaderSize
+		position = 0
+	}
 
-			_ = binary.Read(readerChunkSize, binary.LittleEndian, &chunkSize)
+	var entries []sidecarEntry
+	bufferChunkSize := make([]byte, 4)
 
-			storage.size = append(storage.size, int64(chunkSize))
-			storage.position = append(storage.position, int64(position))
-			storage.fileID = append(storage.fileID, activeChunkFileID)
+	for position < fileSize {
+		if position+headerSize > fileSize {
+			return entries, cs.recoverTail(filepath, fileID, position, fileSize, position == acked)
 		}
 
-		if _, err := os.Stat(fmt.Sprintf("%s%03d", storageDirWithFilePrefix, activeChunkFileID+1)); os.IsNotExist(err) {
-			break
+		if _, err := seg.file.ReadAt(bufferChunkSize, position); err != nil {
+			return nil, fmt.Errorf("reading file '%s' at offset %d: %v", filepath, position, err)
 		}
 
-		activeChunkFileID++
+		e := sidecarEntry{position: uint32(position), sizeWord: binary.LittleEndian.Uint32(bufferChunkSize)}
+		end := position + headerSize + int64(e.chunkSize(seg.legacy))
+
+		if end > fileSize {
+			return entries, cs.recoverTail(filepath, fileID, position, fileSize, position == acked)
+		}
+
+		if last && !seg.legacy && (end == fileSize || position >= acked) {
+			record := make([]byte, end-position)
+			if _, err := seg.file.ReadAt(record, position); err != nil {
+				return nil, fmt.Errorf("reading file '%s' at offset %d: %v", filepath, position, err)
+			}
+			if _, err := decodeRecord(record); err != nil {
+				return entries, cs.recoverTail(filepath, fileID, position, fileSize, position == acked)
+			}
+		}
+
+		entries = append(entries, e)
+		position = end
 	}
 
-	return &storage, nil
+	return entries, nil
+}
+
+// recordIntact reports whether the record of e matches its checksum.
+func (seg *segment) recordIntact(e sidecarEntry) (bool, error) {
+	end := int64(e.position) + recordHeaderSize + int64(e.chunkSize(false))
+	if end > seg.size {
+		return false, nil
+	}
+	record := make([]byte, end-int64(e.position))
+	if _, err := seg.file.ReadAt(record, int64(e.position)); err != nil {
+		return false, err
+	}
+	_, err := decodeRecord(record)
+	return err == nil, nil
+}
+
+// openSegment opens the segment file at filepath, creating it with a fresh
+// header carrying dict if it is missing or empty. Segments written before
+// checksums were introduced are detected by their missing header and
+// reopened read-only. A header cut short by a crash is rewritten; the number
+// of bytes it had is returned as dropped. In readOnly mode nothing is
+// written and such a segment is opened as holding no records.
+func openSegment(filepath string, dict []byte, readOnly bool) (seg *segment, dropped int64, err error) {
+	flag := os.O_CREATE | os.O_RDWR
+	if readOnly {
+		flag = os.O_RDONLY
+	}
+
+	f, err := os.OpenFile(filepath, flag, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening file '%s': %v", filepath, err)
+	}
+
+	fileinfo, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, fmt.Errorf("stat of file '%s': %v", filepath, err)
+	}
+
+	header := make([]byte, segmentHeaderSize)
+	n, _ := io.ReadFull(f, header)
+
+	switch {
+	case n == segmentHeaderSize && isSegmentHeader(header):
+		seg, err := readSegmentHeader(f, header, fileinfo.Size())
+		if err == nil {
+			return seg, 0, nil
+		}
+		if err != io.ErrUnexpectedEOF {
+			_ = f.Close()
+			return nil, 0, fmt.Errorf("file '%s': %v", filepath, err)
+		}
+	case n == 0 || (n < segmentHeaderSize && isTornSegmentHeader(header[:n])):
+	case readOnly:
+		return &segment{file: f, legacy: true}, 0, nil
+	default:
+		_ = f.Close()
+		if f, err = os.Open(filepath); err != nil {
+			return nil, 0, fmt.Errorf("opening legacy file '%s': %v", filepath, err)
+		}
+		return &segment{file: f, legacy: true}, 0, nil
+	}
+
+	// The file is empty or holds a header torn by a crash during creation.
+	if readOnly {
+		return &segment{file: f, dataStart: fileinfo.Size()}, fileinfo.Size(), nil
+	}
+
+	header = encodeSegmentHeader(dict)
+	if err := f.Truncate(0); err != nil {
+		_ = f.Close()
+		return nil, 0, fmt.Errorf("truncating torn header of '%s': %v", filepath, err)
+	}
+	if _, err := f.WriteAt(header, 0); err != nil {
+		_ = f.Close() // ignore error; Write error takes precedence
+		return nil, 0, fmt.Errorf("writing header of '%s': %v", filepath, err)
+	}
+
+	return &segment{file: f, dataStart: int64(len(header)), dict: dict}, fileinfo.Size(), nil
+}
+
+// readSegmentHeader parses the rest of a versioned segment header. It
+// returns io.ErrUnexpectedEOF if the file ends inside the header.
+func readSegmentHeader(f *os.File, header []byte, fileSize int64) (*segment, error) {
+	if version := binary.LittleEndian.Uint16(header[4:]); version != segmentVersion {
+		return nil, fmt.Errorf("unsupported segment version %d", version)
+	}
+
+	flags := binary.LittleEndian.Uint16(header[6:])
+	if flags&^knownSegmentFlags != 0 {
+		return nil, fmt.Errorf("unsupported segment flags %#x", flags)
+	}
+
+	seg := segment{file: f, dataStart: segmentHeaderSize}
+
+	if flags&segmentFlagDictionary != 0 {
+		bufferDictSize := make([]byte, 4)
+		if _, err := f.ReadAt(bufferDictSize, seg.dataStart); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		dictSize := int64(binary.LittleEndian.Uint32(bufferDictSize))
+		if seg.dataStart+4+dictSize > fileSize {
+			return nil, io.ErrUnexpectedEOF
+		}
+		seg.dict = make([]byte, dictSize)
+		if _, err := f.ReadAt(seg.dict, seg.dataStart+4); err != nil {
+			return nil, fmt.Errorf("reading dictionary: %v", err)
+		}
+		seg.dataStart += 4 + dictSize
+	}
+
+	return &seg, nil
 }
 
 const ChunkFileMaxSize = 20 * 1024 * 1024
 
-// ChunkStorage is
+// ChunkStorage is an append-only store of byte chunks spread over numbered
+// segment files, addressed by the order in which they were added. It is
+// safe for concurrent use: reads go through ReadAt and only hold the index
+// lock while looking up a chunk, so they proceed alongside an append.
 type ChunkStorage struct {
-	Path     string
-	file     []*os.File
+	Path    string
+	options Options
+
+	appendMu sync.Mutex   // serializes writes to the segments
+	mu       sync.RWMutex // guards segments and the index below
+	segments []*segment
 	position []int64
 	size     []int64
 	fileID   []int
+	deleted  map[int]struct{}
+
+	// generation counts compactions, which renumber the chunks.
+	generation int64
+
+	hashes *hashIndex // nil without Options.InfoHashFunc
+
+	recovered []Recovery
+	closed    bool
+}
+
+// segment is one w64systemNNN file of a ChunkStorage.
+type segment struct {
+	file    *os.File
+	legacy  bool     // pre-checksum layout, read-only
+	size    int64    // end of the last complete record, guarded by appendMu
+	sidecar *sidecar // open for appends on the active segment only
+	mapped  mmap.MMap
+	readers sync.WaitGroup // reads in progress, waited for before closing
+
+	dataStart  int64  // offset of the first record, after the header
+	dict       []byte // compression dictionary from the header
+	compressor *compressor
 }
 
 func (cs *ChunkStorage) NumberOfChunks() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
 	return len(cs.position)
 }
 
+// ReadOnly reports whether the storage was opened with Options.ReadOnly,
+// so that files derived from it are not written either.
+func (cs *ChunkStorage) ReadOnly() bool {
+	return cs.options.ReadOnly
+}
+
 func (cs *ChunkStorage) AddChunk(data []byte) error {
-	var activechunkfileid int
-	activechunkfileid = len(cs.file) - 1
-	var newchunkfile bool = false
-
-	//------------------------------
-	fileinfo, staterr := cs.file[activechunkfileid].Stat()
-	if staterr != nil {
-		log.Fatal(staterr)
-	}
-	//applog.Trace("size %d", fileinfo.Size())
-	if fileinfo.Size() > ChunkFileMaxSize {
-		activechunkfileid++
-		filepath := fmt.Sprintf("%s%03d", cs.Path, activechunkfileid)
-		f, err := os.OpenFile(filepath, os.O_CREATE|os.O_RDWR, 0644)
-		if err != nil {
-			log.Fatal(err)
+	_, _, err := cs.addChunk(data, false)
+	return err
+}
+
+// AddChunkIfAbsent appends data unless a chunk not deleted holds an item
+// with the same info hash, as extracted by Options.InfoHashFunc. It returns
+// the ID of the chunk added, or of the one found and false. Looking up and
+// appending happen under one lock, so of concurrent calls for an info hash
+// only one adds it. Chunks without an info hash are always added.
+func (cs *ChunkStorage) AddChunkIfAbsent(data []byte) (chunkid int, added bool, err error) {
+	return cs.addChunk(data, true)
+}
+
+func (cs *ChunkStorage) addChunk(data []byte, ifAbsent bool) (int, bool, error) {
+	if len(data) > MaxChunkSize {
+		return 0, false, fmt.Errorf("chunk of %d bytes exceeds the maximum of %d", len(data), MaxChunkSize)
+	}
+
+	cs.appendMu.Lock()
+	defer cs.appendMu.Unlock()
+
+	var hash metainfo.Hash
+	var hashed bool
+	if cs.options.InfoHashFunc != nil {
+		hash, hashed = cs.options.InfoHashFunc(data)
+	}
+	if ifAbsent && hashed {
+		if chunkid, ok := cs.LookupByInfoHash(hash); ok {
+			return chunkid, false, nil
+		}
+	}
+
+	fileID, seg, err := cs.activeSegment()
+	if err != nil {
+		return 0, false, err
+	}
+
+	var flags uint32
+	if cs.options.Compress {
+		if compressed, ok := seg.compress(data); ok {
+			flags, data = recordFlagCompressed, compressed
 		}
-		cs.file = append(cs.file, f)
-		newchunkfile = true
 	}
 
-	//------------------------------
+	record := encodeRecord(flags, data)
+	position, err := seg.appendRecord(record)
+	if err != nil {
+		return 0, false, err
+	}
 
-	bufferchunkfilesize := make([]byte, 4)
-	binary.LittleEndian.PutUint32(bufferchunkfilesize, uint32(len(data)))
-	_, serrs := cs.file[activechunkfileid].Seek(0, os.SEEK_END)
-	if serrs != nil {
-		cs.file[activechunkfileid].Close() // error
-		log.Fatal(serrs)
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.position = append(cs.position, position)
+	cs.size = append(cs.size, int64(len(data)))
+	cs.fileID = append(cs.fileID, fileID)
+	chunkid := len(cs.position) - 1
+	if cs.hashes != nil {
+		cs.hashes.add(chunkid, hash, hashed, chunkFingerprint(fileID, position, record[:recordHeaderSize]))
 	}
-	_, lerr := cs.file[activechunkfileid].Write(bufferchunkfilesize)
-	if lerr != nil {
-		cs.file[activechunkfileid].Close() // error
-		log.Fatal(lerr)
+	return chunkid, true, nil
+}
+
+// DeleteChunk marks chunk chunkid as deleted by appending a tombstone. The
+// chunk keeps its ID, reading it returns ErrDeleted, and its bytes stay on
+// disk until the next Compact.
+func (cs *ChunkStorage) DeleteChunk(chunkid int) error {
+	cs.appendMu.Lock()
+	defer cs.appendMu.Unlock()
+
+	cs.mu.RLock()
+	_, deleted := cs.deleted[chunkid]
+	numberOfChunks := len(cs.position)
+	cs.mu.RUnlock()
+
+	if chunkid < 0 || chunkid >= numberOfChunks {
+		return fmt.Errorf("chunk ID %d out of range", chunkid)
 	}
-	_, serrd := cs.file[activechunkfileid].Seek(0, os.SEEK_END)
-	if serrd != nil {
-		cs.file[activechunkfileid].Close() // error
-		log.Fatal(serrd)
+	if deleted {
+		return nil
 	}
 
-	_, err := cs.file[activechunkfileid].Write(data)
+	payload := make([]byte, tombstoneSize)
+	binary.LittleEndian.PutUint32(payload, uint32(chunkid))
+
+	_, seg, err := cs.activeSegment()
 	if err != nil {
-		cs.file[activechunkfileid].Close() // error
-		log.Fatal(err)
+		return err
+	}
+	if _, err := seg.appendRecord(encodeRecord(recordFlagTombstone, payload)); err != nil {
+		return err
 	}
 
-	if (!newchunkfile) && (cs.NumberOfChunks() >= 1) {
-		newposition := int64(int(cs.position[cs.NumberOfChunks()-1]+cs.size[cs.NumberOfChunks()-1]) + 4)
-		cs.position = append(cs.position, newposition)
-	} else {
-		cs.position = append(cs.position, int64(0))
+	cs.mu.Lock()
+	cs.deleted[chunkid] = struct{}{}
+	cs.mu.Unlock()
+
+	return nil
+}
+
+// IsDeleted reports whether chunk chunkid was removed by DeleteChunk.
+func (cs *ChunkStorage) IsDeleted(chunkid int) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_, deleted := cs.deleted[chunkid]
+	return deleted
+}
+
+// activeSegment returns the segment to append to, starting a new one when
+// the current one is full or read-only. The caller must hold appendMu.
+func (cs *ChunkStorage) activeSegment() (fileID int, seg *segment, err error) {
+	if cs.options.ReadOnly {
+		return 0, nil, ErrReadOnly
 	}
 
-	cs.size = append(cs.size, int64(len(data)))
+	cs.mu.RLock()
+	if cs.closed {
+		cs.mu.RUnlock()
+		return 0, nil, ErrClosed
+	}
+	fileID = len(cs.segments) - 1
+	seg = cs.segments[fileID]
+	cs.mu.RUnlock()
+
+	if seg.size <= ChunkFileMaxSize && !seg.legacy {
+		return fileID, seg, nil
+	}
 
-	cs.fileID = append(cs.fileID, activechunkfileid)
+	sealed := seg
+	sealed.closeSidecar()
 
-	return err
+	fileID++
+	filepath := segmentPath(cs.Path, fileID)
+	if seg, _, err = openSegment(filepath, cs.options.Dictionary, false); err != nil {
+		return 0, nil, fmt.Errorf("adding segment: %v", err)
+	}
+	seg.size = seg.dataStart
+	seg.sidecar, _ = createSidecar(sidecarPath(filepath), nil, seg.size)
+
+	cs.mu.Lock()
+	cs.segments = append(cs.segments, seg)
+	cs.mapSegment(sealed)
+	cs.mu.Unlock()
+
+	return fileID, seg, nil
+}
+
+// appendRecord writes an encoded record at the end of the segment. The
+// caller must hold appendMu.
+func (seg *segment) appendRecord(record []byte) (position int64, err error) {
+	position = seg.size
+
+	if _, err := seg.file.WriteAt(record, position); err != nil {
+		_ = seg.file.Truncate(position) // ignore error; Write error takes precedence
+		return 0, fmt.Errorf("writing chunk to '%s': %v", seg.file.Name(), err)
+	}
+	seg.size += int64(len(record))
+
+	if seg.sidecar != nil {
+		e := sidecarEntry{position: uint32(position), sizeWord: binary.LittleEndian.Uint32(record)}
+		if err := seg.sidecar.append(e, seg.size); err != nil {
+			seg.dropSidecar()
+		}
+	}
+
+	return position, nil
+}
+
+// GetChunkById returns the payload of chunk chunkid, decompressed if it was
+// stored compressed. Chunks in versioned segments are checked against their
+// stored checksum and ErrChecksum is returned on mismatch; deleted chunks
+// return ErrDeleted.
+func (cs *ChunkStorage) GetChunkById(chunkid int) ([]byte, error) {
+	return cs.getChunk(chunkid, -1)
 }
 
-func (cs *ChunkStorage) GetChunkById(chunkid int) []byte {
+// getChunk reads chunk chunkid, failing with ErrCompacted if the storage
+// is no longer at generation, unless generation is negative.
+func (cs *ChunkStorage) getChunk(chunkid int, generation int64) ([]byte, error) {
+	cs.mu.RLock()
+	if cs.closed {
+		cs.mu.RUnlock()
+		return nil, ErrClosed
+	}
+	if generation >= 0 && generation != cs.generation {
+		cs.mu.RUnlock()
+		return nil, ErrCompacted
+	}
+	if chunkid < 0 || chunkid >= len(cs.position) {
+		cs.mu.RUnlock()
+		return nil, fmt.Errorf("chunk ID %d out of range", chunkid)
+	}
+	if _, deleted := cs.deleted[chunkid]; deleted {
+		cs.mu.RUnlock()
+		return nil, fmt.Errorf("chunk ID %d: %w", chunkid, ErrDeleted)
+	}
+	position, size := cs.position[chunkid], cs.size[chunkid]
+	seg := cs.segments[cs.fileID[chunkid]]
+	legacy, dict := seg.legacy, seg.dict
+
+	if legacy {
+		return cs.readAt(seg, position+legacyRecordHeaderSize, size)
+	}
 
-	//applog.Trace("position %d size %d file %d", cs.position[chunkid]+4, cs.size[chunkid],cs.fileID[chunkid])
-	return cs.GetChunk(cs.position[chunkid]+4, cs.size[chunkid], cs.fileID[chunkid])
+	record, err := cs.readAt(seg, position, size+recordHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decodeRecord(record)
+	if err == nil && binary.LittleEndian.Uint32(record)&recordFlagCompressed != 0 {
+		data, err = decompress(data, dict)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("chunk ID %d: %w", chunkid, err)
+	}
+	return data, nil
 }
 
-func (cs *ChunkStorage) GetChunk(position int64, length int64, fileid int) []byte {
-	_, seekerr := cs.file[fileid].Seek(position, 0)
-	//check(err)
-	if seekerr != nil {
-		cs.file[fileid].Close() // ignore error; Write error takes precedence
-		log.Fatal(seekerr)
+// GetChunk reads length raw bytes at position of segment fileid.
+func (cs *ChunkStorage) GetChunk(position int64, length int64, fileid int) ([]byte, error) {
+	cs.mu.RLock()
+	if cs.closed {
+		cs.mu.RUnlock()
+		return nil, ErrClosed
 	}
+	if fileid < 0 || fileid >= len(cs.segments) {
+		cs.mu.RUnlock()
+		return nil, fmt.Errorf("segment %d out of range", fileid)
+	}
+	return cs.readAt(cs.segments[fileid], position, length)
+}
+
+// ChunkFingerprint returns a checksum of where chunk chunkid is stored and
+// of its record header, which holds its size and the checksum of its
+// payload. It tells whether a file derived from the storage was written for
+// the chunks it holds now, and does not change when the chunk is deleted.
+func (cs *ChunkStorage) ChunkFingerprint(chunkid int) (uint32, error) {
+	cs.mu.RLock()
+	if cs.closed {
+		cs.mu.RUnlock()
+		return 0, ErrClosed
+	}
+	if chunkid < 0 || chunkid >= len(cs.position) {
+		cs.mu.RUnlock()
+		return 0, fmt.Errorf("chunk ID %d out of range", chunkid)
+	}
+	fileID, position := cs.fileID[chunkid], cs.position[chunkid]
+	seg := cs.segments[fileID]
+	headerSize := int64(recordHeaderSize)
+	if seg.legacy {
+		headerSize = legacyRecordHeaderSize
+	}
+
+	header, err := cs.readAt(seg, position, headerSize)
+	if err != nil {
+		return 0, err
+	}
+	return chunkFingerprint(fileID, position, header), nil
+}
+
+func chunkFingerprint(fileID int, position int64, recordHeader []byte) uint32 {
+	location := make([]byte, 12)
+	binary.LittleEndian.PutUint32(location, uint32(fileID))
+	binary.LittleEndian.PutUint64(location[4:], uint64(position))
+	crc := crc32.Update(0, castagnoli, location)
+	return crc32.Update(crc, castagnoli, recordHeader)
+}
+
+// readAt reads length bytes at position of seg. The caller must hold mu for
+// reading, which readAt releases once it counts as a reader of seg: Compact
+// and Close wait for the readers of a segment before unmapping and closing
+// it, while appends need not wait for reads.
+func (cs *ChunkStorage) readAt(seg *segment, position, length int64) ([]byte, error) {
+	f, mapped := seg.file, seg.mapped
+	seg.readers.Add(1)
+	cs.mu.RUnlock()
+	defer seg.readers.Done()
+
+	if mapped != nil {
+		return copyMapped(mapped, f.Name(), position, length)
+	}
+
 	chunk := make([]byte, length)
-	_, readerr := cs.file[fileid].Read(chunk)
+	if _, err := f.ReadAt(chunk, position); err != nil {
+		return nil, fmt.Errorf("reading %d bytes of '%s' at offset %d: %v", length, f.Name(), position, err)
+	}
+	return chunk, nil
+}
+
+// Close closes all segment files. Reads and writes return ErrClosed from
+// then on.
+func (cs *ChunkStorage) Close() error {
+	cs.appendMu.Lock()
+	defer cs.appendMu.Unlock()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 
-	if readerr != nil {
-		cs.file[fileid].Close() // ignore error; Write error takes precedence
-		log.Fatal(readerr)
+	if cs.closed {
+		return nil
+	}
+	cs.closed = true
+	if cs.hashes != nil {
+		cs.hashes.close()
+	}
+	return cs.closeSegments()
+}
+
+// closeSegments closes and forgets all segment files once the reads in
+// progress are done. The caller must hold mu or be the only user of the
+// storage.
+func (cs *ChunkStorage) closeSegments() error {
+	var firstErr error
+	for _, seg := range cs.segments {
+		seg.readers.Wait()
+		seg.closeSidecar()
+		seg.unmap()
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
-	return chunk
+	cs.segments = nil
+	return firstErr
 }
@@ -0,0 +1,314 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// testInfoHash gives chunk i of testChunk an info hash derived from i.
+func testInfoHash(chunk []byte) (metainfo.Hash, bool) {
+	var hash metainfo.Hash
+	if len(chunk) < 14 {
+		return hash, false
+	}
+	i, err := strconv.Atoi(string(chunk[6:14]))
+	if err != nil {
+		return hash, false
+	}
+	binary.LittleEndian.PutUint64(hash[:], uint64(i)+1)
+	return hash, true
+}
+
+func testHash(i int) metainfo.Hash {
+	hash, _ := testInfoHash(testChunk(i, 100))
+	return hash
+}
+
+// TestConcurrentReadsAndWrites runs readers of every kind against a writer
+// adding and deleting chunks; run it with -race.
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	const chunks, size = 3000, 100
+
+	cs, err := New(t.TempDir(), "w", Options{InfoHashFunc: testInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	// Every fifth chunk gets deleted some time after being added.
+	deleted := func(chunkid int) bool { return chunkid%5 == 0 }
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < chunks; i++ {
+			if err := cs.AddChunk(testChunk(i, size)); err != nil {
+				t.Error(err)
+				return
+			}
+			if i >= 10 && deleted(i-10) {
+				if err := cs.DeleteChunk(i - 10); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+
+	readers := map[string]func() error{
+		"GetChunkById": func() error {
+			for chunkid := 0; chunkid < cs.NumberOfChunks(); chunkid += 7 {
+				chunk, err := cs.GetChunkById(chunkid)
+				if errors.Is(err, ErrDeleted) && deleted(chunkid) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(chunk, testChunk(chunkid, size)) {
+					return fmt.Errorf("chunk %d read as %q", chunkid, chunk)
+				}
+			}
+			return nil
+		},
+		"Scan": func() error {
+			previous := -1
+			it := cs.Scan(0, -1, false)
+			for it.Next() {
+				if it.ID() <= previous {
+					return fmt.Errorf("scan went back to chunk %d", it.ID())
+				}
+				previous = it.ID()
+				if !bytes.Equal(it.Bytes(), testChunk(it.ID(), size)) {
+					return fmt.Errorf("chunk %d scanned as %q", it.ID(), it.Bytes())
+				}
+			}
+			return it.Err()
+		},
+		"ReverseScan": func() error {
+			it := cs.Scan(0, -1, true)
+			for it.Next() {
+				if !bytes.Equal(it.Bytes(), testChunk(it.ID(), size)) {
+					return fmt.Errorf("chunk %d scanned as %q", it.ID(), it.Bytes())
+				}
+			}
+			return it.Err()
+		},
+		"LookupByInfoHash": func() error {
+			for i := 0; i < cs.NumberOfChunks(); i++ {
+				chunkid, ok := cs.LookupByInfoHash(testHash(i))
+				if ok && chunkid != i {
+					return fmt.Errorf("info hash of chunk %d found at %d", i, chunkid)
+				}
+				if !ok && !deleted(i) {
+					return fmt.Errorf("info hash of chunk %d not found", i)
+				}
+			}
+			return nil
+		},
+	}
+	for name, read := range readers {
+		for r := 0; r < 2; r++ {
+			wg.Add(1)
+			go func(name string, read func() error) {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if err := read(); err != nil {
+						t.Errorf("%s: %v", name, err)
+						return
+					}
+				}
+			}(name, read)
+		}
+	}
+	wg.Wait()
+
+	for chunkid := 0; chunkid < chunks; chunkid++ {
+		want := deleted(chunkid) && chunkid < chunks-10
+		if cs.IsDeleted(chunkid) != want {
+			t.Errorf("chunk %d: deleted %v, want %v", chunkid, !want, want)
+		}
+	}
+	for name, read := range readers {
+		if err := read(); err != nil {
+			t.Errorf("%s after writing: %v", name, err)
+		}
+	}
+}
+
+func TestAddChunkIfAbsent(t *testing.T) {
+	const adders, chunks, size = 4, 100, 100
+
+	cs, err := New(t.TempDir(), "w", Options{InfoHashFunc: testInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	var mu sync.Mutex
+	added := make(map[int]int)
+	var wg sync.WaitGroup
+	for a := 0; a < adders; a++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < chunks; i++ {
+				chunkid, ok, err := cs.AddChunkIfAbsent(testChunk(i, size))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if ok {
+					mu.Lock()
+					added[i]++
+					mu.Unlock()
+				}
+				if got, err := cs.GetChunkById(chunkid); err != nil || !bytes.Equal(got, testChunk(i, size)) {
+					t.Errorf("chunk %d returned as %d", i, chunkid)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := cs.NumberOfChunks(); n != chunks {
+		t.Errorf("%d chunks stored, want %d", n, chunks)
+	}
+	for i := 0; i < chunks; i++ {
+		if added[i] != 1 {
+			t.Errorf("chunk %d added %d times", i, added[i])
+		}
+	}
+
+	// A deleted chunk's info hash may be added again, and chunks without
+	// one always are.
+	if err := cs.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	if chunkid, ok, err := cs.AddChunkIfAbsent(testChunk(0, size)); err != nil || !ok || chunkid != chunks {
+		t.Errorf("deleted chunk added again as %d, %v, %v", chunkid, ok, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, ok, err := cs.AddChunkIfAbsent([]byte("no hash")); err != nil || !ok {
+			t.Errorf("chunk without an info hash not added: %v", err)
+		}
+	}
+}
+
+// TestHashIndexRebuilt opens storages whose info hash index is damaged or
+// belongs to other chunks and checks that it is rebuilt.
+func TestHashIndexRebuilt(t *testing.T) {
+	const chunks, size = 20, 100
+
+	// other is a storage of the same layout holding other chunks.
+	other := t.TempDir()
+	cs, err := New(other, "w", Options{InfoHashFunc: testInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < chunks; i++ {
+		if err := cs.AddChunk(testChunk(chunks+i, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stale := readTestFile(t, hashIndexPath(path.Join(other, "w")))
+
+	for _, test := range []struct {
+		name   string
+		damage func(index []byte) []byte
+	}{
+		{"flipped hash", func(index []byte) []byte {
+			index[hashIndexHeaderSize+3*hashIndexEntrySize+5] ^= 1
+			return index
+		}},
+		{"flipped fingerprint", func(index []byte) []byte {
+			index[len(index)-6] ^= 1
+			return index
+		}},
+		{"torn entry", func(index []byte) []byte {
+			return index[:len(index)-3]
+		}},
+		{"other storage", func([]byte) []byte { return stale }},
+		{"other version", func(index []byte) []byte {
+			index[4] = 1
+			return index
+		}},
+	} {
+		dir := t.TempDir()
+		writeStore(t, dir, chunks, size)
+		cs, err := New(dir, "w", Options{InfoHashFunc: testInfoHash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+		filepath := hashIndexPath(path.Join(dir, "w"))
+		want := readTestFile(t, filepath)
+		writeTestFile(t, filepath, test.damage(append([]byte(nil), want...)))
+
+		cs, err = New(dir, "w", Options{InfoHashFunc: testInfoHash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < chunks; i++ {
+			if chunkid, ok := cs.LookupByInfoHash(testHash(i)); !ok || chunkid != i {
+				t.Errorf("%s: chunk %d looked up at %d, %v", test.name, i, chunkid, ok)
+			}
+			if _, ok := cs.LookupByInfoHash(testHash(chunks + i)); ok {
+				t.Errorf("%s: info hash of chunk %d of the other storage found", test.name, chunks+i)
+			}
+		}
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := readTestFile(t, filepath); !bytes.Equal(got, want) {
+			t.Errorf("%s: index not rewritten", test.name)
+		}
+	}
+
+	// Deleting a chunk leaves the index valid.
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	cs, err = New(dir, "w", Options{InfoHashFunc: testInfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.DeleteChunk(chunks - 1); err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint, err := cs.ChunkFingerprint(chunks - 1); err != nil || fingerprint == 0 {
+		t.Fatalf("fingerprint of a deleted chunk: %x, %v", fingerprint, err)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cs, err = New(dir, "w", Options{InfoHashFunc: testInfoHash, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	covered := cs.coveredByHashIndex(readTestFile(t, hashIndexPath(cs.Path))[hashIndexHeaderSize:], chunks)
+	if covered != chunks {
+		t.Errorf("index covers %d chunks after a deletion, want %d", covered, chunks)
+	}
+}
@@ -0,0 +1,295 @@
+package chunk_storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Compaction writes the surviving chunks to "<segment>.compact" files next
+// to the live segments. Once they are all synced, a marker file holding the
+// number of new segments is written; from then on the compaction counts as
+// done and New finishes renaming the files into place if the process dies
+// halfway through the swap, dropping the info hash index and derived files
+// along the way. Without the marker, leftover .compact files are
+// discarded and the old segments stay authoritative.
+const compactSuffix = ".compact"
+
+func compactionMarkerPath(storagePath string) string {
+	return storagePath + ".compacted"
+}
+
+// Compact rewrites the storage into fresh segments without deleted chunks
+// and swaps them in place of the old ones. Legacy segments are converted to
+// the current format and every chunk is rewritten with the compression
+// settings of the storage's Options, so Compact also migrates a storage to
+// or from compression. Chunk IDs are renumbered, so IDs obtained
+// before Compact must not be used after it. Writers block while compaction
+// runs; readers keep using the old segments until the swap.
+func (cs *ChunkStorage) Compact() error {
+	if cs.options.ReadOnly {
+		return ErrReadOnly
+	}
+
+	cs.appendMu.Lock()
+	defer cs.appendMu.Unlock()
+
+	cs.mu.RLock()
+	closed := cs.closed
+	cs.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	w := compactWriter{storagePath: cs.Path, options: cs.options}
+
+	for chunkid, n := 0, cs.NumberOfChunks(); chunkid < n; chunkid++ {
+		data, err := cs.GetChunkById(chunkid)
+		if errors.Is(err, ErrDeleted) {
+			continue
+		}
+		if err == nil {
+			err = w.add(data)
+		}
+		if err != nil {
+			w.abort()
+			return fmt.Errorf("compacting: %w", err)
+		}
+	}
+
+	count, err := w.finish()
+	if err != nil {
+		w.abort()
+		return fmt.Errorf("compacting: %v", err)
+	}
+
+	marker := compactionMarkerPath(cs.Path)
+	if err := os.WriteFile(marker, []byte(strconv.Itoa(count)), 0644); err != nil {
+		w.abort()
+		return fmt.Errorf("writing compaction marker '%s': %v", marker, err)
+	}
+
+	if err := cs.swapCompacted(); err != nil {
+		return err
+	}
+
+	if cs.options.InfoHashFunc == nil {
+		return nil
+	}
+	index, err := cs.openHashIndex()
+	if err != nil {
+		return err
+	}
+	cs.mu.Lock()
+	cs.hashes = index
+	cs.mu.Unlock()
+	return nil
+}
+
+// swapCompacted replaces the segments with the compacted ones and reloads
+// them. The info hash index refers to the old IDs, so it is dropped until
+// Compact rebuilds it.
+func (cs *ChunkStorage) swapCompacted() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	_ = cs.closeSegments() // ignore error; the files are replaced below
+	if cs.hashes != nil {
+		cs.hashes.close()
+		cs.hashes = nil
+	}
+
+	err := finishCompaction(cs.Path)
+
+	cs.position, cs.size, cs.fileID = nil, nil, nil
+	cs.generation++
+	if loadErr := cs.load(); err == nil {
+		err = loadErr
+	}
+	return err
+}
+
+// finishCompaction completes or rolls back a compaction interrupted by a
+// crash, depending on whether its marker made it to disk.
+func finishCompaction(storagePath string) error {
+	marker := compactionMarkerPath(storagePath)
+
+	content, err := os.ReadFile(marker)
+	if os.IsNotExist(err) {
+		for fileID := 0; ; fileID++ {
+			err := os.Remove(segmentPath(storagePath, fileID) + compactSuffix)
+			if os.IsNotExist(err) {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("removing unfinished compaction: %v", err)
+			}
+		}
+	} else if err != nil {
+		return fmt.Errorf("reading compaction marker '%s': %v", marker, err)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || count < 1 {
+		return fmt.Errorf("invalid compaction marker '%s'", marker)
+	}
+
+	if err := removeIfExists(hashIndexPath(storagePath)); err != nil {
+		return fmt.Errorf("removing stale info hash index: %v", err)
+	}
+	if err := removeDerivedFiles(storagePath); err != nil {
+		return err
+	}
+
+	for fileID := 0; fileID < count; fileID++ {
+		compacted := segmentPath(storagePath, fileID) + compactSuffix
+		if _, err := os.Stat(compacted); os.IsNotExist(err) {
+			continue // renamed before the interruption
+		}
+		if err := removeIfExists(sidecarPath(segmentPath(storagePath, fileID))); err != nil {
+			return fmt.Errorf("removing stale sidecar index: %v", err)
+		}
+		if err := os.Rename(compacted, segmentPath(storagePath, fileID)); err != nil {
+			return fmt.Errorf("swapping in compacted segment: %v", err)
+		}
+	}
+
+	for fileID := count; ; fileID++ {
+		if err := removeIfExists(sidecarPath(segmentPath(storagePath, fileID))); err != nil {
+			return fmt.Errorf("removing stale sidecar index: %v", err)
+		}
+		err := os.Remove(segmentPath(storagePath, fileID))
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("removing stale segment: %v", err)
+		}
+	}
+
+	syncDir(path.Dir(storagePath))
+
+	if err := os.Remove(marker); err != nil {
+		return fmt.Errorf("removing compaction marker '%s': %v", marker, err)
+	}
+	return nil
+}
+
+func removeIfExists(filepath string) error {
+	if err := os.Remove(filepath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// syncDir flushes directory entries after renames. Not every platform can
+// sync a directory, so failures are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
+
+// compactWriter writes records to a sequence of .compact segment files.
+type compactWriter struct {
+	storagePath string
+	options     Options
+	compressor  *compressor
+	count       int
+	file        *os.File
+	buf         *bufio.Writer
+	size        int64
+}
+
+func (w *compactWriter) add(data []byte) error {
+	if w.file == nil || w.size > ChunkFileMaxSize {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+
+	var flags uint32
+	if w.options.Compress {
+		if w.compressor == nil {
+			w.compressor = newCompressor(w.options.Dictionary)
+		}
+		if compressed, ok := w.compressor.compress(data); ok {
+			flags, data = recordFlagCompressed, compressed
+		}
+	}
+
+	record := encodeRecord(flags, data)
+	if _, err := w.buf.Write(record); err != nil {
+		return fmt.Errorf("writing '%s': %v", w.file.Name(), err)
+	}
+	w.size += int64(len(record))
+	return nil
+}
+
+// next seals the current file and starts the following one.
+func (w *compactWriter) next() error {
+	if err := w.seal(); err != nil {
+		return err
+	}
+
+	filepath := segmentPath(w.storagePath, w.count) + compactSuffix
+	f, err := os.OpenFile(filepath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("creating '%s': %v", filepath, err)
+	}
+
+	header := encodeSegmentHeader(w.options.Dictionary)
+	w.file, w.buf, w.size = f, bufio.NewWriter(f), int64(len(header))
+	w.count++
+
+	if _, err := w.buf.Write(header); err != nil {
+		return fmt.Errorf("writing header of '%s': %v", filepath, err)
+	}
+	return nil
+}
+
+func (w *compactWriter) seal() error {
+	if w.file == nil {
+		return nil
+	}
+
+	f := w.file
+	w.file = nil
+
+	if err := w.buf.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing '%s': %v", f.Name(), err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("syncing '%s': %v", f.Name(), err)
+	}
+	return f.Close()
+}
+
+// finish seals the last file and returns the number of files written. An
+// empty storage still gets one segment holding just the header.
+func (w *compactWriter) finish() (int, error) {
+	if w.count == 0 {
+		if err := w.next(); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.seal(); err != nil {
+		return 0, err
+	}
+	return w.count, nil
+}
+
+func (w *compactWriter) abort() {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	for fileID := 0; fileID < w.count; fileID++ {
+		_ = os.Remove(segmentPath(w.storagePath, fileID) + compactSuffix)
+	}
+}
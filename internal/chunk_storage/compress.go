@@ -0,0 +1,135 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// MaxDictionarySize is the largest useful compression dictionary: deflate
+// can only refer back this far.
+const MaxDictionarySize = 32 * 1024
+
+// compressor deflates chunks for one segment, primed with its dictionary.
+// It is not safe for concurrent use; segments only use it under appendMu.
+type compressor struct {
+	w   *flate.Writer
+	buf bytes.Buffer
+}
+
+func newCompressor(dict []byte) *compressor {
+	var c compressor
+	c.w, _ = flate.NewWriterDict(&c.buf, flate.BestCompression, dict) // only fails on a bad level
+	return &c
+}
+
+// compress returns data deflated, and false if that does not make it smaller.
+func (c *compressor) compress(data []byte) ([]byte, bool) {
+	c.buf.Reset()
+	c.w.Reset(&c.buf)
+
+	if _, err := c.w.Write(data); err != nil {
+		return nil, false
+	}
+	if err := c.w.Close(); err != nil {
+		return nil, false
+	}
+	if c.buf.Len() >= len(data) {
+		return nil, false
+	}
+	return append([]byte(nil), c.buf.Bytes()...), true
+}
+
+func (seg *segment) compress(data []byte) ([]byte, bool) {
+	if seg.compressor == nil {
+		seg.compressor = newCompressor(seg.dict)
+	}
+	return seg.compressor.compress(data)
+}
+
+var decompressors sync.Pool
+
+func decompress(data, dict []byte) ([]byte, error) {
+	src := bytes.NewReader(data)
+
+	r, ok := decompressors.Get().(io.ReadCloser)
+	if ok {
+		_ = r.(flate.Resetter).Reset(src, dict) // never fails for a flate reader
+	} else {
+		r = flate.NewReaderDict(src, dict)
+	}
+	defer decompressors.Put(r)
+
+	chunk, err := io.ReadAll(io.LimitReader(r, MaxChunkSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing: %v", err)
+	}
+	if len(chunk) > MaxChunkSize {
+		return nil, fmt.Errorf("decompressing: chunk exceeds %d bytes", MaxChunkSize)
+	}
+	return chunk, nil
+}
+
+// TrainDictionary builds a compression dictionary of at most size bytes
+// from sample chunks. It collects the tokens, split before '&' and ' ',
+// that recur across samples, such as tracker parameters of magnet URIs, and
+// keeps those that save the most bytes. The most valuable ones go last,
+// where deflate reaches them with the shortest distances.
+func TrainDictionary(samples [][]byte, size int) []byte {
+	if size > MaxDictionarySize {
+		size = MaxDictionarySize
+	}
+
+	counts := make(map[string]int)
+	for _, sample := range samples {
+		seen := make(map[string]bool)
+		start := 0
+		for i := 1; i <= len(sample); i++ {
+			if i < len(sample) && sample[i] != '&' && sample[i] != ' ' {
+				continue
+			}
+			if token := string(sample[start:i]); len(token) >= 4 && !seen[token] {
+				seen[token] = true
+				counts[token]++
+			}
+			start = i
+		}
+	}
+
+	type candidate struct {
+		token string
+		score int
+	}
+
+	var candidates []candidate
+	for token, count := range counts {
+		if count > 1 {
+			candidates = append(candidates, candidate{token, (count - 1) * len(token)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].token < candidates[j].token
+	})
+
+	var picked []string
+	total := 0
+	for _, c := range candidates {
+		if total+len(c.token) > size {
+			continue
+		}
+		picked = append(picked, c.token)
+		total += len(c.token)
+	}
+
+	dict := make([]byte, 0, total)
+	for i := len(picked) - 1; i >= 0; i-- {
+		dict = append(dict, picked[i]...)
+	}
+	return dict
+}
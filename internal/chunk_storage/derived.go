@@ -0,0 +1,39 @@
+package chunk_storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// derivedInfix separates the storage path from the name of a derived file.
+const derivedInfix = ".x-"
+
+// DerivedPath returns the path at which an index built from the chunks of
+// the storage, such as a search index, should be kept. Compaction renumbers
+// chunks, so it removes every derived file; their owners must rebuild them
+// when they find them missing.
+func (cs *ChunkStorage) DerivedPath(name string) string {
+	return cs.Path + derivedInfix + name
+}
+
+func removeDerivedFiles(storagePath string) error {
+	dir, base := filepath.Split(storagePath)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("listing derived files: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), base+derivedInfix) {
+			if err := removeIfExists(filepath.Join(dir, entry.Name())); err != nil {
+				return fmt.Errorf("removing derived file: %v", err)
+			}
+		}
+	}
+	return nil
+}
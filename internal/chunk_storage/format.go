@@ -0,0 +1,126 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// On-disk layout of a w64system segment (version 2):
+//
+//	segment header: magic "W64S" | version uint16 | flags uint16
+//	                [dictionary size uint32 | dictionary]
+//	record:         flags|size uint32 | crc32c uint32 | payload[size]
+//
+// The CRC32C covers the size word and the payload, so both a flipped bit in
+// the data and a corrupted length are detected. A tombstone record carries
+// the ID of the chunk it deletes as a uint32 payload and takes no ID itself.
+// A compressed record holds the deflate stream of the chunk, primed with the
+// segment's dictionary when the header has one.
+//
+// Legacy (version 1) segments have no header and store records as
+// size uint32 | payload[size]; they are still readable but never written to.
+const (
+	segmentMagic      = "W64S"
+	segmentVersion    = 2
+	segmentHeaderSize = 8
+
+	segmentFlagDictionary = 1 << 0
+	knownSegmentFlags     = segmentFlagDictionary
+
+	recordHeaderSize       = 8
+	legacyRecordHeaderSize = 4
+
+	// MaxChunkSize is the largest payload AddChunk accepts. The upper bits of
+	// the size word are reserved for record flags.
+	MaxChunkSize = 1<<28 - 1
+
+	recordSizeMask       = MaxChunkSize
+	recordFlagTombstone  = 1 << 31
+	recordFlagCompressed = 1 << 30
+
+	tombstoneSize = 4
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrChecksum is returned when a chunk read back from disk does not
+	// match the checksum stored alongside it.
+	ErrChecksum = errors.New("chunk checksum mismatch")
+
+	// ErrDeleted is returned when reading a chunk removed by DeleteChunk.
+	ErrDeleted = errors.New("chunk deleted")
+
+	// ErrCompacted is returned by an Iterator whose storage was compacted
+	// while it ran, which renumbers the chunks under it.
+	ErrCompacted = errors.New("storage compacted during scan")
+
+	// ErrReadOnly is returned by writes to a storage opened with
+	// Options.ReadOnly.
+	ErrReadOnly = errors.New("storage opened read-only")
+
+	// ErrClosed is returned by reads and writes after Close.
+	ErrClosed = errors.New("storage closed")
+)
+
+func encodeSegmentHeader(dict []byte) []byte {
+	if len(dict) == 0 {
+		header := make([]byte, segmentHeaderSize)
+		copy(header, segmentMagic)
+		binary.LittleEndian.PutUint16(header[4:], segmentVersion)
+		return header
+	}
+
+	header := make([]byte, segmentHeaderSize+4+len(dict))
+	copy(header, segmentMagic)
+	binary.LittleEndian.PutUint16(header[4:], segmentVersion)
+	binary.LittleEndian.PutUint16(header[6:], segmentFlagDictionary)
+	binary.LittleEndian.PutUint32(header[segmentHeaderSize:], uint32(len(dict)))
+	copy(header[segmentHeaderSize+4:], dict)
+	return header
+}
+
+// isTornSegmentHeader reports whether b, the whole content of a file, is
+// the beginning of a segment header cut short while the segment was being
+// created.
+func isTornSegmentHeader(b []byte) bool {
+	prefix := encodeSegmentHeader(nil)[:6] // magic and version
+	if len(b) < len(prefix) {
+		return bytes.HasPrefix(prefix, b)
+	}
+	return bytes.HasPrefix(b, prefix)
+}
+
+// isSegmentHeader reports whether b starts with the magic of a versioned
+// segment. A legacy segment starts with the size of its first chunk, which
+// can never be as large as the magic read as a little endian uint32.
+func isSegmentHeader(b []byte) bool {
+	return len(b) >= len(segmentMagic) && string(b[:len(segmentMagic)]) == segmentMagic
+}
+
+func encodeRecord(flags uint32, data []byte) []byte {
+	record := make([]byte, recordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(record, flags|uint32(len(data)))
+	copy(record[recordHeaderSize:], data)
+	binary.LittleEndian.PutUint32(record[4:], recordChecksum(record[:4], data))
+	return record
+}
+
+func recordChecksum(sizeWord, data []byte) uint32 {
+	crc := crc32.Update(0, castagnoli, sizeWord)
+	return crc32.Update(crc, castagnoli, data)
+}
+
+// decodeRecord checks a full record read from disk and returns its payload.
+func decodeRecord(record []byte) ([]byte, error) {
+	if len(record) < recordHeaderSize {
+		return nil, ErrChecksum
+	}
+	data := record[recordHeaderSize:]
+	if binary.LittleEndian.Uint32(record[4:]) != recordChecksum(record[:4], data) {
+		return nil, ErrChecksum
+	}
+	return data, nil
+}
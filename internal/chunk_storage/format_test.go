@@ -0,0 +1,189 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	for _, data := range [][]byte{nil, []byte("x"), testChunk(1, 1000)} {
+		for _, flags := range []uint32{0, recordFlagCompressed, recordFlagTombstone} {
+			record := encodeRecord(flags, data)
+			if sizeWord := binary.LittleEndian.Uint32(record); sizeWord != flags|uint32(len(data)) {
+				t.Errorf("size word %#x, want flags %#x and size %d", sizeWord, flags, len(data))
+			}
+			got, err := decodeRecord(record)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("decoding %d bytes with flags %#x: got %q, %v", len(data), flags, got, err)
+			}
+
+			// Any flipped bit, in the size word, the checksum or the
+			// payload, is caught.
+			for i := range record {
+				damaged := append([]byte(nil), record...)
+				damaged[i] ^= 0x10
+				if _, err := decodeRecord(damaged); !errors.Is(err, ErrChecksum) {
+					t.Errorf("byte %d of %d flipped: got %v, want ErrChecksum", i, len(record), err)
+				}
+			}
+		}
+	}
+
+	if _, err := decodeRecord(make([]byte, recordHeaderSize-1)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("short record: got %v, want ErrChecksum", err)
+	}
+}
+
+func TestSegmentRoundTrip(t *testing.T) {
+	dict := []byte("chunk 0000 xxxxxxxx")
+
+	for _, options := range []Options{{}, {Compress: true, Dictionary: dict}} {
+		dir := t.TempDir()
+		cs, err := New(dir, "w", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100; i++ {
+			if err := cs.AddChunk(testChunk(i, 100)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		content := readTestFile(t, segmentPath(path.Join(dir, "w"), 0))
+		if !bytes.Equal(content[:len(encodeSegmentHeader(options.Dictionary))], encodeSegmentHeader(options.Dictionary)) {
+			t.Fatalf("segment starts with %q, want a version %d header", content[:segmentHeaderSize], segmentVersion)
+		}
+
+		// Reading needs neither the options nor the sidecar.
+		if err := os.Remove(sidecarPath(segmentPath(path.Join(dir, "w"), 0))); err != nil {
+			t.Fatal(err)
+		}
+		cs, err = New(dir, "w", Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkChunks(t, cs, 100, 100)
+		segments := cs.Segments()
+		if len(segments) != 1 || segments[0].Legacy || segments[0].Dictionary != len(options.Dictionary) {
+			t.Errorf("got segments %+v", segments)
+		}
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLegacySegment(t *testing.T) {
+	const chunks, size = 50, 100
+
+	dir := t.TempDir()
+	var legacy []byte
+	for i := 0; i < chunks; i++ {
+		sizeWord := make([]byte, legacyRecordHeaderSize)
+		binary.LittleEndian.PutUint32(sizeWord, size)
+		legacy = append(append(legacy, sizeWord...), testChunk(i, size)...)
+	}
+	segment := segmentPath(path.Join(dir, "w"), 0)
+	writeTestFile(t, segment, legacy)
+
+	cs, err := New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, cs, chunks, size)
+
+	// Legacy segments are never written to: new chunks go to a versioned
+	// segment after them.
+	for i := chunks; i < 2*chunks; i++ {
+		if err := cs.AddChunk(testChunk(i, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, segment); !bytes.Equal(got, legacy) {
+		t.Fatal("legacy segment modified")
+	}
+
+	cs, err = New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	checkChunks(t, cs, 2*chunks, size)
+	segments := cs.Segments()
+	if len(segments) != 2 || !segments[0].Legacy || segments[1].Legacy {
+		t.Fatalf("got segments %+v, want a legacy one then a versioned one", segments)
+	}
+	if content := readTestFile(t, segmentPath(path.Join(dir, "w"), 1)); !isSegmentHeader(content) {
+		t.Fatalf("second segment starts with %q", content[:segmentHeaderSize])
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	const chunks, size, damaged = 10, 100, 4
+
+	dir := t.TempDir()
+	cs, err := New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < chunks; i++ {
+		if err := cs.AddChunk(testChunk(i, size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segment := segmentPath(path.Join(dir, "w"), 0)
+	content := readTestFile(t, segment)
+	content[segmentHeaderSize+damaged*(recordHeaderSize+size)+recordHeaderSize+20] ^= 1
+	writeTestFile(t, segment, content)
+
+	cs, err = New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	if got := cs.NumberOfChunks(); got != chunks {
+		t.Fatalf("got %d chunks, want %d", got, chunks)
+	}
+	for chunkid := 0; chunkid < chunks; chunkid++ {
+		chunk, err := cs.GetChunkById(chunkid)
+		if chunkid == damaged {
+			if !errors.Is(err, ErrChecksum) {
+				t.Errorf("damaged chunk: got %q, %v, want ErrChecksum", chunk, err)
+			}
+		} else if err != nil || !bytes.Equal(chunk, testChunk(chunkid, size)) {
+			t.Errorf("chunk %d: got %q, %v", chunkid, chunk, err)
+		}
+	}
+
+	it := cs.Scan(0, -1, false)
+	for it.Next() {
+	}
+	if !errors.Is(it.Err(), ErrChecksum) || it.ID() != damaged {
+		t.Errorf("scan ended at chunk %d with %v, want ErrChecksum at %d", it.ID(), it.Err(), damaged)
+	}
+}
+
+func TestUnsupportedSegmentVersion(t *testing.T) {
+	dir := t.TempDir()
+	header := encodeSegmentHeader(nil)
+	binary.LittleEndian.PutUint16(header[4:], segmentVersion+1)
+	writeTestFile(t, segmentPath(path.Join(dir, "w"), 0), header)
+
+	if _, err := New(dir, "w", Options{}); err == nil {
+		t.Fatal("opened a segment of an unknown version")
+	}
+}
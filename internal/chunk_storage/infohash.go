@@ -0,0 +1,211 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// The info hash index of a storage lives in "<Path>.ihash":
+//
+//	header: magic "W64H" | version uint16 | reserved uint16
+//	entry:  present uint8 | info hash [20]byte | chunk fingerprint uint32 |
+//	        crc32c uint32
+//
+// Entry i belongs to chunk i, so the file doubles as a record of how many
+// chunks it covers. Each entry carries the ChunkFingerprint of its chunk and
+// a checksum of the bytes before it. An index with a damaged entry, or
+// whose last covered entry does not match the chunk stored under its ID,
+// was not written for this storage and is rebuilt. Chunks added while the
+// file was not kept up to date are hashed again on open, and entries past
+// the last chunk are cut off. Compaction renumbers the chunks and removes
+// the file.
+const (
+	hashIndexMagic      = "W64H"
+	hashIndexVersion    = 2
+	hashIndexHeaderSize = 8
+	hashIndexEntrySize  = 1 + len(metainfo.Hash{}) + 4 + 4
+)
+
+func hashIndexPath(storagePath string) string {
+	return storagePath + ".ihash"
+}
+
+// hashIndex maps info hashes to the chunks holding them.
+type hashIndex struct {
+	file *os.File // nil when the index is not persisted
+	ids  map[metainfo.Hash][]int
+}
+
+// openHashIndex loads the info hash index of cs, hashing the chunks the file
+// does not cover yet. The caller must hold appendMu or have exclusive use of
+// cs, and must not hold mu.
+func (cs *ChunkStorage) openHashIndex() (*hashIndex, error) {
+	filepath := hashIndexPath(cs.Path)
+	index := &hashIndex{ids: make(map[metainfo.Hash][]int)}
+	numberOfChunks := cs.NumberOfChunks()
+
+	content, err := os.ReadFile(filepath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading info hash index '%s': %v", filepath, err)
+	}
+
+	header := encodeHashIndexHeader()
+	covered := 0
+	if bytes.HasPrefix(content, header) {
+		covered = cs.coveredByHashIndex(content[len(header):], numberOfChunks)
+		for chunkid := 0; chunkid < covered; chunkid++ {
+			entry := content[len(header)+chunkid*hashIndexEntrySize:]
+			if entry[0] != 0 {
+				var hash metainfo.Hash
+				copy(hash[:], entry[1:])
+				index.ids[hash] = append(index.ids[hash], chunkid)
+			}
+		}
+	}
+
+	if !cs.options.ReadOnly {
+		f, err := os.OpenFile(filepath, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("opening info hash index '%s': %v", filepath, err)
+		}
+		size := int64(len(header) + covered*hashIndexEntrySize)
+		err = f.Truncate(size)
+		if err == nil && covered == 0 {
+			_, err = f.WriteAt(header, 0)
+		}
+		if err == nil {
+			_, err = f.Seek(size, io.SeekStart)
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("resetting info hash index '%s': %v", filepath, err)
+		}
+		index.file = f
+	}
+
+	var entries []byte
+	for chunkid := covered; chunkid < numberOfChunks; chunkid++ {
+		var hash metainfo.Hash
+		var ok bool
+		if chunk, err := cs.GetChunkById(chunkid); err == nil {
+			hash, ok = cs.options.InfoHashFunc(chunk)
+		}
+		fingerprint, err := cs.ChunkFingerprint(chunkid)
+		if err != nil {
+			index.close()
+			return nil, fmt.Errorf("indexing info hashes: %v", err)
+		}
+		index.insert(chunkid, hash, ok)
+		entries = append(entries, encodeHashIndexEntry(hash, ok, fingerprint)...)
+	}
+	index.persist(entries)
+
+	return index, nil
+}
+
+func encodeHashIndexHeader() []byte {
+	header := make([]byte, hashIndexHeaderSize)
+	copy(header, hashIndexMagic)
+	binary.LittleEndian.PutUint16(header[4:], hashIndexVersion)
+	return header
+}
+
+// coveredByHashIndex returns the number of chunks the entries of an index
+// file hold, 0 if an entry is damaged or the last one was written for
+// another chunk.
+func (cs *ChunkStorage) coveredByHashIndex(entries []byte, numberOfChunks int) int {
+	covered := len(entries) / hashIndexEntrySize
+	if covered > numberOfChunks {
+		covered = numberOfChunks
+	}
+	for chunkid := 0; chunkid < covered; chunkid++ {
+		entry := entries[chunkid*hashIndexEntrySize : (chunkid+1)*hashIndexEntrySize]
+		crc := entry[hashIndexEntrySize-4:]
+		if crc32.Checksum(entry[:hashIndexEntrySize-4], castagnoli) != binary.LittleEndian.Uint32(crc) {
+			return 0
+		}
+	}
+	if covered == 0 {
+		return 0
+	}
+
+	last := entries[(covered-1)*hashIndexEntrySize:]
+	fingerprint, err := cs.ChunkFingerprint(covered - 1)
+	if err != nil || fingerprint != binary.LittleEndian.Uint32(last[hashIndexEntrySize-8:]) {
+		return 0
+	}
+	return covered
+}
+
+func encodeHashIndexEntry(hash metainfo.Hash, ok bool, fingerprint uint32) []byte {
+	entry := make([]byte, hashIndexEntrySize)
+	if ok {
+		entry[0] = 1
+		copy(entry[1:], hash[:])
+	}
+	binary.LittleEndian.PutUint32(entry[hashIndexEntrySize-8:], fingerprint)
+	binary.LittleEndian.PutUint32(entry[hashIndexEntrySize-4:], crc32.Checksum(entry[:hashIndexEntrySize-4], castagnoli))
+	return entry
+}
+
+func (index *hashIndex) insert(chunkid int, hash metainfo.Hash, ok bool) {
+	if ok {
+		index.ids[hash] = append(index.ids[hash], chunkid)
+	}
+}
+
+// add records the hash and fingerprint of a newly added chunk. The caller
+// must hold mu.
+func (index *hashIndex) add(chunkid int, hash metainfo.Hash, ok bool, fingerprint uint32) {
+	index.insert(chunkid, hash, ok)
+	index.persist(encodeHashIndexEntry(hash, ok, fingerprint))
+}
+
+// persist appends entries to the file. The index only saves rehashing on
+// the next open, so after a failed write the file is left as a shorter but
+// consistent prefix and no longer written to.
+func (index *hashIndex) persist(entries []byte) {
+	if index.file == nil || len(entries) == 0 {
+		return
+	}
+	if _, err := index.file.Write(entries); err != nil {
+		index.close()
+	}
+}
+
+func (index *hashIndex) close() {
+	if index.file != nil {
+		_ = index.file.Close()
+		index.file = nil
+	}
+}
+
+// LookupByInfoHash returns the ID of the first chunk not deleted whose
+// item has the given info hash, as extracted by Options.InfoHashFunc. It
+// always reports false if the storage has no InfoHashFunc.
+func (cs *ChunkStorage) LookupByInfoHash(hash metainfo.Hash) (chunkid int, ok bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if cs.hashes == nil {
+		return 0, false
+	}
+	for _, chunkid := range cs.hashes.ids[hash] {
+		if _, deleted := cs.deleted[chunkid]; !deleted {
+			return chunkid, true
+		}
+	}
+	return 0, false
+}
+
+// HasInfoHashIndex reports whether the storage was opened with an
+// InfoHashFunc and so can answer LookupByInfoHash.
+func (cs *ChunkStorage) HasInfoHashIndex() bool {
+	return cs.options.InfoHashFunc != nil
+}
@@ -0,0 +1,34 @@
+package chunk_storage
+
+import (
+	"fmt"
+
+	"github.com/edsrzf/mmap-go"
+)
+
+// mapSegment maps a sealed segment if the storage is configured to. A
+// segment that cannot be mapped keeps being read through its file.
+func (cs *ChunkStorage) mapSegment(seg *segment) {
+	if !cs.options.Mmap || seg.mapped != nil || seg.size == 0 {
+		return
+	}
+	if m, err := mmap.MapRegion(seg.file, int(seg.size), mmap.RDONLY, 0, 0); err == nil {
+		seg.mapped = m
+	}
+}
+
+func (seg *segment) unmap() {
+	if seg.mapped != nil {
+		_ = seg.mapped.Unmap()
+		seg.mapped = nil
+	}
+}
+
+// copyMapped returns a copy of length bytes at position of a mapped
+// segment.
+func copyMapped(m mmap.MMap, name string, position, length int64) ([]byte, error) {
+	if position < 0 || length < 0 || position+length > int64(len(m)) {
+		return nil, fmt.Errorf("reading %d bytes of '%s' at offset %d: out of bounds", length, name, position)
+	}
+	return append([]byte(nil), m[position:position+length]...), nil
+}
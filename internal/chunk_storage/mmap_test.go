@@ -0,0 +1,168 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+)
+
+// testChunk returns the payload of chunk i of a store written by
+// writeStore: its number followed by filler up to size bytes.
+func testChunk(i, size int) []byte {
+	chunk := []byte(fmt.Sprintf("chunk %08d ", i))
+	return append(chunk, bytes.Repeat([]byte{'x'}, size-len(chunk))...)
+}
+
+// validChunk reports whether chunk looks like one returned by testChunk.
+func validChunk(chunk []byte, size int) bool {
+	return len(chunk) == size && bytes.HasPrefix(chunk, []byte("chunk ")) &&
+		bytes.Count(chunk[15:], []byte{'x'}) == size-15
+}
+
+// writeStore writes n chunks of size bytes straight into versioned segments
+// of the storage "w" in dir, which is much faster than calling AddChunk.
+func writeStore(tb testing.TB, dir string, n, size int) {
+	tb.Helper()
+
+	fileID := 0
+	segment := encodeSegmentHeader(nil)
+	flush := func() {
+		if err := os.WriteFile(segmentPath(path.Join(dir, "w"), fileID), segment, 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if len(segment) > ChunkFileMaxSize {
+			flush()
+			fileID++
+			segment = encodeSegmentHeader(nil)
+		}
+		segment = append(segment, encodeRecord(0, testChunk(i, size))...)
+	}
+	flush()
+}
+
+func TestCompactAndCloseDuringMappedReads(t *testing.T) {
+	const chunks, size = 30000, 1000
+
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	cs, err := New(dir, "w", Options{Mmap: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs.segments) < 2 || cs.segments[0].mapped == nil {
+		t.Fatalf("want a mapped sealed segment, got %d segments", len(cs.segments))
+	}
+	for chunkid := 0; chunkid < 1000; chunkid += 3 {
+		if err := cs.DeleteChunk(chunkid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Readers keep the chunks they read and check them again later, after
+	// their segment may have been unmapped.
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func(chunkid int) {
+			defer wg.Done()
+			var previous []byte
+			for {
+				chunk, err := cs.GetChunkById(chunkid)
+				if errors.Is(err, ErrClosed) {
+					return
+				}
+				if err != nil && !errors.Is(err, ErrDeleted) {
+					t.Errorf("reading chunk %d: %v", chunkid, err)
+					return
+				}
+				if err == nil && !validChunk(chunk, size) {
+					t.Errorf("chunk %d: got %q", chunkid, chunk)
+					return
+				}
+				if previous != nil && !validChunk(previous, size) {
+					t.Errorf("chunk read before %d changed to %q", chunkid, previous)
+					return
+				}
+				if chunk != nil {
+					previous = chunk
+				}
+				chunkid = (chunkid + 7919) % (chunks - 1000)
+			}
+		}(r)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cs.Compact(); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := cs.Close(); err != nil {
+		t.Error(err)
+	}
+	wg.Wait()
+
+	if _, err := cs.GetChunkById(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("reading after Close: got %v, want ErrClosed", err)
+	}
+	if err := cs.AddChunk([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("adding after Close: got %v, want ErrClosed", err)
+	}
+	if err := cs.Compact(); !errors.Is(err, ErrClosed) {
+		t.Errorf("compacting after Close: got %v, want ErrClosed", err)
+	}
+}
+
+// benchRecords is the size of the store the read benchmarks run against.
+const benchRecords = 1000000
+
+func benchmarkReads(b *testing.B, read func(b *testing.B, cs *ChunkStorage)) {
+	dir := b.TempDir()
+	writeStore(b, dir, benchRecords, 100)
+
+	for _, mapped := range []bool{false, true} {
+		name := "file"
+		if mapped {
+			name = "mmap"
+		}
+		b.Run(name, func(b *testing.B) {
+			cs, err := New(dir, "w", Options{Mmap: mapped})
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cs.Close()
+			b.ResetTimer()
+			read(b, cs)
+		})
+	}
+}
+
+func BenchmarkGetChunkById(b *testing.B) {
+	benchmarkReads(b, func(b *testing.B, cs *ChunkStorage) {
+		for i := 0; i < b.N; i++ {
+			if _, err := cs.GetChunkById(i * 7919 % benchRecords); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkScan(b *testing.B) {
+	benchmarkReads(b, func(b *testing.B, cs *ChunkStorage) {
+		it := cs.Scan(0, -1, false)
+		for i := 0; i < b.N; i++ {
+			if !it.Next() {
+				if err := it.Err(); err != nil {
+					b.Fatal(err)
+				}
+				it = cs.Scan(0, -1, false)
+				i--
+			}
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package chunk_storage
+
+import "github.com/anacrolix/torrent/metainfo"
+
+// Options tunes how a ChunkStorage is opened. The zero value gives the
+// default behaviour.
+type Options struct {
+	// ReadOnly opens the storage without ever writing to it: incomplete
+	// trailing records are left out of the index but not truncated, and
+	// AddChunk, DeleteChunk and Compact return ErrReadOnly.
+	ReadOnly bool
+
+	// Mmap serves reads of sealed segments, those no longer appended to,
+	// from read-only memory maps, which saves a system call per read.
+	// Chunks are copied out of the mapping, so they stay valid after Close
+	// or Compact.
+	Mmap bool
+
+	// Compress stores new chunks deflate-compressed whenever that makes
+	// them smaller. Reading is transparent either way.
+	Compress bool
+
+	// Dictionary primes compression in segments created from now on. It is
+	// stored in each segment header, so reading never needs it passed in.
+	// See TrainDictionary.
+	Dictionary []byte
+
+	// InfoHashFunc extracts the info hash of the item held by a chunk,
+	// reporting false if it has none. When set, the storage keeps a
+	// persistent index from info hashes to chunk IDs, updated by AddChunk
+	// and queried with LookupByInfoHash.
+	InfoHashFunc func(chunk []byte) (metainfo.Hash, bool)
+}
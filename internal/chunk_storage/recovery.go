@@ -0,0 +1,103 @@
+package chunk_storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// Recovery describes records found unreadable at the end of a segment when
+// the storage was opened. Most are incomplete records left behind by a
+// crash during AddChunk; others start at a damaged record, past which the
+// records could not be told apart.
+type Recovery struct {
+	Path      string // segment file
+	Offset    int64  // where the unreadable records started
+	Dropped   int64  // number of bytes left out of the index
+	Truncated bool   // false for read-only segments, which are left as is
+
+	// Damaged is set when the bytes are not provably the remnant of an
+	// interrupted append. They are then moved to the Quarantine file
+	// before the segment is truncated, so that they can still be salvaged.
+	Damaged    bool
+	Quarantine string
+}
+
+func (r Recovery) String() string {
+	switch {
+	case !r.Truncated && r.Damaged:
+		return fmt.Sprintf("%s: ignored %d bytes from a damaged record at offset %d", r.Path, r.Dropped, r.Offset)
+	case !r.Truncated:
+		return fmt.Sprintf("%s: ignored %d trailing bytes at offset %d", r.Path, r.Dropped, r.Offset)
+	case r.Damaged:
+		return fmt.Sprintf("%s: moved %d bytes from a damaged record at offset %d to '%s'", r.Path, r.Dropped, r.Offset, r.Quarantine)
+	}
+	return fmt.Sprintf("%s: truncated %d trailing bytes at offset %d", r.Path, r.Dropped, r.Offset)
+}
+
+// Recovered returns the unreadable trailing records that New dropped while
+// opening the storage.
+func (cs *ChunkStorage) Recovered() []Recovery {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	return cs.recovered
+}
+
+// recoverTail drops everything from offset to the end of segment fileID.
+// Versioned segments are truncated so that the next AddChunk appends right
+// after the last complete record; unless torn reports that the bytes were
+// never acknowledged by AddChunk, they are first copied to a quarantine
+// file. Legacy segments, and every segment of a storage opened with
+// Options.ReadOnly, are only left out of the index.
+func (cs *ChunkStorage) recoverTail(filepath string, fileID int, offset, fileSize int64, torn bool) error {
+	seg := cs.segments[fileID]
+	r := Recovery{Path: filepath, Offset: offset, Dropped: fileSize - offset, Damaged: !torn}
+
+	if !seg.legacy && !cs.options.ReadOnly {
+		if r.Damaged {
+			quarantine, err := quarantineTail(seg.file, offset, fileSize)
+			if err != nil {
+				return fmt.Errorf("moving damaged records of '%s' at offset %d aside: %v", filepath, offset, err)
+			}
+			r.Quarantine = quarantine
+		}
+		if err := seg.file.Truncate(offset); err != nil {
+			return fmt.Errorf("truncating incomplete record of '%s' at offset %d: %v", filepath, offset, err)
+		}
+		if err := seg.file.Sync(); err != nil {
+			return fmt.Errorf("syncing '%s' after truncation: %v", filepath, err)
+		}
+		r.Truncated = true
+	}
+
+	seg.size = offset
+	cs.recovered = append(cs.recovered, r)
+	return nil
+}
+
+// quarantineTail copies the bytes of f from offset to fileSize to a new
+// "<segment>.N.quarantine" file next to it, synced, and returns its path.
+func quarantineTail(f *os.File, offset, fileSize int64) (string, error) {
+	q, err := os.CreateTemp(path.Dir(f.Name()), path.Base(f.Name())+".*.quarantine")
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(q, io.NewSectionReader(f, offset, fileSize-offset)); err != nil {
+		_ = q.Close()
+		_ = os.Remove(q.Name())
+		return "", err
+	}
+	if err := q.Sync(); err != nil {
+		_ = q.Close()
+		_ = os.Remove(q.Name())
+		return "", err
+	}
+	if err := q.Close(); err != nil {
+		_ = os.Remove(q.Name())
+		return "", err
+	}
+	syncDir(path.Dir(f.Name()))
+	return q.Name(), nil
+}
@@ -0,0 +1,187 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+)
+
+// checkChunks fails unless cs holds exactly n chunks written by testChunk.
+func checkChunks(t *testing.T, cs *ChunkStorage, n, size int) {
+	t.Helper()
+
+	if got := cs.NumberOfChunks(); got != n {
+		t.Fatalf("got %d chunks, want %d", got, n)
+	}
+	for chunkid := 0; chunkid < n; chunkid++ {
+		chunk, err := cs.GetChunkById(chunkid)
+		if err != nil {
+			t.Fatalf("reading chunk %d: %v", chunkid, err)
+		}
+		if !bytes.Equal(chunk, testChunk(chunkid, size)) {
+			t.Fatalf("chunk %d: got %q", chunkid, chunk)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, filepath string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func writeTestFile(t *testing.T, filepath string, content []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRecoverInterruptedAppend kills an append at every byte of its record,
+// leaving the sidecar as it was before the append, and checks that opening
+// the storage drops exactly the partial record.
+func TestRecoverInterruptedAppend(t *testing.T) {
+	const chunks, size = 10, 100
+
+	tombstone := make([]byte, tombstoneSize)
+	binary.LittleEndian.PutUint32(tombstone, 3)
+
+	for _, test := range []struct {
+		name   string
+		record []byte
+	}{
+		{"AddChunk", encodeRecord(0, testChunk(chunks, size))},
+		{"DeleteChunk", encodeRecord(recordFlagTombstone, tombstone)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cs, err := New(dir, "w", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < chunks; i++ {
+				if err := cs.AddChunk(testChunk(i, size)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := cs.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			segment := segmentPath(path.Join(dir, "w"), 0)
+			before := readTestFile(t, segment)
+			sidecar := readTestFile(t, sidecarPath(segment))
+
+			// The file can also have grown without its new bytes reaching
+			// the disk.
+			tails := [][]byte{make([]byte, len(test.record))}
+			for n := 1; n < len(test.record); n++ {
+				tails = append(tails, test.record[:n])
+			}
+
+			for _, tail := range tails {
+				writeTestFile(t, segment, append(append([]byte(nil), before...), tail...))
+				writeTestFile(t, sidecarPath(segment), sidecar)
+
+				cs, err := New(dir, "w", Options{})
+				if err != nil {
+					t.Fatalf("%d bytes written: %v", len(tail), err)
+				}
+				checkChunks(t, cs, chunks, size)
+				if cs.IsDeleted(3) {
+					t.Fatalf("%d bytes written: partial tombstone deleted chunk 3", len(tail))
+				}
+
+				want := Recovery{Path: segment, Offset: int64(len(before)), Dropped: int64(len(tail)), Truncated: true}
+				if recovered := cs.Recovered(); len(recovered) != 1 || recovered[0] != want {
+					t.Fatalf("%d bytes written: got recoveries %v, want %v", len(tail), recovered, want)
+				}
+				if got := readTestFile(t, segment); !bytes.Equal(got, before) {
+					t.Fatalf("%d bytes written: segment of %d bytes after recovery, want %d", len(tail), len(got), len(before))
+				}
+
+				if err := cs.AddChunk(testChunk(chunks, size)); err != nil {
+					t.Fatal(err)
+				}
+				if err := cs.Close(); err != nil {
+					t.Fatal(err)
+				}
+
+				cs, err = New(dir, "w", Options{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				checkChunks(t, cs, chunks+1, size)
+				if recovered := cs.Recovered(); len(recovered) != 0 {
+					t.Fatalf("%d bytes written: recovered %v after reopening", len(tail), recovered)
+				}
+				if err := cs.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestQuarantineDamagedRecord damages the size of a record in the middle of
+// a segment and checks that the records after it are moved aside rather
+// than discarded.
+func TestQuarantineDamagedRecord(t *testing.T) {
+	const chunks, size, damaged = 100, 100, 10
+
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	segment := segmentPath(path.Join(dir, "w"), 0)
+	content := readTestFile(t, segment)
+
+	offset := segmentHeaderSize + damaged*(recordHeaderSize+size)
+	content[offset+3] ^= 0x08 // the size now runs past the end of the file
+	writeTestFile(t, segment, content)
+
+	// A read-only open leaves everything in place.
+	cs, err := New(dir, "w", Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, cs, damaged, size)
+	want := Recovery{Path: segment, Offset: int64(offset), Dropped: int64(len(content) - offset), Damaged: true}
+	if recovered := cs.Recovered(); len(recovered) != 1 || recovered[0] != want {
+		t.Fatalf("got recoveries %v, want %v", recovered, want)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestFile(t, segment); !bytes.Equal(got, content) {
+		t.Fatal("read-only open modified the segment")
+	}
+
+	cs, err = New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	checkChunks(t, cs, damaged, size)
+
+	recovered := cs.Recovered()
+	if len(recovered) != 1 || !recovered[0].Damaged || !recovered[0].Truncated || recovered[0].Quarantine == "" {
+		t.Fatalf("got recoveries %v, want the damaged records quarantined", recovered)
+	}
+	if got := readTestFile(t, recovered[0].Quarantine); !bytes.Equal(got, content[offset:]) {
+		t.Errorf("quarantine holds %d bytes, want the %d from the damaged record on", len(got), len(content)-offset)
+	}
+	if got := readTestFile(t, segment); !bytes.Equal(got, content[:offset]) {
+		t.Errorf("segment of %d bytes after recovery, want %d", len(got), offset)
+	}
+
+	if err := cs.AddChunk(testChunk(damaged, size)); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, cs, damaged+1, size)
+}
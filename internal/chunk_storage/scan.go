@@ -0,0 +1,115 @@
+package chunk_storage
+
+import (
+	"errors"
+)
+
+// Iterator walks a range of chunks in ID order or in reverse. It is not safe
+// for concurrent use, but any number of iterators may run alongside appends
+// to the same storage. See Scan.
+type Iterator struct {
+	cs         *ChunkStorage
+	generation int64
+	from, to   int // remaining IDs are [from, to), to < 0 meaning up to the live end
+	reverse    bool
+
+	id    int
+	chunk []byte
+	err   error
+	done  bool
+}
+
+// Scan returns an iterator over the chunks with IDs in [from, to). A
+// negative to stands for the end of the storage: a forward scan then also
+// visits chunks appended while it runs, and a reverse scan starts from the
+// last chunk present when Scan is called. Deleted chunks are skipped.
+//
+//	it := storage.Scan(0, -1, false)
+//	for it.Next() {
+//		process(it.ID(), it.Bytes())
+//	}
+//	if err := it.Err(); err != nil {
+//		// it.ID() is the chunk that could not be read
+//	}
+func (cs *ChunkStorage) Scan(from, to int, reverse bool) *Iterator {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if from < 0 {
+		from = 0
+	}
+	if to > len(cs.position) || (to < 0 && reverse) {
+		to = len(cs.position)
+	}
+
+	return &Iterator{cs: cs, generation: cs.generation, from: from, to: to, reverse: reverse, id: -1}
+}
+
+// Next advances to the next chunk and reports whether there is one. It
+// returns false at the end of the range, after Stop, or on an error, which
+// Err then returns.
+func (it *Iterator) Next() bool {
+	for !it.done {
+		if !it.advance() {
+			it.Stop()
+			break
+		}
+
+		chunk, err := it.cs.getChunk(it.id, it.generation)
+		if errors.Is(err, ErrDeleted) {
+			continue
+		}
+		if err != nil {
+			it.err = err
+			it.Stop()
+			break
+		}
+
+		it.chunk = chunk
+		return true
+	}
+	return false
+}
+
+// advance moves id to the next ID of the range, if any.
+func (it *Iterator) advance() bool {
+	to := it.to
+	if to < 0 {
+		to = it.cs.NumberOfChunks()
+	}
+	if it.from >= to {
+		return false
+	}
+
+	if it.reverse {
+		it.to--
+		it.id = it.to
+	} else {
+		it.id = it.from
+		it.from++
+	}
+	return true
+}
+
+// ID returns the ID of the current chunk, or of the chunk that failed to
+// be read once Next has returned false with an error.
+func (it *Iterator) ID() int {
+	return it.id
+}
+
+// Bytes returns the current chunk. It follows the same rules as the result
+// of GetChunkById.
+func (it *Iterator) Bytes() []byte {
+	return it.chunk
+}
+
+// Err returns the error that ended the scan, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Stop ends the scan early; Next returns false from then on.
+func (it *Iterator) Stop() {
+	it.done = true
+	it.chunk = nil
+}
@@ -0,0 +1,217 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// scanIDs returns the IDs a scan visits, checking the chunks along the way.
+func scanIDs(t *testing.T, it *Iterator, size int) []int {
+	t.Helper()
+
+	var ids []int
+	for it.Next() {
+		if !bytes.Equal(it.Bytes(), testChunk(it.ID(), size)) {
+			t.Fatalf("chunk %d scanned as %q", it.ID(), it.Bytes())
+		}
+		ids = append(ids, it.ID())
+	}
+	return ids
+}
+
+func TestScanRange(t *testing.T) {
+	const chunks, size = 10, 100
+
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	cs, err := New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	for _, test := range []struct {
+		from, to int
+		reverse  bool
+		ids      []int
+	}{
+		{0, -1, false, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{0, -1, true, []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}},
+		{3, 6, false, []int{3, 4, 5}},
+		{3, 6, true, []int{5, 4, 3}},
+		{-5, 2, false, []int{0, 1}},
+		{8, 100, false, []int{8, 9}},
+		{8, 100, true, []int{9, 8}},
+		{4, 4, false, nil},
+		{6, 4, false, nil},
+		{6, 4, true, nil},
+		{10, -1, false, nil},
+	} {
+		it := cs.Scan(test.from, test.to, test.reverse)
+		if ids := scanIDs(t, it, size); !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Scan(%d, %d, %v): got %v, want %v", test.from, test.to, test.reverse, ids, test.ids)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("Scan(%d, %d, %v): %v", test.from, test.to, test.reverse, err)
+		}
+	}
+
+	// A forward scan to the end also visits chunks appended while it runs;
+	// a reverse one starts from the last chunk present when it began.
+	forward, reverse := cs.Scan(8, -1, false), cs.Scan(8, -1, true)
+	if !forward.Next() || forward.ID() != 8 {
+		t.Fatal("forward scan did not start at chunk 8")
+	}
+	if err := cs.AddChunk(testChunk(chunks, size)); err != nil {
+		t.Fatal(err)
+	}
+	if ids := scanIDs(t, forward, size); !reflect.DeepEqual(ids, []int{9, 10}) {
+		t.Errorf("forward scan went on with %v, want [9 10]", ids)
+	}
+	if ids := scanIDs(t, reverse, size); !reflect.DeepEqual(ids, []int{9, 8}) {
+		t.Errorf("reverse scan visited %v, want [9 8]", ids)
+	}
+
+	// Stop ends a scan early.
+	it := cs.Scan(0, -1, false)
+	it.Next()
+	it.Stop()
+	if it.Next() || it.Bytes() != nil || it.Err() != nil {
+		t.Error("scan went on after Stop")
+	}
+}
+
+func TestScanSkipsDeleted(t *testing.T) {
+	const chunks, size = 10, 100
+
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	cs, err := New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	for _, chunkid := range []int{0, 4, 5, 9} {
+		if err := cs.DeleteChunk(chunkid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, reverse := range []bool{false, true} {
+		want := []int{1, 2, 3, 6, 7, 8}
+		if reverse {
+			want = []int{8, 7, 6, 3, 2, 1}
+		}
+		it := cs.Scan(0, -1, reverse)
+		if ids := scanIDs(t, it, size); !reflect.DeepEqual(ids, want) {
+			t.Errorf("reverse %v: got %v, want %v", reverse, ids, want)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("reverse %v: %v", reverse, err)
+		}
+	}
+}
+
+// TestScanResumesAfterChecksumError damages a record in the middle of a
+// segment and resumes the scan past it from ID, as catalog.Merge does.
+func TestScanResumesAfterChecksumError(t *testing.T) {
+	const chunks, size, damaged = 10, 100, 4
+
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	cs, err := New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sidecar written on open lets the damage go unnoticed until the
+	// record is read.
+	segment := segmentPath(path.Join(dir, "w"), 0)
+	content := readTestFile(t, segment)
+	content[segmentHeaderSize+damaged*(recordHeaderSize+size)+recordHeaderSize+20] ^= 1
+	writeTestFile(t, segment, content)
+
+	cs, err = New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	for _, test := range []struct {
+		reverse       bool
+		before, after []int
+	}{
+		{false, []int{0, 1, 2, 3}, []int{5, 6, 7, 8, 9}},
+		{true, []int{9, 8, 7, 6, 5}, []int{3, 2, 1, 0}},
+	} {
+		it := cs.Scan(0, -1, test.reverse)
+		if ids := scanIDs(t, it, size); !reflect.DeepEqual(ids, test.before) {
+			t.Errorf("reverse %v: got %v before the damaged chunk, want %v", test.reverse, ids, test.before)
+		}
+		if !errors.Is(it.Err(), ErrChecksum) || it.ID() != damaged {
+			t.Fatalf("reverse %v: scan ended at chunk %d with %v, want chunk %d and %v", test.reverse, it.ID(), it.Err(), damaged, ErrChecksum)
+		}
+		if it.Next() || it.Bytes() != nil {
+			t.Errorf("reverse %v: scan went on after an error", test.reverse)
+		}
+
+		if test.reverse {
+			it = cs.Scan(0, it.ID(), true)
+		} else {
+			it = cs.Scan(it.ID()+1, -1, false)
+		}
+		if ids := scanIDs(t, it, size); !reflect.DeepEqual(ids, test.after) {
+			t.Errorf("reverse %v: resumed with %v, want %v", test.reverse, ids, test.after)
+		}
+		if err := it.Err(); err != nil {
+			t.Errorf("reverse %v: resumed scan: %v", test.reverse, err)
+		}
+	}
+}
+
+func TestScanCompacted(t *testing.T) {
+	const chunks, size = 10, 100
+
+	dir := t.TempDir()
+	writeStore(t, dir, chunks, size)
+	cs, err := New(dir, "w", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	for _, reverse := range []bool{false, true} {
+		it := cs.Scan(0, -1, reverse)
+		if !it.Next() {
+			t.Fatal(it.Err())
+		}
+		if err := cs.DeleteChunk(5); err != nil {
+			t.Fatal(err)
+		}
+		if err := cs.Compact(); err != nil {
+			t.Fatal(err)
+		}
+		if it.Next() {
+			t.Errorf("reverse %v: scan went on to chunk %d after Compact", reverse, it.ID())
+		}
+		if !errors.Is(it.Err(), ErrCompacted) {
+			t.Errorf("reverse %v: scan ended with %v, want %v", reverse, it.Err(), ErrCompacted)
+		}
+
+		// A scan started after Compact sees the renumbered chunks.
+		it = cs.Scan(0, -1, reverse)
+		n := 0
+		for it.Next() {
+			n++
+		}
+		if it.Err() != nil || n != cs.NumberOfChunks() {
+			t.Errorf("reverse %v: %d chunks scanned after Compact, want %d: %v", reverse, n, cs.NumberOfChunks(), it.Err())
+		}
+	}
+}
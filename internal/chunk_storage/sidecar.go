@@ -0,0 +1,167 @@
+package chunk_storage
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+)
+
+// Every segment has a sidecar index "<segment>.idx" listing its records, so
+// that opening a large storage does not have to walk every segment:
+//
+//	header:  magic "W64I" | version uint16 | reserved uint16
+//	entries: position uint32 | flags|size uint32
+//	footer:  entry count uint32 | segment size uint64 | crc32c(entries) uint32
+//
+// The active segment's sidecar gets a new entry and footer on every append.
+// The sidecar is trusted only if its checksum matches and the segment size
+// recorded in the footer equals the actual one; otherwise the segment is
+// scanned and the sidecar rewritten. The final record of the active
+// segment is checked even then, and dropped as damaged if it is not intact.
+// As the footer is only written once a record is complete, it also tells
+// the remnant of an interrupted append from a damaged record.
+const (
+	sidecarSuffix     = ".idx"
+	sidecarMagic      = "W64I"
+	sidecarVersion    = 1
+	sidecarHeaderSize = 8
+	sidecarEntrySize  = 8
+	sidecarFooterSize = 16
+)
+
+func sidecarPath(segmentPath string) string {
+	return segmentPath + sidecarSuffix
+}
+
+// sidecarEntry locates one record, tombstones included, in its segment.
+type sidecarEntry struct {
+	position uint32
+	sizeWord uint32
+}
+
+func (e sidecarEntry) chunkSize(legacy bool) uint32 {
+	if legacy {
+		return e.sizeWord
+	}
+	return e.sizeWord & recordSizeMask
+}
+
+func (e sidecarEntry) encode(b []byte) {
+	binary.LittleEndian.PutUint32(b, e.position)
+	binary.LittleEndian.PutUint32(b[4:], e.sizeWord)
+}
+
+// sidecar is the sidecar index of the active segment, kept open for appends.
+type sidecar struct {
+	file  *os.File
+	count uint32
+	crc   uint32
+}
+
+// readSidecar returns the entries of a sidecar index and the size of the
+// segment they describe, reporting false if the sidecar is missing or
+// damaged.
+func readSidecar(filepath string) ([]sidecarEntry, int64, bool) {
+	content, err := os.ReadFile(filepath)
+	if err != nil || len(content) < sidecarHeaderSize+sidecarFooterSize {
+		return nil, 0, false
+	}
+	if string(content[:len(sidecarMagic)]) != sidecarMagic ||
+		binary.LittleEndian.Uint16(content[4:]) != sidecarVersion {
+		return nil, 0, false
+	}
+
+	footer := content[len(content)-sidecarFooterSize:]
+	count := binary.LittleEndian.Uint32(footer)
+	body := content[sidecarHeaderSize : len(content)-sidecarFooterSize]
+
+	if int64(len(body)) != int64(count)*sidecarEntrySize ||
+		binary.LittleEndian.Uint32(footer[12:]) != crc32.Checksum(body, castagnoli) {
+		return nil, 0, false
+	}
+
+	entries := make([]sidecarEntry, count)
+	for i := range entries {
+		b := body[i*sidecarEntrySize:]
+		entries[i] = sidecarEntry{position: binary.LittleEndian.Uint32(b), sizeWord: binary.LittleEndian.Uint32(b[4:])}
+	}
+	return entries, int64(binary.LittleEndian.Uint64(footer[4:])), true
+}
+
+// createSidecar writes a sidecar index holding entries and keeps it open
+// for appends.
+func createSidecar(filepath string, entries []sidecarEntry, segmentSize int64) (*sidecar, error) {
+	content := make([]byte, sidecarHeaderSize+len(entries)*sidecarEntrySize+sidecarFooterSize)
+	copy(content, sidecarMagic)
+	binary.LittleEndian.PutUint16(content[4:], sidecarVersion)
+
+	body := content[sidecarHeaderSize : len(content)-sidecarFooterSize]
+	for i, e := range entries {
+		e.encode(body[i*sidecarEntrySize:])
+	}
+
+	sc := sidecar{count: uint32(len(entries)), crc: crc32.Checksum(body, castagnoli)}
+	sc.encodeFooter(content[len(content)-sidecarFooterSize:], segmentSize)
+
+	f, err := os.OpenFile(filepath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		_ = os.Remove(filepath)
+		return nil, err
+	}
+
+	sc.file = f
+	return &sc, nil
+}
+
+// writeSidecar writes a sidecar index for a sealed segment.
+func writeSidecar(filepath string, entries []sidecarEntry, segmentSize int64) error {
+	sc, err := createSidecar(filepath, entries, segmentSize)
+	if err != nil {
+		return err
+	}
+	return sc.file.Close()
+}
+
+func (sc *sidecar) encodeFooter(b []byte, segmentSize int64) {
+	binary.LittleEndian.PutUint32(b, sc.count)
+	binary.LittleEndian.PutUint64(b[4:], uint64(segmentSize))
+	binary.LittleEndian.PutUint32(b[12:], sc.crc)
+}
+
+// append adds an entry for a record just written, overwriting the previous
+// footer with one that covers the grown segment.
+func (sc *sidecar) append(e sidecarEntry, segmentSize int64) error {
+	b := make([]byte, sidecarEntrySize+sidecarFooterSize)
+	e.encode(b)
+
+	next := sidecar{count: sc.count + 1, crc: crc32.Update(sc.crc, castagnoli, b[:sidecarEntrySize])}
+	next.encodeFooter(b[sidecarEntrySize:], segmentSize)
+
+	if _, err := sc.file.WriteAt(b, sidecarHeaderSize+int64(sc.count)*sidecarEntrySize); err != nil {
+		return err
+	}
+
+	sc.count, sc.crc = next.count, next.crc
+	return nil
+}
+
+func (seg *segment) closeSidecar() {
+	if seg.sidecar != nil {
+		_ = seg.sidecar.file.Close()
+		seg.sidecar = nil
+	}
+}
+
+// dropSidecar discards a sidecar that could not be kept up to date, so the
+// next open scans the segment instead of trusting it.
+func (seg *segment) dropSidecar() {
+	if seg.sidecar != nil {
+		filepath := seg.sidecar.file.Name()
+		seg.closeSidecar()
+		_ = os.Remove(filepath)
+	}
+}
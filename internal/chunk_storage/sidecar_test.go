@@ -0,0 +1,113 @@
+package chunk_storage
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSidecar(t *testing.T) {
+	const chunks, size = 10, 100
+
+	for _, test := range []struct {
+		name   string
+		damage func(t *testing.T, segment string)
+		chunks int
+	}{
+		{"intact", func(t *testing.T, segment string) {}, chunks},
+		{"missing", func(t *testing.T, segment string) {
+			if err := os.Remove(sidecarPath(segment)); err != nil {
+				t.Fatal(err)
+			}
+		}, chunks},
+		{"corrupt", func(t *testing.T, segment string) {
+			sidecar := readTestFile(t, sidecarPath(segment))
+			sidecar[sidecarHeaderSize] ^= 1
+			writeTestFile(t, sidecarPath(segment), sidecar)
+		}, chunks},
+		{"zero-filled tail", func(t *testing.T, segment string) {
+			content := readTestFile(t, segment)
+			copy(content[len(content)-recordHeaderSize-size:], make([]byte, recordHeaderSize+size))
+			writeTestFile(t, segment, content)
+		}, chunks - 1},
+		{"flipped bit in the final record", func(t *testing.T, segment string) {
+			content := readTestFile(t, segment)
+			content[len(content)-1] ^= 1
+			writeTestFile(t, segment, content)
+		}, chunks - 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cs, err := New(dir, "w", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < chunks; i++ {
+				if err := cs.AddChunk(testChunk(i, size)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := cs.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			segment := segmentPath(path.Join(dir, "w"), 0)
+			test.damage(t, segment)
+
+			cs, err = New(dir, "w", Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkChunks(t, cs, test.chunks, size)
+			if err := cs.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.chunks < chunks {
+				recovered := cs.Recovered()
+				offset := int64(segmentHeaderSize + test.chunks*(recordHeaderSize+size))
+				if len(recovered) != 1 || recovered[0].Offset != offset || !recovered[0].Damaged {
+					t.Fatalf("got recoveries %v, want the final record dropped as damaged", recovered)
+				}
+			}
+
+			// Whatever happened, the sidecar is up to date again.
+			entries, acked, ok := readSidecar(sidecarPath(segment))
+			if !ok || acked != int64(len(readTestFile(t, segment))) || len(entries) != test.chunks {
+				t.Fatalf("sidecar: ok %v, segment size %d, %d entries", ok, acked, len(entries))
+			}
+			for i, e := range entries {
+				if want := uint32(segmentHeaderSize + i*(recordHeaderSize+size)); e.position != want || e.sizeWord != size {
+					t.Fatalf("entry %d: got %+v", i, e)
+				}
+			}
+		})
+	}
+}
+
+func TestSidecarSealedSegments(t *testing.T) {
+	dir := t.TempDir()
+	writeStore(t, dir, 30000, 1000)
+
+	// The first open scans every segment and writes their sidecars, which
+	// the second one reads instead.
+	for i := 0; i < 2; i++ {
+		cs, err := New(dir, "w", Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cs.segments) < 2 {
+			t.Fatalf("got %d segments, want several", len(cs.segments))
+		}
+		checkChunks(t, cs, 30000, 1000)
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := readTestFile(t, sidecarPath(segmentPath(path.Join(dir, "w"), 0)))
+	if !bytes.HasPrefix(content, []byte(sidecarMagic)) {
+		t.Fatal("no sidecar written for the sealed segment")
+	}
+}
@@ -0,0 +1,36 @@
+package chunk_storage
+
+// SegmentInfo describes one segment file of a ChunkStorage.
+type SegmentInfo struct {
+	Path       string
+	Legacy     bool  // pre-checksum layout
+	Size       int64 // bytes up to the end of the last complete record
+	Chunks     int   // chunks stored in the segment, deleted ones included
+	Deleted    int   // chunks of the segment removed by DeleteChunk
+	Dictionary int   // size of the compression dictionary, 0 if none
+}
+
+// Segments returns a description of every segment, in order.
+func (cs *ChunkStorage) Segments() []SegmentInfo {
+	cs.appendMu.Lock()
+	defer cs.appendMu.Unlock()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	infos := make([]SegmentInfo, len(cs.segments))
+	for fileID, seg := range cs.segments {
+		infos[fileID] = SegmentInfo{
+			Path:       segmentPath(cs.Path, fileID),
+			Legacy:     seg.legacy,
+			Size:       seg.size,
+			Dictionary: len(seg.dict),
+		}
+	}
+	for chunkid, fileID := range cs.fileID {
+		infos[fileID].Chunks++
+		if _, ok := cs.deleted[chunkid]; ok {
+			infos[fileID].Deleted++
+		}
+	}
+	return infos
+}
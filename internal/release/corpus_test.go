@@ -0,0 +1,333 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseCorpus parses release names as they are found on trackers and
+// indexers.
+func TestParseCorpus(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		want Info
+	}{
+		// Scene movies.
+		{"The.Shawshank.Redemption.1994.1080p.BluRay.x264-AMIABLE", Info{Title: "The Shawshank Redemption", Year: 1994, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "AMIABLE"}},
+		{"Pulp.Fiction.1994.720p.BluRay.x264-SiNNERS", Info{Title: "Pulp Fiction", Year: 1994, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"The.Dark.Knight.2008.2160p.UHD.BluRay.x265-TERMiNAL", Info{Title: "The Dark Knight", Year: 2008, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", Group: "TERMiNAL"}},
+		{"Inception.2010.1080p.BluRay.x264-REFiNED", Info{Title: "Inception", Year: 2010, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "REFiNED"}},
+		{"Fight.Club.1999.REMASTERED.1080p.BluRay.x264-SiNNERS", Info{Title: "Fight Club", Year: 1999, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"Forrest.Gump.1994.720p.BrRip.x264-YIFY", Info{Title: "Forrest Gump", Year: 1994, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "YIFY"}},
+		{"Gladiator.2000.EXTENDED.1080p.BluRay.x264-HDEX", Info{Title: "Gladiator", Year: 2000, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "HDEX"}},
+		{"Alien.1979.DC.1080p.BluRay.x264-CiNEFiLE", Info{Title: "Alien", Year: 1979, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "CiNEFiLE"}},
+		{"Aliens.1986.SE.720p.BluRay.x264-CiNEFiLE", Info{Title: "Aliens", Year: 1986, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "CiNEFiLE"}},
+		{"Se7en.1995.1080p.BluRay.x264-CiNEFiLE", Info{Title: "Se7en", Year: 1995, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "CiNEFiLE"}},
+		{"The.Godfather.1972.1080p.BluRay.x264-AMIABLE", Info{Title: "The Godfather", Year: 1972, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "AMIABLE"}},
+		{"The.Godfather.Part.II.1974.1080p.BluRay.x264-AMIABLE", Info{Title: "The Godfather Part II", Year: 1974, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "AMIABLE"}},
+		{"Goodfellas.1990.REMASTERED.720p.BluRay.x264-AMIABLE", Info{Title: "Goodfellas", Year: 1990, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "AMIABLE"}},
+		{"Heat.1995.Directors.Cut.1080p.BluRay.x264-SADPANDA", Info{Title: "Heat", Year: 1995, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SADPANDA"}},
+		{"Jurassic.Park.1993.2160p.UHD.BluRay.x265-TERMiNAL", Info{Title: "Jurassic Park", Year: 1993, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", Group: "TERMiNAL"}},
+		{"Titanic.1997.720p.BluRay.x264-SiNNERS", Info{Title: "Titanic", Year: 1997, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"Avatar.2009.EXTENDED.1080p.BluRay.x264-IRONCLUST", Info{Title: "Avatar", Year: 2009, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "IRONCLUST"}},
+		{"Avengers.Endgame.2019.1080p.WEB-DL.DD5.1.H264-FGT", Info{Title: "Avengers Endgame", Year: 2019, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AC3", Group: "FGT"}},
+		{"Avengers.Infinity.War.2018.720p.HDCAM.x264-Tokyo", Info{Title: "Avengers Infinity War", Year: 2018, Resolution: "720p", Source: "CAM", VideoCodec: "H.264", Group: "Tokyo"}},
+		{"Joker.2019.1080p.WEBRip.x264-RARBG", Info{Title: "Joker", Year: 2019, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.264", Group: "RARBG"}},
+		{"Joker.2019.HDRip.XviD.AC3-EVO", Info{Title: "Joker", Year: 2019, Source: "HDRip", VideoCodec: "XviD", AudioCodec: "AC3", Group: "EVO"}},
+		{"Tenet.2020.1080p.BluRay.x264.DTS-HD.MA.5.1-FGT", Info{Title: "Tenet", Year: 2020, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Group: "FGT"}},
+		{"Dune.2021.2160p.HMAX.WEB-DL.DDP5.1.Atmos.HDR.HEVC-EVO", Info{Title: "Dune", Year: 2021, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "EVO"}},
+		{"No.Time.to.Die.2021.1080p.WEBRip.x264-RARBG", Info{Title: "No Time to Die", Year: 2021, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.264", Group: "RARBG"}},
+		{"The.Batman.2022.1080p.WEB-DL.DDP5.1.Atmos.H.264-CMRG", Info{Title: "The Batman", Year: 2022, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "CMRG"}},
+		{"Top.Gun.Maverick.2022.720p.WEBRip.x264-GalaxyRG", Info{Title: "Top Gun Maverick", Year: 2022, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Group: "GalaxyRG"}},
+		{"Everything.Everywhere.All.at.Once.2022.1080p.BluRay.x264-VETO", Info{Title: "Everything Everywhere All at Once", Year: 2022, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "VETO"}},
+		{"Barbie.2023.1080p.WEBRip.x265-RARBG", Info{Title: "Barbie", Year: 2023, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.265", Group: "RARBG"}},
+		{"Oppenheimer.2023.2160p.UHD.BluRay.x265.10bit.HDR.TrueHD.7.1.Atmos-SWTYBLZ", Info{Title: "Oppenheimer", Year: 2023, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "TrueHD", Group: "SWTYBLZ"}},
+		{"Killers.of.the.Flower.Moon.2023.1080p.ATVP.WEB-DL.DDP5.1.H.264-FLUX", Info{Title: "Killers of the Flower Moon", Year: 2023, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Poor.Things.2023.720p.WEB.h264-ETHEL", Info{Title: "Poor Things", Year: 2023, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", Group: "ETHEL"}},
+		{"The.Holdovers.2023.1080p.AMZN.WEB-DL.DDP5.1.H.264-FLUX", Info{Title: "The Holdovers", Year: 2023, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Anatomy.of.a.Fall.2023.FRENCH.1080p.WEB.H264-FW", Info{Title: "Anatomy of a Fall", Year: 2023, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Languages: []string{"French"}, Group: "FW"}},
+		{"The.Zone.of.Interest.2023.GERMAN.1080p.WEB.H264-SLOT", Info{Title: "The Zone of Interest", Year: 2023, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Languages: []string{"German"}, Group: "SLOT"}},
+		{"Past.Lives.2023.720p.BluRay.x264-PiGNUS", Info{Title: "Past Lives", Year: 2023, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "PiGNUS"}},
+		{"Civil.War.2024.1080p.WEB.H264-FLUX", Info{Title: "Civil War", Year: 2024, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "FLUX"}},
+		{"Furiosa.A.Mad.Max.Saga.2024.2160p.WEB.H265-FLUX", Info{Title: "Furiosa A Mad Max Saga", Year: 2024, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", Group: "FLUX"}},
+		{"Inside.Out.2.2024.1080p.WEB-DL.DDP5.1.Atmos.H.264-FLUX", Info{Title: "Inside Out 2", Year: 2024, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Deadpool.and.Wolverine.2024.HDTS.x264-SWTYBLZ", Info{Title: "Deadpool and Wolverine", Year: 2024, Source: "TS", VideoCodec: "H.264", Group: "SWTYBLZ"}},
+		{"Alien.Romulus.2024.TELESYNC.x264-SUNSCREEN", Info{Title: "Alien Romulus", Year: 2024, Source: "TS", VideoCodec: "H.264", Group: "SUNSCREEN"}},
+		{"Gravity.2013.3D.1080p.BluRay.Half-SBS.x264.DTS-HD.MA.5.1-RARBG", Info{Title: "Gravity", Year: 2013, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Group: "RARBG"}},
+		{"Blade.Runner.1982.The.Final.Cut.1080p.BluRay.x264-HDEX", Info{Title: "Blade Runner", Year: 1982, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "HDEX"}},
+		{"Apocalypse.Now.1979.Redux.720p.BluRay.x264-CtrlHD", Info{Title: "Apocalypse Now", Year: 1979, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "CtrlHD"}},
+		{"Metropolis.1927.RESTORED.1080p.BluRay.x264-CiNEFiLE", Info{Title: "Metropolis", Year: 1927, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "CiNEFiLE"}},
+		{"Casablanca.1942.1080p.BluRay.FLAC.1.0.x264-DON", Info{Title: "Casablanca", Year: 1942, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "FLAC", Group: "DON"}},
+		{"Psycho.1960.720p.BluRay.DTS.x264-ESiR", Info{Title: "Psycho", Year: 1960, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS", Group: "ESiR"}},
+		{"Vertigo.1958.1080p.BluRay.x264-HDMaNiAcS", Info{Title: "Vertigo", Year: 1958, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "HDMaNiAcS"}},
+		{"Seven.Samurai.1954.JAPANESE.1080p.BluRay.x264-CiNEFiLE", Info{Title: "Seven Samurai", Year: 1954, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Japanese"}, Group: "CiNEFiLE"}},
+		{"Crouching.Tiger.Hidden.Dragon.2000.CHINESE.1080p.BluRay.x264-HDEX", Info{Title: "Crouching Tiger Hidden Dragon", Year: 2000, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Chinese"}, Group: "HDEX"}},
+		{"Amores.Perros.2000.SPANISH.720p.BluRay.x264-HDEX", Info{Title: "Amores Perros", Year: 2000, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Spanish"}, Group: "HDEX"}},
+		{"La.Haine.1995.FRENCH.1080p.BluRay.x264-SbR", Info{Title: "La Haine", Year: 1995, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"French"}, Group: "SbR"}},
+		{"Das.Boot.1981.Directors.Cut.GERMAN.1080p.BluRay.x264-DiVERSiTY", Info{Title: "Das Boot", Year: 1981, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"German"}, Group: "DiVERSiTY"}},
+		{"Oldboy.2003.KOREAN.1080p.BluRay.x264.DTS-WiKi", Info{Title: "Oldboy", Year: 2003, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS", Languages: []string{"Korean"}, Group: "WiKi"}},
+		{"Cinema.Paradiso.1988.ITALIAN.720p.BluRay.x264-CtrlHD", Info{Title: "Cinema Paradiso", Year: 1988, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Italian"}, Group: "CtrlHD"}},
+		{"Leon.The.Professional.1994.EXTENDED.720p.BluRay.x264-SiNNERS", Info{Title: "Leon The Professional", Year: 1994, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"Terminator.2.Judgment.Day.1991.1080p.BluRay.x264-FSiHD", Info{Title: "Terminator 2 Judgment Day", Year: 1991, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "FSiHD"}},
+		{"Back.to.the.Future.Part.III.1990.720p.BluRay.x264-SiNNERS", Info{Title: "Back to the Future Part III", Year: 1990, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"Rocky.IV.1985.1080p.BluRay.x264-AMIABLE", Info{Title: "Rocky IV", Year: 1985, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "AMIABLE"}},
+		{"Toy.Story.3.2010.1080p.BluRay.x264-BestHD", Info{Title: "Toy Story 3", Year: 2010, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "BestHD"}},
+		{"Ocean's.Eleven.2001.1080p.BluRay.x264-FSiHD", Info{Title: "Ocean's Eleven", Year: 2001, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "FSiHD"}},
+		{"Mission.Impossible.Dead.Reckoning.Part.One.2023.1080p.WEB.H264-MIMOSA", Info{Title: "Mission Impossible Dead Reckoning Part One", Year: 2023, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "MIMOSA"}},
+		{"21.Jump.Street.2012.720p.BluRay.x264-SPARKS", Info{Title: "21 Jump Street", Year: 2012, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SPARKS"}},
+		{"10.Cloverfield.Lane.2016.1080p.BluRay.x264-DRONES", Info{Title: "10 Cloverfield Lane", Year: 2016, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "DRONES"}},
+		{"300.2006.1080p.BluRay.x264-SiNNERS", Info{Title: "300", Year: 2006, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"1984.1984.720p.BluRay.x264-SADPANDA", Info{Title: "1984", Year: 1984, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SADPANDA"}},
+		{"Apollo.13.1995.1080p.BluRay.x264-HD4U", Info{Title: "Apollo 13", Year: 1995, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "HD4U"}},
+		{"Ready.Player.One.2018.HC.HDRip.XviD.AC3-EVO", Info{Title: "Ready Player One", Year: 2018, Source: "HDRip", VideoCodec: "XviD", AudioCodec: "AC3", Group: "EVO"}},
+		{"Venom.2018.HDTS.XViD.AC3-ETRG", Info{Title: "Venom", Year: 2018, Source: "TS", VideoCodec: "XviD", AudioCodec: "AC3", Group: "ETRG"}},
+		{"The.Lion.King.2019.CAM.XViD-EVO", Info{Title: "The Lion King", Year: 2019, Source: "CAM", VideoCodec: "XviD", Group: "EVO"}},
+		{"Frozen.II.2019.DVDSCR.x264-COLLECTiVE", Info{Title: "Frozen II", Year: 2019, Source: "SCR", VideoCodec: "H.264", Group: "COLLECTiVE"}},
+		{"Frozen.2013.DVDRip.XviD-SPARKS", Info{Title: "Frozen", Year: 2013, Source: "DVD", VideoCodec: "XviD", Group: "SPARKS"}},
+		{"Shrek.2001.DVDRip.XviD.AC3-FLAWL3SS", Info{Title: "Shrek", Year: 2001, Source: "DVD", VideoCodec: "XviD", AudioCodec: "AC3", Group: "FLAWL3SS"}},
+		{"Up.2009.PROPER.DVDRip.XviD-NeDiVx", Info{Title: "Up", Year: 2009, Source: "DVD", VideoCodec: "XviD", Group: "NeDiVx"}},
+		{"Wall-E.2008.720p.BluRay.x264-SEPTiC", Info{Title: "Wall-E", Year: 2008, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SEPTiC"}},
+		{"X-Men.Days.of.Future.Past.2014.1080p.BluRay.x264-SPARKS", Info{Title: "X-Men Days of Future Past", Year: 2014, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SPARKS"}},
+		{"Mad.Max.Fury.Road.2015.1080p.BluRay.REMUX.AVC.DTS-HD.MA.7.1-RARBG", Info{Title: "Mad Max Fury Road", Year: 2015, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Group: "RARBG"}},
+		{"Parasite.2019.1080p.BluRay.REMUX.AVC.DTS-HD.MA.5.1-FGT", Info{Title: "Parasite", Year: 2019, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Group: "FGT"}},
+		{"The.Matrix.Resurrections.2021.1080p.WEB-DL.DD5.1.H.264-EVO", Info{Title: "The Matrix Resurrections", Year: 2021, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AC3", Group: "EVO"}},
+		{"Nope.2022.1080p.BluRay.x265.10bit.AAC5.1-LAMA", Info{Title: "Nope", Year: 2022, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "AAC", Group: "LAMA"}},
+		{"Her.2013.1080p.BluRay.DTS.x264-DON", Info{Title: "Her", Year: 2013, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS", Group: "DON"}},
+
+		// Scene TV: episodes, seasons, packs and dated shows.
+		{"Breaking.Bad.S01E01.720p.BluRay.x264-DEMAND", Info{Title: "Breaking Bad", Season: 1, Episode: 1, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "DEMAND"}},
+		{"Breaking.Bad.S04E13.1080p.BluRay.x264-ROVERS", Info{Title: "Breaking Bad", Season: 4, Episode: 13, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "ROVERS"}},
+		{"Better.Call.Saul.S06E13.1080p.WEB.H264-CAKES", Info{Title: "Better Call Saul", Season: 6, Episode: 13, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "CAKES"}},
+		{"The.Sopranos.S01.1080p.BluRay.x265-RARBG", Info{Title: "The Sopranos", Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", Group: "RARBG"}},
+		{"The.Wire.S03E11.Middle.Ground.720p.WEB-DL.DD5.1.H.264-BS", Info{Title: "The Wire", Season: 3, Episode: 11, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AC3", Group: "BS"}},
+		{"Game.of.Thrones.S01E01.Winter.Is.Coming.1080p.BluRay.x264-ROVERS", Info{Title: "Game of Thrones", Season: 1, Episode: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "ROVERS"}},
+		{"House.of.the.Dragon.S02E08.2160p.MAX.WEB-DL.DDP5.1.Atmos.DV.HDR.H.265-FLUX", Info{Title: "House of the Dragon", Season: 2, Episode: 8, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"The.Last.of.Us.S01E03.1080p.WEB.H264-CAKES", Info{Title: "The Last of Us", Season: 1, Episode: 3, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "CAKES"}},
+		{"Succession.S04E10.720p.HEVC.x265-MeGusta", Info{Title: "Succession", Season: 4, Episode: 10, Resolution: "720p", VideoCodec: "H.265", Group: "MeGusta"}},
+		{"Severance.S02E01.1080p.WEB.H264-SuccessfulCrab", Info{Title: "Severance", Season: 2, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "SuccessfulCrab"}},
+		{"Stranger.Things.S04E09.2160p.NF.WEB-DL.DDP5.1.Atmos.DV.H.265-SMURF", Info{Title: "Stranger Things", Season: 4, Episode: 9, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "SMURF"}},
+		{"The.Crown.S05E01.1080p.NF.WEBRip.DDP5.1.x264-NTb", Info{Title: "The Crown", Season: 5, Episode: 1, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"The.Mandalorian.S03E08.1080p.DSNP.WEB-DL.DDP5.1.Atmos.H.264-FLUX", Info{Title: "The Mandalorian", Season: 3, Episode: 8, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Andor.S01E12.Rix.Road.2160p.DSNP.WEB-DL.DDP5.1.HDR.H.265-NOSiViD", Info{Title: "Andor", Season: 1, Episode: 12, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "NOSiViD"}},
+		{"The.Bear.S03E01.1080p.HULU.WEB-DL.DDP5.1.H.264-NTb", Info{Title: "The Bear", Season: 3, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Shogun.2024.S01E10.A.Dream.of.a.Dream.1080p.DSNP.WEB-DL.DDP5.1.H.264-NTb", Info{Title: "Shogun", Year: 2024, Season: 1, Episode: 10, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Fargo.S05E01.720p.HDTV.x264-SYNCOPY", Info{Title: "Fargo", Season: 5, Episode: 1, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "SYNCOPY"}},
+		{"Doctor.Who.2023.S01E01.Space.Babies.1080p.DSNP.WEB-DL.DDP5.1.H.264-NTb", Info{Title: "Doctor Who", Year: 2023, Season: 1, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Doctor.Who.S08E01.HDTV.x264-TLA", Info{Title: "Doctor Who", Season: 8, Episode: 1, Source: "HDTV", VideoCodec: "H.264", Group: "TLA"}},
+		{"Sherlock.S04E03.The.Final.Problem.720p.HDTV.x264-MTB", Info{Title: "Sherlock", Season: 4, Episode: 3, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "MTB"}},
+		{"The.Office.US.S09E23.720p.WEB-DL.DD5.1.H.264-BS", Info{Title: "The Office US", Season: 9, Episode: 23, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AC3", Group: "BS"}},
+		{"Friends.S10E17E18.The.Last.One.720p.BluRay.x264-PSYCHD", Info{Title: "Friends", Season: 10, Episode: 17, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "PSYCHD"}},
+		{"Seinfeld.S05E14.DVDRip.XviD-SAiNTS", Info{Title: "Seinfeld", Season: 5, Episode: 14, Source: "DVD", VideoCodec: "XviD", Group: "SAiNTS"}},
+		{"The.Simpsons.S34E22.1080p.WEB.h264-KOGi", Info{Title: "The Simpsons", Season: 34, Episode: 22, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "KOGi"}},
+		{"Family.Guy.S21E20.720p.HDTV.x264-SYNCOPY", Info{Title: "Family Guy", Season: 21, Episode: 20, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "SYNCOPY"}},
+		{"South.Park.S26E06.1080p.WEB.H264-CAKES", Info{Title: "South Park", Season: 26, Episode: 6, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "CAKES"}},
+		{"Lost.S06E17E18.The.End.720p.BluRay.x264-SiNNERS", Info{Title: "Lost", Season: 6, Episode: 17, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"Dexter.S08E12.720p.HDTV.x264-EVOLVE", Info{Title: "Dexter", Season: 8, Episode: 12, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "EVOLVE"}},
+		{"Mr.Robot.S04E13.1080p.AMZN.WEB-DL.DDP5.1.H.264-NTb", Info{Title: "Mr Robot", Season: 4, Episode: 13, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Westworld.S04E08.720p.WEB.H264-GLHF", Info{Title: "Westworld", Season: 4, Episode: 8, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", Group: "GLHF"}},
+		{"The.Boys.S04E08.1080p.WEB.H264-SuccessfulCrab", Info{Title: "The Boys", Season: 4, Episode: 8, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "SuccessfulCrab"}},
+		{"Ted.Lasso.S03E12.2160p.ATVP.WEB-DL.DDP5.1.Atmos.HDR.H.265-CasStudio", Info{Title: "Ted Lasso", Season: 3, Episode: 12, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "CasStudio"}},
+		{"The.Walking.Dead.S11E24.1080p.WEB.H264-PECULATE", Info{Title: "The Walking Dead", Season: 11, Episode: 24, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "PECULATE"}},
+		{"Vikings.S06E20.720p.WEBRip.x264-XLF", Info{Title: "Vikings", Season: 6, Episode: 20, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Group: "XLF"}},
+		{"Chernobyl.S01E05.720p.HDTV.x264-AVS", Info{Title: "Chernobyl", Season: 1, Episode: 5, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "AVS"}},
+		{"Band.of.Brothers.S01E01.1080p.BluRay.x264-ROVERS", Info{Title: "Band of Brothers", Season: 1, Episode: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "ROVERS"}},
+		{"True.Detective.S01E08.1080p.BluRay.x264-ROVERS", Info{Title: "True Detective", Season: 1, Episode: 8, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "ROVERS"}},
+		{"Twin.Peaks.S03E08.720p.WEB.x264-TBS", Info{Title: "Twin Peaks", Season: 3, Episode: 8, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", Group: "TBS"}},
+		{"The.X-Files.S11E10.720p.HDTV.x264-AVS", Info{Title: "The X-Files", Season: 11, Episode: 10, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "AVS"}},
+		{"Star.Trek.Strange.New.Worlds.S02E10.1080p.WEB.H264-SuccessfulCrab", Info{Title: "Star Trek Strange New Worlds", Season: 2, Episode: 10, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "SuccessfulCrab"}},
+		{"Dark.S03E08.GERMAN.1080p.NF.WEB-DL.DDP5.1.x264-TVARCHiV", Info{Title: "Dark", Season: 3, Episode: 8, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Languages: []string{"German"}, Group: "TVARCHiV"}},
+		{"Money.Heist.S05E10.SPANISH.720p.NF.WEBRip.x264-GalaxyTV", Info{Title: "Money Heist", Season: 5, Episode: 10, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Languages: []string{"Spanish"}, Group: "GalaxyTV"}},
+		{"Lupin.S01E01.FRENCH.1080p.NF.WEB-DL.DDP5.1.x264-NTb", Info{Title: "Lupin", Season: 1, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Languages: []string{"French"}, Group: "NTb"}},
+		{"Squid.Game.S01E01.KOREAN.1080p.NF.WEBRip.DDP5.1.x264-NTb", Info{Title: "Squid Game", Season: 1, Episode: 1, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.264", AudioCodec: "EAC3", Languages: []string{"Korean"}, Group: "NTb"}},
+		{"The.Sopranos.S06E21.Made.in.America.DVDRip.XviD-SAiNTS", Info{Title: "The Sopranos", Season: 6, Episode: 21, Source: "DVD", VideoCodec: "XviD", Group: "SAiNTS"}},
+		{"Top.Gear.S22E01.HDTV.x264-FTP", Info{Title: "Top Gear", Season: 22, Episode: 1, Source: "HDTV", VideoCodec: "H.264", Group: "FTP"}},
+		{"QI.S21E05.720p.HDTV.x264-DARKFLiX", Info{Title: "QI", Season: 21, Episode: 5, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "DARKFLiX"}},
+		{"Jeopardy.2023.11.20.720p.HDTV.x264-NTb", Info{Title: "Jeopardy", Year: 2023, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "NTb"}},
+		{"The.Daily.Show.2024.03.14.Sam.Altman.720p.WEB.h264-EDITH", Info{Title: "The Daily Show", Year: 2024, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", Group: "EDITH"}},
+		{"Saturday.Night.Live.S49E15.1080p.WEB.h264-EDITH", Info{Title: "Saturday Night Live", Season: 49, Episode: 15, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "EDITH"}},
+		{"Survivor.S46E01.720p.WEB.h264-EDITH", Info{Title: "Survivor", Season: 46, Episode: 1, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", Group: "EDITH"}},
+		{"The.Great.British.Bake.Off.S14E10.1080p.HDTV.H264-DARKFLiX", Info{Title: "The Great British Bake Off", Season: 14, Episode: 10, Resolution: "1080p", Source: "HDTV", VideoCodec: "H.264", Group: "DARKFLiX"}},
+		{"MasterChef.Australia.S16E01.720p.HDTV.x264-ORENJI", Info{Title: "MasterChef Australia", Season: 16, Episode: 1, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "ORENJI"}},
+		{"Planet.Earth.III.S01E01.Coasts.2160p.iP.WEB-DL.AAC2.0.HLG.H.265-RAWR", Info{Title: "Planet Earth III", Season: 1, Episode: 1, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "AAC", Group: "RAWR"}},
+		{"Blue.Planet.II.S01.2160p.UHD.BluRay.x265.10bit.HDR.DTS-HD.MA.5.1-SWTYBLZ", Info{Title: "Blue Planet II", Season: 1, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "DTS-HD", Group: "SWTYBLZ"}},
+		{"Cosmos.A.Spacetime.Odyssey.S01E01.720p.HDTV.x264-2HD", Info{Title: "Cosmos A Spacetime Odyssey", Season: 1, Episode: 1, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "2HD"}},
+		{"Black.Mirror.S06E01.Joan.Is.Awful.1080p.NF.WEB-DL.DDP5.1.Atmos.H.264-FLUX", Info{Title: "Black Mirror", Season: 6, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Arcane.S02E09.1080p.WEB.h264-ETHEL", Info{Title: "Arcane", Season: 2, Episode: 9, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "ETHEL"}},
+		{"Peaky.Blinders.S06E06.720p.HDTV.x264-SYNCOPY", Info{Title: "Peaky Blinders", Season: 6, Episode: 6, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "SYNCOPY"}},
+		{"Downton.Abbey.S06E09.Christmas.Special.720p.HDTV.x264-FoV", Info{Title: "Downton Abbey", Season: 6, Episode: 9, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "FoV"}},
+		{"The.Expanse.S06E06.Babylons.Ashes.1080p.AMZN.WEB-DL.DDP5.1.H.264-NTb", Info{Title: "The Expanse", Season: 6, Episode: 6, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Battlestar.Galactica.2003.S04E20.720p.BluRay.x264-SiNNERS", Info{Title: "Battlestar Galactica", Year: 2003, Season: 4, Episode: 20, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "SiNNERS"}},
+		{"Firefly.S01.1080p.BluRay.x264-ROVERS", Info{Title: "Firefly", Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "ROVERS"}},
+		{"Seinfeld.Season.9.Complete.DVDRip.XviD", Info{Title: "Seinfeld", Season: 9, Source: "DVD", VideoCodec: "XviD"}},
+		{"Friends.Complete.Series.S01-S10.1080p.BluRay.x265-RARBG", Info{Title: "Friends", Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", Group: "RARBG"}},
+		{"Breaking.Bad.Complete.Series.720p.BluRay.x264", Info{Title: "Breaking Bad", Resolution: "720p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"The.Wire.Season.1-5.Complete.720p.BluRay", Info{Title: "The Wire", Season: 1, Resolution: "720p", Source: "BluRay"}},
+		{"Curb.Your.Enthusiasm.S12.Complete.1080p.WEB.H264", Info{Title: "Curb Your Enthusiasm", Season: 12, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264"}},
+		{"Only.Murders.in.the.Building.S04E01.720p.HEVC.x265-MeGusta", Info{Title: "Only Murders in the Building", Season: 4, Episode: 1, Resolution: "720p", VideoCodec: "H.265", Group: "MeGusta"}},
+		{"Hacks.S03E09.1080p.WEB.h264-ETHEL", Info{Title: "Hacks", Season: 3, Episode: 9, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "ETHEL"}},
+		{"Slow.Horses.S04E06.2160p.ATVP.WEB-DL.DDP5.1.Atmos.DV.H.265-FLUX", Info{Title: "Slow Horses", Season: 4, Episode: 6, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Reacher.S02E08.1080p.AMZN.WEB-DL.DDP5.1.H.264-FLUX", Info{Title: "Reacher", Season: 2, Episode: 8, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"The.Rings.of.Power.S02E08.720p.AMZN.WEBRip.x264-GalaxyTV", Info{Title: "The Rings of Power", Season: 2, Episode: 8, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Group: "GalaxyTV"}},
+		{"Fallout.S01E08.The.Beginning.1080p.AMZN.WEB-DL.DDP5.1.H.264-FLUX", Info{Title: "Fallout", Season: 1, Episode: 8, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Yellowstone.2018.S05E14.1080p.WEB.h264-ETHEL", Info{Title: "Yellowstone", Year: 2018, Season: 5, Episode: 14, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "ETHEL"}},
+		{"Ozark.S04E14.720p.NF.WEBRip.x264-GalaxyTV", Info{Title: "Ozark", Season: 4, Episode: 14, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Group: "GalaxyTV"}},
+		{"The.Witcher.S03E08.2160p.NF.WEB-DL.DDP5.1.Atmos.DV.H.265-FLUX", Info{Title: "The Witcher", Season: 3, Episode: 8, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Monk.1x01.Mr.Monk.and.the.Candidate.DVDRip.XviD", Info{Title: "Monk", Season: 1, Episode: 1, Source: "DVD", VideoCodec: "XviD"}},
+		{"Frasier.3x15.DVDRip.XviD-TOPAZ", Info{Title: "Frasier", Season: 3, Episode: 15, Source: "DVD", VideoCodec: "XviD", Group: "TOPAZ"}},
+		{"Cheers.S11E25E26.One.for.the.Road.DVDRip.XviD", Info{Title: "Cheers", Season: 11, Episode: 25, Source: "DVD", VideoCodec: "XviD"}},
+
+		// P2P releases, with spaces, brackets and site tags.
+		{"The Shawshank Redemption (1994) 1080p BrRip x264 - YIFY", Info{Title: "The Shawshank Redemption", Year: 1994, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "YIFY"}},
+		{"Interstellar (2014) [1080p] [YTS.AG]", Info{Title: "Interstellar", Year: 2014, Resolution: "1080p"}},
+		{"Inception (2010) [720p] [BluRay] [YTS.MX]", Info{Title: "Inception", Year: 2010, Resolution: "720p", Source: "BluRay"}},
+		{"The Godfather (1972) [2160p] [4K] [BluRay] [5.1] [YTS.MX]", Info{Title: "The Godfather", Year: 1972, Resolution: "2160p", Source: "BluRay"}},
+		{"Pulp Fiction (1994) 720p BrRip x264 - 700MB - YIFY", Info{Title: "Pulp Fiction", Year: 1994, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "YIFY"}},
+		{"Spirited Away (2001) [1080p] [BluRay] [5.1] [YTS.MX]", Info{Title: "Spirited Away", Year: 2001, Resolution: "1080p", Source: "BluRay"}},
+		{"Dune Part Two (2024) [2160p] [4K] [WEB] [5.1] [YTS.MX]", Info{Title: "Dune Part Two", Year: 2024, Resolution: "2160p", Source: "WEB-DL"}},
+		{"Oppenheimer (2023) [1080p] [WEBRip] [x265] [10bit] [5.1] [YTS.MX]", Info{Title: "Oppenheimer", Year: 2023, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.265"}},
+		{"Heat (1995) 1080p BluRay x264 DTS-HD MA 5.1 - 4.4GB", Info{Title: "Heat", Year: 1995, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD"}},
+		{"The Matrix 1999 1080p BluRay DD+ 5.1 x265-EDGE2020", Info{Title: "The Matrix", Year: 1999, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "EDGE2020"}},
+		{"Fight Club (1999) 1080p BluRay 10bit HEVC 6CH x265 - PSA", Info{Title: "Fight Club", Year: 1999, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", Group: "PSA"}},
+		{"Avengers: Endgame (2019) 1080p BluRay x264 Dual Audio Hindi English", Info{Title: "Avengers: Endgame", Year: 2019, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Hindi", "English"}}},
+		{"John Wick Chapter 4 2023 1080p WEB-DL DDP5.1 H.264-EniaHD", Info{Title: "John Wick Chapter 4", Year: 2023, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "EniaHD"}},
+		{"Gladiator II (2024) 1080p CAM x264 - TheHoutrix", Info{Title: "Gladiator II", Year: 2024, Resolution: "1080p", Source: "CAM", VideoCodec: "H.264", Group: "TheHoutrix"}},
+		{"Deadpool & Wolverine (2024) 1080p TS", Info{Title: "Deadpool & Wolverine", Year: 2024, Resolution: "1080p", Source: "TS"}},
+		{"Barbie 2023 HDRip 720p x264 AAC-Galaxy", Info{Title: "Barbie", Year: 2023, Resolution: "720p", Source: "HDRip", VideoCodec: "H.264", AudioCodec: "AAC", Group: "Galaxy"}},
+		{"Parasite (2019) [BluRay] [1080p] [KOREAN] [YTS.AG]", Info{Title: "Parasite", Year: 2019, Resolution: "1080p", Source: "BluRay", Languages: []string{"Korean"}}},
+		{"Amélie (2001) 1080p BluRay x265 FRENCH AAC - HxD", Info{Title: "Amélie", Year: 2001, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "AAC", Languages: []string{"French"}, Group: "HxD"}},
+		{"Knives Out (2019) [WEBRip] [720p] [YTS.LT]", Info{Title: "Knives Out", Year: 2019, Resolution: "720p", Source: "WEBRip"}},
+		{"Joker (2019) 1080p HDCAM x264 AAC - MkvCage", Info{Title: "Joker", Year: 2019, Resolution: "1080p", Source: "CAM", VideoCodec: "H.264", AudioCodec: "AAC", Group: "MkvCage"}},
+		{"Top Gun: Maverick (2022) 4K HDR 2160p WEB-DL", Info{Title: "Top Gun: Maverick", Year: 2022, Resolution: "2160p", Source: "WEB-DL"}},
+		{"Nomadland.2020.1080p.WEBRip.x265-RARBG", Info{Title: "Nomadland", Year: 2020, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.265", Group: "RARBG"}},
+		{"Soul.2020.1080p.DSNP.WEBRip.DDP5.1.x264-CM", Info{Title: "Soul", Year: 2020, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "CM"}},
+		{"Hereditary.2018.1080p.BluRay.x264.DTS-HD.MA.5.1-SWTYBLZ", Info{Title: "Hereditary", Year: 2018, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Group: "SWTYBLZ"}},
+		{"Midsommar.2019.DIRECTORS.CUT.1080p.WEB-DL.DD5.1.H264-FGT", Info{Title: "Midsommar", Year: 2019, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AC3", Group: "FGT"}},
+		{"Get Out (2017) 720p BluRay x264 [Dual Audio] [Hindi DD 5.1 + English DD 5.1]", Info{Title: "Get Out", Year: 2017, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AC3", Languages: []string{"Hindi", "English"}}},
+		{"Arrival (2016) 1080p BluRay x265 HEVC AAC-SARTRE", Info{Title: "Arrival", Year: 2016, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "AAC", Group: "SARTRE"}},
+		{"Her (2013) BluRay 720p 800MB Ganool", Info{Title: "Her", Year: 2013, Resolution: "720p", Source: "BluRay"}},
+		{"The Grand Budapest Hotel 2014 720p BRRip x264 AC3-JYK", Info{Title: "The Grand Budapest Hotel", Year: 2014, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AC3", Group: "JYK"}},
+		{"Whiplash 2014 1080p BluRay x264 DTS-JYK", Info{Title: "Whiplash", Year: 2014, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS", Group: "JYK"}},
+		{"Mad Max: Fury Road (2015) 1080p BrRip x264 - YIFY", Info{Title: "Mad Max: Fury Road", Year: 2015, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "YIFY"}},
+		{"Coco (2017) [BluRay] [720p] [YTS.AM]", Info{Title: "Coco", Year: 2017, Resolution: "720p", Source: "BluRay"}},
+		{"La La Land 2016 1080p BluRay x264 DTS-HD MA 7.1-FGT", Info{Title: "La La Land", Year: 2016, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Group: "FGT"}},
+		{"The Social Network (2010) 1080p BluRay x264 Dual Audio [Hindi 2.0 - English 2.0] ESub", Info{Title: "The Social Network", Year: 2010, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Hindi", "English"}}},
+		{"Drive.2011.1080p.BluRay.H264.AAC-RARBG", Info{Title: "Drive", Year: 2011, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AAC", Group: "RARBG"}},
+		{"Moonlight.2016.720p.BluRay.H264.AAC-RARBG", Info{Title: "Moonlight", Year: 2016, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AAC", Group: "RARBG"}},
+		{"Ex.Machina.2014.1080p.BluRay.x264.YIFY", Info{Title: "Ex Machina", Year: 2014, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"The.Revenant.2015.1080p.BluRay.x264-[YTS.AG]", Info{Title: "The Revenant", Year: 2015, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"Gravity 2013 1080p 3D HSBS BluRay DTS x264-PublicHD", Info{Title: "Gravity", Year: 2013, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS", Group: "PublicHD"}},
+		{"Game of Thrones S08E06 720p WEB H264-MEMENTO [eztv]", Info{Title: "Game of Thrones", Season: 8, Episode: 6, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", Group: "MEMENTO"}},
+		{"The Boys S03E01 1080p WEB H264-GLHF [eztv]", Info{Title: "The Boys", Season: 3, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "GLHF"}},
+		{"House.of.the.Dragon.S01E01.720p.HMAX.WEBRip.x264-GalaxyTV[TGx]", Info{Title: "House of the Dragon", Season: 1, Episode: 1, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Group: "GalaxyTV"}},
+		{"The Last of Us S01E01 When You're Lost in the Darkness 1080p HMAX WEB-DL DDP5 1 Atmos H 264-SMURF", Info{Title: "The Last of Us", Season: 1, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "SMURF"}},
+		{"Succession S04E10 With Open Eyes 1080p AMZN WEB-DL DDP5 1 H 264-NTb", Info{Title: "Succession", Season: 4, Episode: 10, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Breaking Bad Season 1 Complete 720p BluRay x264 [i_c]", Info{Title: "Breaking Bad", Season: 1, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"The Office US Season 1-9 Complete 720p WEB-DL", Info{Title: "The Office US", Season: 1, Resolution: "720p", Source: "WEB-DL"}},
+		{"Friends - Season 1 - Complete (1994) 1080p BluRay", Info{Title: "Friends", Year: 1994, Season: 1, Resolution: "1080p", Source: "BluRay"}},
+		{"Rick and Morty S07E10 1080p WEB H264-NHTFS [eztv]", Info{Title: "Rick and Morty", Season: 7, Episode: 10, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "NHTFS"}},
+		{"Stranger Things S04 COMPLETE 720p NF WEBRip x264-GalaxyTV", Info{Title: "Stranger Things", Season: 4, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", Group: "GalaxyTV"}},
+		{"Better Call Saul S06E13 Saul Gone 1080p AMC WEB-DL DDP5 1 H 264-NTb[rartv]", Info{Title: "Better Call Saul", Season: 6, Episode: 13, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Shogun.2024.S01.COMPLETE.1080p.DSNP.WEB-DL.DDP5.1.H.264-NTb[TGx]", Info{Title: "Shogun", Year: 2024, Season: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "NTb"}},
+		{"Chernobyl (2019) Season 1 S01 (1080p BluRay x265 HEVC 10bit AAC 5.1 Silence)", Info{Title: "Chernobyl", Year: 2019, Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "AAC"}},
+		{"The Wire (2002) Season 1-5 S01-S05 (1080p BluRay x265 HEVC 10bit AAC 5.1 Silence)", Info{Title: "The Wire", Year: 2002, Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "AAC"}},
+		{"Band of Brothers (2001) S01 (1080p BluRay x265 HEVC 10bit AAC 5.1 Silence)", Info{Title: "Band of Brothers", Year: 2001, Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "AAC"}},
+		{"Dark (2017) Season 1 S01 (1080p NF WEB-DL x265 HEVC 10bit AAC 5.1 German Silence)", Info{Title: "Dark", Year: 2017, Season: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "AAC", Languages: []string{"German"}}},
+		{"Severance S01 1080p ATVP WEB-DL DDP5.1 Atmos H.264-SMURF", Info{Title: "Severance", Season: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "SMURF"}},
+		{"Fargo S01E01 The Crocodiles Dilemma 1080p BluRay x264", Info{Title: "Fargo", Season: 1, Episode: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"Westworld.S01.1080p.BluRay.x265-RARBG", Info{Title: "Westworld", Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", Group: "RARBG"}},
+		{"Sherlock Series 4 Episode 3 The Final Problem 720p HDTV", Info{Title: "Sherlock", Season: 4, Episode: 3, Resolution: "720p", Source: "HDTV"}},
+		{"Doctor Who Series 13 Episode 1 1080p", Info{Title: "Doctor Who", Season: 13, Episode: 1, Resolution: "1080p"}},
+		{"Top Gear Series 22 Episode 1 720p HDTV x264", Info{Title: "Top Gear", Season: 22, Episode: 1, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264"}},
+		{"Planet Earth II Episode 6 Cities 2160p UHD BluRay", Info{Title: "Planet Earth II", Episode: 6, Resolution: "2160p", Source: "BluRay"}},
+		{"The Mandalorian S02E08 Chapter 16 The Rescue 2160p WEB-DL DDP5 1 Atmos HDR HEVC-MZABI", Info{Title: "The Mandalorian", Season: 2, Episode: 8, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "MZABI"}},
+		{"Lost Season 6 Episode 17 The End 720p", Info{Title: "Lost", Season: 6, Episode: 17, Resolution: "720p"}},
+		{"Twin Peaks 1990 S01E01 Pilot 1080p BluRay x264", Info{Title: "Twin Peaks", Year: 1990, Season: 1, Episode: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"Cosmos 1980 Episode 1 The Shores of the Cosmic Ocean DVDRip", Info{Title: "Cosmos", Year: 1980, Episode: 1, Source: "DVD"}},
+		{"The Sopranos Complete Series S01-S06 720p BluRay x264", Info{Title: "The Sopranos", Season: 1, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"Ubuntu 24.04 LTS Desktop amd64", Info{Title: "Ubuntu 24 04 LTS Desktop amd64"}},
+		{"Big Buck Bunny 1080p", Info{Title: "Big Buck Bunny", Resolution: "1080p"}},
+		{"Sintel 2010 4K", Info{Title: "Sintel", Year: 2010, Resolution: "2160p"}},
+
+		// Anime fansub and raw releases, and scene anime.
+		{"[HorribleSubs] One Piece - 1000 [1080p].mkv", Info{Title: "One Piece", Episode: 1000, Resolution: "1080p", Group: "HorribleSubs"}},
+		{"[SubsPlease] Jujutsu Kaisen - 47 (1080p) [8C2C0A3E].mkv", Info{Title: "Jujutsu Kaisen", Episode: 47, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[Erai-raws] Spy x Family - 12 [720p][Multiple Subtitle].mkv", Info{Title: "Spy x Family", Episode: 12, Resolution: "720p", Group: "Erai-raws"}},
+		{"[Judas] Shingeki no Kyojin - S04E28 [1080p][HEVC x265 10bit][Eng-Subs]", Info{Title: "Shingeki no Kyojin", Season: 4, Episode: 28, Resolution: "1080p", VideoCodec: "H.265", Group: "Judas"}},
+		{"[SubsPlease] Frieren - 28 (1080p) [F4E1A2B3].mkv", Info{Title: "Frieren", Episode: 28, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[Commie] Steins;Gate - 01 [BD 720p AAC] [1D3C7E2F].mkv", Info{Title: "Steins;Gate", Episode: 1, Resolution: "720p", Source: "BluRay", AudioCodec: "AAC", Group: "Commie"}},
+		{"[Coalgirls] Clannad After Story (1920x1080 Blu-Ray FLAC) [5C6D8E9F]", Info{Title: "Clannad After Story", Resolution: "1080p", Source: "BluRay", AudioCodec: "FLAC", Group: "Coalgirls"}},
+		{"[Nep_Blanc] Cowboy Bebop [BDRip 1080p x265 10bit FLAC]", Info{Title: "Cowboy Bebop", Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "FLAC", Group: "Nep_Blanc"}},
+		{"[DameDesuYo] Made in Abyss - 01 (1920x1080 10bit AAC) [3F4A5B6C].mkv", Info{Title: "Made in Abyss", Episode: 1, Resolution: "1080p", AudioCodec: "AAC", Group: "DameDesuYo"}},
+		{"[Golumpa] Dr. Stone - 24 [English Dub] [FuniDub 720p x264 AAC] [MKV]", Info{Title: "Dr Stone", Episode: 24, Resolution: "720p", VideoCodec: "H.264", AudioCodec: "AAC", Languages: []string{"English"}, Group: "Golumpa"}},
+		{"[SubsPlease] Oshi no Ko - 11 (720p) [B1C2D3E4].mkv", Info{Title: "Oshi no Ko", Episode: 11, Resolution: "720p", Group: "SubsPlease"}},
+		{"[EMBER] Chainsaw Man (2022) (Season 1) [BDRip] [1080p Dual Audio HEVC 10 bits DDP]", Info{Title: "Chainsaw Man", Year: 2022, Season: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "EMBER"}},
+		{"[ASW] Dungeon Meshi - 24 [1080p HEVC x265 10Bit][AAC]", Info{Title: "Dungeon Meshi", Episode: 24, Resolution: "1080p", VideoCodec: "H.265", AudioCodec: "AAC", Group: "ASW"}},
+		{"[Erai-raws] Bocchi the Rock! - 01 ~ 12 [1080p][Multiple Subtitle]", Info{Title: "Bocchi the Rock!", Episode: 1, Resolution: "1080p", Group: "Erai-raws"}},
+		{"[Cleo] Neon Genesis Evangelion - The End of Evangelion [Dual Audio 10bit BD1080p][HEVC-x265]", Info{Title: "Neon Genesis Evangelion - The End of Evangelion", Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", Group: "Cleo"}},
+		{"[Kametsu] Spirited Away (BD 1080p Hi10 FLAC) [Dual-Audio]", Info{Title: "Spirited Away", Resolution: "1080p", Source: "BluRay", AudioCodec: "FLAC", Group: "Kametsu"}},
+		{"[SubsPlease] Dandadan - 12 (1080p) [A9B8C7D6].mkv", Info{Title: "Dandadan", Episode: 12, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[Moozzi2] Fullmetal Alchemist Brotherhood [BD 1920x1080 x265-10Bit Flac]", Info{Title: "Fullmetal Alchemist Brotherhood", Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "FLAC", Group: "Moozzi2"}},
+		{"[Hi10] Mob Psycho 100 [BD 1080p]", Info{Title: "Mob Psycho 100", Resolution: "1080p", Source: "BluRay", Group: "Hi10"}},
+		{"[SallySubs] Kimi no Na wa [BD 1080p FLAC]", Info{Title: "Kimi no Na wa", Resolution: "1080p", Source: "BluRay", AudioCodec: "FLAC", Group: "SallySubs"}},
+		{"[HorribleSubs] Boku no Hero Academia - 88 [720p].mkv", Info{Title: "Boku no Hero Academia", Episode: 88, Resolution: "720p", Group: "HorribleSubs"}},
+		{"[Ohys-Raws] Sousou no Frieren - 01 (NTV 1280x720 x264 AAC).mp4", Info{Title: "Sousou no Frieren", Episode: 1, Resolution: "720p", VideoCodec: "H.264", AudioCodec: "AAC", Group: "Ohys-Raws"}},
+		{"[Leopard-Raws] Demon Slayer - 26 END (MX 1280x720 x264 AAC).mp4", Info{Title: "Demon Slayer", Episode: 26, Resolution: "720p", VideoCodec: "H.264", AudioCodec: "AAC", Group: "Leopard-Raws"}},
+		{"[SubsPlease] Kusuriya no Hitorigoto - 24 (1080p) [0A1B2C3D].mkv", Info{Title: "Kusuriya no Hitorigoto", Episode: 24, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[Yameii] Solo Leveling - S01E12 [English Dub] [CR WEB-DL 1080p] [5E6F7A8B]", Info{Title: "Solo Leveling", Season: 1, Episode: 12, Resolution: "1080p", Source: "WEB-DL", Languages: []string{"English"}, Group: "Yameii"}},
+		{"[Anime Land] Vinland Saga S2 - 24 END (WEBRip 1080p Hi10P AAC) RAW [9C8B7A6D]", Info{Title: "Vinland Saga", Season: 2, Episode: 24, Resolution: "1080p", Source: "WEBRip", AudioCodec: "AAC", Group: "Anime Land"}},
+		{"[Erai-raws] Blue Lock 2nd Season - 14 [1080p][HEVC][Multiple Subtitle]", Info{Title: "Blue Lock 2nd Season", Episode: 14, Resolution: "1080p", VideoCodec: "H.265", Group: "Erai-raws"}},
+		{"[SubsPlease] Mushoku Tensei S2 - 24 (1080p) [3E2D1C0B].mkv", Info{Title: "Mushoku Tensei", Season: 2, Episode: 24, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[SubsPlease] Shingeki no Kyojin (The Final Season) - 87 (1080p) [ABCD1234].mkv", Info{Title: "Shingeki no Kyojin The Final Season", Episode: 87, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[Trix] Haikyuu!! S04 (BD 1080p AV1 Opus) [Multi Subs]", Info{Title: "Haikyuu!!", Season: 4, Resolution: "1080p", Source: "BluRay", VideoCodec: "AV1", AudioCodec: "Opus", Languages: []string{"Multi"}, Group: "Trix"}},
+		{"[Reaktor] Ghost in the Shell - Stand Alone Complex [1080p][x265][10-bit][Dual-Audio]", Info{Title: "Ghost in the Shell - Stand Alone Complex", Resolution: "1080p", VideoCodec: "H.265", Group: "Reaktor"}},
+		{"[Beatrice-Raws] Akira [BDRip 3840x2160 HEVC TrueHD]", Info{Title: "Akira", Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "TrueHD", Group: "Beatrice-Raws"}},
+		{"[Kawaiika-Raws] Violet Evergarden The Movie [BDRip 1920x1080 HEVC FLAC]", Info{Title: "Violet Evergarden The Movie", Resolution: "1080p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "FLAC", Group: "Kawaiika-Raws"}},
+		{"[GJM] Kaguya-sama wa Kokurasetai - Ultra Romantic - 13 [61E1F2A3]", Info{Title: "Kaguya-sama wa Kokurasetai - Ultra Romantic", Episode: 13, Group: "GJM"}},
+		{"[Some-Stuffs] Pokemon (2019) - 132 (1080p)", Info{Title: "Pokemon", Year: 2019, Episode: 132, Resolution: "1080p", Group: "Some-Stuffs"}},
+		{"[Tsundere-Raws] Re Zero kara Hajimeru Isekai Seikatsu S3 - 08 [1080p]", Info{Title: "Re Zero kara Hajimeru Isekai Seikatsu", Season: 3, Episode: 8, Resolution: "1080p", Group: "Tsundere-Raws"}},
+		{"[SubsPlease] Kaiju No. 8 - 12 (1080p) [7D6C5B4A].mkv", Info{Title: "Kaiju No 8", Episode: 12, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[HorribleSubs] Kimetsu no Yaiba - 19 [1080p].mkv", Info{Title: "Kimetsu no Yaiba", Episode: 19, Resolution: "1080p", Group: "HorribleSubs"}},
+		{"[Erai-raws] Hunter x Hunter (2011) - 148 [1080p][Multiple Subtitle]", Info{Title: "Hunter x Hunter", Year: 2011, Episode: 148, Resolution: "1080p", Group: "Erai-raws"}},
+		{"[Exiled-Destiny] Code Geass Lelouch of the Rebellion R2 [Dual Audio]", Info{Title: "Code Geass Lelouch of the Rebellion R2", Group: "Exiled-Destiny"}},
+		{"[Doki] Toradora! - 25 (1280x720 h264 BD AAC) [E0F1A2B3]", Info{Title: "Toradora!", Episode: 25, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AAC", Group: "Doki"}},
+		{"Spirited.Away.2001.JAPANESE.1080p.BluRay.x264.DTS-FGT", Info{Title: "Spirited Away", Year: 2001, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS", Languages: []string{"Japanese"}, Group: "FGT"}},
+		{"Akira.1988.REMASTERED.JAPANESE.2160p.BluRay.x265.10bit.SDR.DTS-HD.MA.5.1-SWTYBLZ", Info{Title: "Akira", Year: 1988, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "DTS-HD", Languages: []string{"Japanese"}, Group: "SWTYBLZ"}},
+		{"Your.Name.2016.1080p.BluRay.x264-HAiKU", Info{Title: "Your Name", Year: 2016, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "HAiKU"}},
+		{"Perfect.Blue.1997.JAPANESE.1080p.BluRay.x264-WiKi", Info{Title: "Perfect Blue", Year: 1997, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Languages: []string{"Japanese"}, Group: "WiKi"}},
+		{"Jujutsu.Kaisen.S02E23.1080p.CR.WEB-DL.AAC2.0.H.264-VARYG", Info{Title: "Jujutsu Kaisen", Season: 2, Episode: 23, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AAC", Group: "VARYG"}},
+		{"Frieren.Beyond.Journeys.End.S01E28.1080p.CR.WEB-DL.JPN.AAC2.0.H.264.MSubs-ToonsHub", Info{Title: "Frieren Beyond Journeys End", Season: 1, Episode: 28, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AAC", Languages: []string{"Japanese"}, Group: "ToonsHub"}},
+		{"One.Piece.E1089.1080p.CR.WEB-DL.AAC2.0.H.264-VARYG", Info{Title: "One Piece", Episode: 1089, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AAC", Group: "VARYG"}},
+		{"Attack.on.Titan.S04E30.1080p.WEB.H264-SENPAI", Info{Title: "Attack on Titan", Season: 4, Episode: 30, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "SENPAI"}},
+		{"Cowboy.Bebop.S01E01.Asteroid.Blues.1080p.BluRay.x264-WaLMaRT", Info{Title: "Cowboy Bebop", Season: 1, Episode: 1, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "WaLMaRT"}},
+		{"Neon.Genesis.Evangelion.S01.1080p.NF.WEB-DL.DDP2.0.x264-TOMMY", Info{Title: "Neon Genesis Evangelion", Season: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "TOMMY"}},
+		{"Demon.Slayer.Kimetsu.no.Yaiba.The.Movie.Mugen.Train.2020.1080p.BluRay.x264-WUTANG", Info{Title: "Demon Slayer Kimetsu no Yaiba The Movie Mugen Train", Year: 2020, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "WUTANG"}},
+		{"Suzume.2022.JAPANESE.1080p.BluRay.x264.DTS-HD.MA.5.1-WiKi", Info{Title: "Suzume", Year: 2022, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Languages: []string{"Japanese"}, Group: "WiKi"}},
+		{"The.Boy.and.the.Heron.2023.1080p.WEBRip.x265-KONTRAST", Info{Title: "The Boy and the Heron", Year: 2023, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.265", Group: "KONTRAST"}},
+		{"Dragon.Ball.Z.S01E01.DVDRip.XviD-AnimeHD", Info{Title: "Dragon Ball Z", Season: 1, Episode: 1, Source: "DVD", VideoCodec: "XviD", Group: "AnimeHD"}},
+		{"Sailor.Moon.Crystal.S03E01.720p.WEBRip.AAC2.0.x264-RTN", Info{Title: "Sailor Moon Crystal", Season: 3, Episode: 1, Resolution: "720p", Source: "WEBRip", VideoCodec: "H.264", AudioCodec: "AAC", Group: "RTN"}},
+		{"Mobile.Suit.Gundam.The.Witch.from.Mercury.S01E12.1080p.CR.WEB-DL.AAC2.0.H.264-VARYG", Info{Title: "Mobile Suit Gundam The Witch from Mercury", Season: 1, Episode: 12, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AAC", Group: "VARYG"}},
+		{"Pluto.S01.1080p.NF.WEB-DL.DDP5.1.Atmos.H.264-FLUX", Info{Title: "Pluto", Season: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Blue.Eye.Samurai.S01E08.2160p.NF.WEB-DL.DDP5.1.Atmos.DV.HDR.H.265-FLUX", Info{Title: "Blue Eye Samurai", Season: 1, Episode: 8, Resolution: "2160p", Source: "WEB-DL", VideoCodec: "H.265", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"Castlevania.Nocturne.S02E08.1080p.NF.WEB-DL.DDP5.1.H.264-FLUX", Info{Title: "Castlevania Nocturne", Season: 2, Episode: 8, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FLUX"}},
+		{"[SubsPlease] Ranma 1-2 (2024) - 12 (1080p) [4A3B2C1D].mkv", Info{Title: "Ranma 1-2", Year: 2024, Episode: 12, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[Erai-raws] 86 - 23 [1080p][Multiple Subtitle]", Info{Title: "86", Episode: 23, Resolution: "1080p", Group: "Erai-raws"}},
+		{"[SubsPlease] 2.5 Jigen no Ririsa - 24 (1080p) [1F2E3D4C].mkv", Info{Title: "2 5 Jigen no Ririsa", Episode: 24, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[SubsPlease] Shikanoko Nokonoko Koshitantan - 12 (720p) [AABBCCDD].mkv", Info{Title: "Shikanoko Nokonoko Koshitantan", Episode: 12, Resolution: "720p", Group: "SubsPlease"}},
+		{"[Judas] Bleach - Sennen Kessen-hen - S01E13 [1080p][HEVC x265 10bit]", Info{Title: "Bleach - Sennen Kessen-hen", Season: 1, Episode: 13, Resolution: "1080p", VideoCodec: "H.265", Group: "Judas"}},
+		// Numbers in titles.
+		{"2001.A.Space.Odyssey.1968.1080p.BluRay.x264-AMIABLE", Info{Title: "2001 A Space Odyssey", Year: 1968, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "AMIABLE"}},
+		{"Blade.Runner.2049.2017.1080p.BluRay.x264-SPARKS", Info{Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SPARKS"}},
+		{"The.Year.2000", Info{Title: "The Year", Year: 2000}},
+		{"1917.2019.1080p.BluRay.x264-SPARKS", Info{Title: "1917", Year: 2019, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SPARKS"}},
+		{"Apollo.13.1995.720p.BluRay.x264", Info{Title: "Apollo 13", Year: 1995, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"Ocean's.Eleven.2001.1080p", Info{Title: "Ocean's Eleven", Year: 2001, Resolution: "1080p"}},
+		{"Se7en.1995.REMASTERED.1080p.BluRay.x264", Info{Title: "Se7en", Year: 1995, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"S.W.A.T.2017.S07E13.720p.HDTV.x264-SYNCOPY", Info{Title: "S W A T", Year: 2017, Season: 7, Episode: 13, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "SYNCOPY"}},
+		{"9-1-1.S07E10.1080p.WEB.h264-ETHEL", Info{Title: "9-1-1", Season: 7, Episode: 10, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Group: "ETHEL"}},
+		{"24.S09E12.720p.HDTV.x264-KILLERS", Info{Title: "24", Season: 9, Episode: 12, Resolution: "720p", Source: "HDTV", VideoCodec: "H.264", Group: "KILLERS"}},
+	} {
+		if got := Parse(c.name); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%q):\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Package release extracts what scene style release names, such as
+// "Show.S02E05.720p.WEB-DL.x265-GRP", tell about their content.
+package release
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Info is what a release name tells about its content. Whatever the name
+// does not mention is left zero.
+type Info struct {
+	Title      string
+	Year       int
+	Season     int
+	Episode    int
+	Resolution string   // such as "720p" or "1080i"; 4k is "2160p"
+	Source     string   // one of Sources
+	VideoCodec string   // one of VideoCodecs
+	AudioCodec string   // such as "AAC" or "DTS-HD"
+	Languages  []string // among Languages
+	Group      string
+}
+
+// extensions are the file extensions release names may keep.
+var extensions = []string{".mkv", ".mp4", ".avi", ".m4v", ".wmv", ".mov", ".mpg", ".mpeg", ".webm", ".flv", ".iso", ".torrent"}
+
+// Parse extracts the information a release name carries. Names are split
+// into words at spaces, dots, underscores and brackets. The title is made
+// of the words up to the first one carrying information, such as a year,
+// an episode number or a resolution; a year starting the name, as in
+// "2012.2009.1080p", belongs to the title. The group is the word following
+// the last dash of a name, "x265-GRP", or the bracketed word starting it,
+// "[GRP] Show - 05".
+func Parse(name string) Info {
+	name = strings.TrimSpace(name)
+	lower := strings.ToLower(name)
+	for _, extension := range extensions {
+		if strings.HasSuffix(lower, extension) {
+			name = name[:len(name)-len(extension)]
+			break
+		}
+	}
+
+	// A name may end with the dash of a group left out, "x264-".
+	name = strings.TrimRight(name, "- ")
+
+	var info Info
+	if strings.HasPrefix(name, "[") {
+		if end := strings.IndexByte(name, ']'); end > 0 {
+			info.Group = strings.TrimSpace(name[1:end])
+			name = name[end+1:]
+		}
+	}
+
+	words := split(name)
+	if info.Group == "" {
+		if group, rest, ok := trailingGroup(name); ok {
+			restWords := split(rest)
+			var restInfo Info
+			if titleEnd := restInfo.scan(restWords); titleEnd < len(restWords) {
+				info.Group, words = group, restWords
+			}
+		}
+	}
+
+	titleEnd := info.scan(words)
+	info.Title = title(words[:titleEnd])
+	return info
+}
+
+// split cuts name into words.
+func split(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		switch r {
+		case '.', '_', '[', ']', '(', ')', '{', '}', ',':
+			return true
+		}
+		return unicode.IsSpace(r)
+	})
+}
+
+// trailingGroup splits "...x265-GRP [site]" into the group "GRP" and the
+// rest of the name, "...x265", reporting false if the name does not end
+// that way or the dash belongs to a word such as "WEB-DL" or a range such
+// as "1-8".
+func trailingGroup(name string) (group, rest string, ok bool) {
+	// Sites tag the releases they host with their name in brackets.
+	for strings.HasSuffix(name, "]") {
+		start := strings.LastIndexByte(name, '[')
+		if start <= 0 {
+			break
+		}
+		name = strings.TrimSpace(name[:start])
+	}
+
+	dash := strings.LastIndexByte(name, '-')
+	if dash <= 0 || dash == len(name)-1 {
+		return "", "", false
+	}
+	group, rest = strings.TrimSpace(name[dash+1:]), strings.TrimSpace(name[:dash])
+	if group == "" {
+		return "", "", false
+	}
+	letters := false
+	for _, r := range group {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return "", "", false
+		}
+		letters = letters || unicode.IsLetter(r)
+	}
+	// A number after a dash ends a range, as in "Season 1-8".
+	if !letters {
+		return "", "", false
+	}
+
+	restWords := split(rest)
+	if len(restWords) == 0 {
+		return "", "", false
+	}
+	joined := strings.ToLower(restWords[len(restWords)-1] + "-" + group)
+	if _, ok := sources[joined]; ok {
+		return "", "", false
+	}
+	if _, ok := audioCodecs[joined]; ok {
+		return "", "", false
+	}
+	return group, rest, true
+}
+
+// scan fills info from words and returns the index of the word ending the
+// title, len(words) if none does.
+func (info *Info) scan(words []string) int {
+	titleEnd := len(words)
+	mark := func(i int) {
+		if i < titleEnd {
+			titleEnd = i
+		}
+	}
+
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		next := ""
+		if i+1 < len(words) {
+			next = strings.ToLower(words[i+1])
+		}
+
+		if year, ok := parseYear(word); ok && i > 0 {
+			// Of two years in a row, the first belongs to the title, as in
+			// "Blade.Runner.2049.2017".
+			if _, nextIsYear := parseYear(next); !nextIsYear || i > titleEnd {
+				if info.Year == 0 {
+					info.Year = year
+				}
+				mark(i)
+			}
+			continue
+		}
+
+		if episode, ok := parseEpisode(word); ok && i > 0 {
+			if info.Episode == 0 {
+				info.Episode = episode
+			}
+			mark(i)
+			continue
+		}
+
+		if season, episode, ok := parseSeasonEpisode(word); ok {
+			mark(i)
+			// The episode may follow as a word of its own, "S01 E02".
+			if e, ok := parseEpisode(next); ok && episode == 0 && strings.HasPrefix(word, "s") {
+				episode = e
+				i++
+			}
+			if info.Season == 0 && info.Episode == 0 {
+				info.Season, info.Episode = season, episode
+			}
+			continue
+		}
+
+		if n, ok := parseNumber(next); ok && i > 0 && info.numbered(word, n, i <= titleEnd+1) {
+			mark(i)
+			i++
+			continue
+		}
+
+		// Anime releases glue the source to the resolution, "BD1080p".
+		if strings.HasPrefix(word, "bd") && Lines(word[2:]) > 0 {
+			if info.Source == "" {
+				info.Source = "BluRay"
+			}
+			word = word[2:]
+		}
+		if lines := frameLines(word); lines > 0 {
+			if info.Resolution == "" {
+				info.Resolution = strconv.Itoa(lines) + "p"
+			}
+			mark(i)
+			continue
+		}
+		if word == "4k" || word == "uhd" || word == "8k" ||
+			(strings.HasSuffix(word, "p") || strings.HasSuffix(word, "i")) && Lines(word) > 0 {
+			if info.Resolution == "" {
+				info.Resolution = strconv.Itoa(Lines(word)) + "p"
+				if strings.HasSuffix(word, "i") {
+					info.Resolution = word
+				}
+			}
+			mark(i)
+			continue
+		}
+
+		if source, ok := sources[word]; ok {
+			if info.Source == "" {
+				info.Source = source
+			}
+			mark(i)
+			continue
+		}
+
+		// H.264 splits into "h" and "264", which both end the title.
+		if (word == "h" || word == "x") && (next == "264" || next == "265") {
+			mark(i)
+			word += next
+			i++
+		}
+		codec, ok := videoCodecs[word]
+		// Anime releases glue the bit depth to the codec, "x265-10bit".
+		if dash := strings.IndexByte(word, '-'); !ok && dash > 0 {
+			codec, ok = videoCodecs[word[:dash]]
+		}
+		if ok {
+			if info.VideoCodec == "" {
+				info.VideoCodec = codec
+			}
+			mark(i)
+			continue
+		}
+
+		if codec, ok := audioCodec(word); ok {
+			if info.AudioCodec == "" || info.AudioCodec == "Atmos" {
+				info.AudioCodec = codec
+			}
+			mark(i)
+			continue
+		}
+
+		if tags[word] || word == "dual" && next == "audio" {
+			mark(i)
+		}
+	}
+
+	// Languages come after the title, though usually before the other
+	// words ending it, as in "Amelie.FRENCH.1080p".
+	for titleEnd > 1 && titleEnd < len(words) {
+		if _, ok := languages[strings.ToLower(words[titleEnd-1])]; !ok {
+			break
+		}
+		titleEnd--
+	}
+	for _, word := range words[titleEnd:] {
+		language, ok := languages[strings.ToLower(word)]
+		if ok && !contains(info.Languages, language) {
+			info.Languages = append(info.Languages, language)
+		}
+	}
+	return titleEnd
+}
+
+// numbered records n if word announces a number, as "season" and
+// "episode" do, reporting whether it does. Anime releases number episodes
+// after a dash, "Show - 05", which only counts within the title or right
+// after the word ending it, as in "Show S2 - 05" or "Show (2019) - 05".
+func (info *Info) numbered(word string, n int, nearTitle bool) bool {
+	switch {
+	case word == "season", word == "seasons", word == "series":
+		if info.Season == 0 {
+			info.Season = n
+		}
+	case word == "episode", word == "ep", word == "-" && nearTitle:
+		if info.Episode == 0 {
+			info.Episode = n
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// parseYear parses years from 1900 to 2099.
+func parseYear(word string) (int, bool) {
+	if len(word) != 4 || !strings.HasPrefix(word, "19") && !strings.HasPrefix(word, "20") {
+		return 0, false
+	}
+	year, err := strconv.Atoi(word)
+	return year, err == nil
+}
+
+// parseNumber parses a number, or the first of a range of numbers such as
+// the seasons of "Season 1-8".
+func parseNumber(word string) (int, bool) {
+	n, rest := leadingNumber(word, 4)
+	if n < 0 {
+		return 0, false
+	}
+	if rest != "" {
+		if !strings.HasPrefix(rest, "-") {
+			return 0, false
+		}
+		if last, rest := leadingNumber(rest[1:], 4); last < 0 || rest != "" {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// parseEpisode parses "e05" and "e1089", in lower case.
+func parseEpisode(word string) (int, bool) {
+	if !strings.HasPrefix(word, "e") {
+		return 0, false
+	}
+	episode, rest := leadingNumber(word[1:], 4)
+	return episode, episode >= 0 && rest == ""
+}
+
+// parseSeasonEpisode parses "s02e05", "s02e05e06", "s02e05-e06", "s02",
+// "s01-s08", "s01-08" and "2x05", in lower case. The episode of a complete
+// season is 0, and a range of seasons gives its first one.
+func parseSeasonEpisode(word string) (int, int, bool) {
+	if strings.HasPrefix(word, "s") {
+		season, rest := leadingNumber(word[1:], 2)
+		if season < 0 {
+			return 0, 0, false
+		}
+		if rest == "" {
+			return season, 0, true
+		}
+		if strings.HasPrefix(rest, "-") {
+			last, rest := leadingNumber(strings.TrimPrefix(rest[1:], "s"), 2)
+			return season, 0, last >= 0 && rest == ""
+		}
+		if !strings.HasPrefix(rest, "e") {
+			return 0, 0, false
+		}
+		episode, rest := leadingNumber(rest[1:], 3)
+		if episode < 0 || rest != "" && !strings.HasPrefix(rest, "e") && !strings.HasPrefix(rest, "-e") {
+			return 0, 0, false
+		}
+		return season, episode, true
+	}
+
+	season, rest := leadingNumber(word, 2)
+	if season < 0 || !strings.HasPrefix(rest, "x") || len(rest) < 3 {
+		return 0, 0, false
+	}
+	episode, rest := leadingNumber(rest[1:], 3)
+	if episode < 0 || rest != "" {
+		return 0, 0, false
+	}
+	return season, episode, true
+}
+
+// frameLines returns the lines of a frame size such as "1920x1080", or 0
+// if word is not one.
+func frameLines(word string) int {
+	x := strings.IndexByte(word, 'x')
+	if x < 0 {
+		return 0
+	}
+	width, rest := leadingNumber(word[:x], 4)
+	if width < 100 || rest != "" {
+		return 0
+	}
+	lines, rest := leadingNumber(word[x+1:], 4)
+	if lines < 100 || rest != "" {
+		return 0
+	}
+	return lines
+}
+
+// leadingNumber parses the number of at most max digits starting s, and
+// returns -1 if there is none.
+func leadingNumber(s string, max int) (int, string) {
+	digits := 0
+	for digits < len(s) && digits <= max && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits > max {
+		return -1, s
+	}
+	n, _ := strconv.Atoi(s[:digits])
+	return n, s[digits:]
+}
+
+// title joins the words of a title, leaving out dashes at its ends.
+func title(words []string) string {
+	for len(words) > 0 && words[0] == "-" {
+		words = words[1:]
+	}
+	for len(words) > 0 && words[len(words)-1] == "-" {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
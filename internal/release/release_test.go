@@ -0,0 +1,57 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		want Info
+	}{
+		{"Show.S02E05.720p.x265-GRP", Info{Title: "Show", Season: 2, Episode: 5, Resolution: "720p", VideoCodec: "H.265", Group: "GRP"}},
+		{"The.Matrix.1999.1080p.BluRay.x264-SPARKS", Info{Title: "The Matrix", Year: 1999, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "SPARKS"}},
+		{"The Matrix (1999) 1080p BrRip x264 - 1.4GB - YIFY", Info{Title: "The Matrix", Year: 1999, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", Group: "YIFY"}},
+		{"Blade.Runner.2049.2017.2160p.UHD.BluRay.REMUX.HDR.HEVC.Atmos-EPSiLON", Info{Title: "Blade Runner 2049", Year: 2017, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "Atmos", Group: "EPSiLON"}},
+		{"2001.A.Space.Odyssey.1968.720p.BluRay.DD5.1.x264-LiNG", Info{Title: "2001 A Space Odyssey", Year: 1968, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AC3", Group: "LiNG"}},
+		{"1917.2019.1080p.WEB-DL.DDP5.1.H.264-FGT", Info{Title: "1917", Year: 2019, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "FGT"}},
+		{"Game.of.Thrones.S08E03.The.Long.Night.1080p.AMZN.WEB-DL.DDP5.1.H.264-GoT", Info{Title: "Game of Thrones", Season: 8, Episode: 3, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "EAC3", Group: "GoT"}},
+		{"Amelie.2001.FRENCH.1080p.BluRay.x264.DTS-HD-CtrlHD", Info{Title: "Amelie", Year: 2001, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "DTS-HD", Languages: []string{"French"}, Group: "CtrlHD"}},
+		{"Le.Fabuleux.Destin.d.Amelie.Poulain.FRENCH.720p.BluRay", Info{Title: "Le Fabuleux Destin d Amelie Poulain", Resolution: "720p", Source: "BluRay", Languages: []string{"French"}}},
+		{"The.French.Connection.1971.REMASTERED.1080p.BluRay.x264", Info{Title: "The French Connection", Year: 1971, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"[SubsPlease] Jujutsu Kaisen - 24 (1080p) [A1B2C3D4].mkv", Info{Title: "Jujutsu Kaisen", Episode: 24, Resolution: "1080p", Group: "SubsPlease"}},
+		{"[HorribleSubs] One Piece - 1000 [720p].mkv", Info{Title: "One Piece", Episode: 1000, Resolution: "720p", Group: "HorribleSubs"}},
+		{"Spider-Man.No.Way.Home.2021.HDCAM.x264-NOGRP", Info{Title: "Spider-Man No Way Home", Year: 2021, Source: "CAM", VideoCodec: "H.264", Group: "NOGRP"}},
+		{"Spider-Man", Info{Title: "Spider-Man"}},
+		{"Ubuntu 22.04 Desktop", Info{Title: "Ubuntu 22 04 Desktop"}},
+		{"Friends.Season.3.Complete.DVDRip.XviD", Info{Title: "Friends", Season: 3, Source: "DVD", VideoCodec: "XviD"}},
+		{"The.Office.US.S05.720p.BluRay.x264-DEMAND", Info{Title: "The Office US", Season: 5, Resolution: "720p", Source: "BluRay", VideoCodec: "H.264", Group: "DEMAND"}},
+		{"Doctor.Who.2005.2x03.School.Reunion.DVDRip.XviD", Info{Title: "Doctor Who", Year: 2005, Season: 2, Episode: 3, Source: "DVD", VideoCodec: "XviD"}},
+		{"Dark.S01E01-E02.GERMAN.1080p.NF.WEBRip.x265.10bit-MZABI", Info{Title: "Dark", Season: 1, Episode: 1, Resolution: "1080p", Source: "WEBRip", VideoCodec: "H.265", Languages: []string{"German"}, Group: "MZABI"}},
+		{"Parasite.2019.KOREAN.1080p.BluRay.H264.AAC-VXT", Info{Title: "Parasite", Year: 2019, Resolution: "1080p", Source: "BluRay", VideoCodec: "H.264", AudioCodec: "AAC", Languages: []string{"Korean"}, Group: "VXT"}},
+		{"Movie.2020.MULTi.1080p.WEB.H264-GRP[rarbg]", Info{Title: "Movie", Year: 2020, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264", Languages: []string{"Multi"}, Group: "GRP"}},
+		{"Inception 2010 1080i HDTV MPEG2", Info{Title: "Inception", Year: 2010, Resolution: "1080i", Source: "HDTV", VideoCodec: "MPEG-2"}},
+		{"Dune.Part.Two.2024.4K.WEB-DL", Info{Title: "Dune Part Two", Year: 2024, Resolution: "2160p", Source: "WEB-DL"}},
+		{"2012.2009.720p.BluRay", Info{Title: "2012", Year: 2009, Resolution: "720p", Source: "BluRay"}},
+		{"Some.Movie.0.1080p.x264", Info{Title: "Some Movie 0", Resolution: "1080p", VideoCodec: "H.264"}},
+		{"The_Simpsons_S35E01_1080p_WEB_H264", Info{Title: "The Simpsons", Season: 35, Episode: 1, Resolution: "1080p", Source: "WEB-DL", VideoCodec: "H.264"}},
+		{"Planet Earth II Episode 3 2160p", Info{Title: "Planet Earth II", Episode: 3, Resolution: "2160p"}},
+		{"Oppenheimer.2023.PROPER.720p.CAMRip.ENG", Info{Title: "Oppenheimer", Year: 2023, Resolution: "720p", Source: "CAM", Languages: []string{"English"}}},
+		{"Movie.H.264-GRP", Info{Title: "Movie", VideoCodec: "H.264", Group: "GRP"}},
+		{"Movie.H.265.1080p-GRP", Info{Title: "Movie", Resolution: "1080p", VideoCodec: "H.265", Group: "GRP"}},
+		{"Movie x 264", Info{Title: "Movie", VideoCodec: "H.264"}},
+		{"Interstellar.2014.IMAX.2160p.UHD.BluRay.x265.10bit.HDR.DTS-HD.MA.5.1-SWTYBLZ", Info{Title: "Interstellar", Year: 2014, Resolution: "2160p", Source: "BluRay", VideoCodec: "H.265", AudioCodec: "DTS-HD", Group: "SWTYBLZ"}},
+		{"Breaking.Bad.S05E16.Felina.720p.WEB-DL.DD5.1.H.264-BS", Info{Title: "Breaking Bad", Season: 5, Episode: 16, Resolution: "720p", Source: "WEB-DL", VideoCodec: "H.264", AudioCodec: "AC3", Group: "BS"}},
+		{"Show S01 E02", Info{Title: "Show", Season: 1, Episode: 2}},
+		{"Movie.720p.BluRay.x264-", Info{Title: "Movie", Resolution: "720p", Source: "BluRay", VideoCodec: "H.264"}},
+		{"Show Season 1-8", Info{Title: "Show", Season: 1}},
+		{"Show.S01-S08.1080p", Info{Title: "Show", Season: 1, Resolution: "1080p"}},
+		{"Show.S01-08.720p", Info{Title: "Show", Season: 1, Resolution: "720p"}},
+		{"", Info{}},
+	} {
+		if got := Parse(c.name); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Parse(%q):\n got %+v\nwant %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package release
+
+import "strings"
+
+// Sources lists the canonical names of the sources a release can be
+// ripped from.
+var Sources = []string{"BluRay", "WEB-DL", "WEBRip", "HDTV", "DVD", "HDRip", "CAM", "TS", "TC", "SCR", "VHS"}
+
+var sources = map[string]string{
+	"bluray":   "BluRay",
+	"blu-ray":  "BluRay",
+	"bdrip":    "BluRay",
+	"brrip":    "BluRay",
+	"bdremux":  "BluRay",
+	"bd":       "BluRay",
+	"web-dl":   "WEB-DL",
+	"webdl":    "WEB-DL",
+	"web":      "WEB-DL",
+	"webrip":   "WEBRip",
+	"web-rip":  "WEBRip",
+	"hdtv":     "HDTV",
+	"pdtv":     "HDTV",
+	"hdtvrip":  "HDTV",
+	"dvd":      "DVD",
+	"dvdrip":   "DVD",
+	"dvdr":     "DVD",
+	"dvd5":     "DVD",
+	"dvd9":     "DVD",
+	"hdrip":    "HDRip",
+	"cam":      "CAM",
+	"camrip":   "CAM",
+	"hdcam":    "CAM",
+	"ts":       "TS",
+	"hdts":     "TS",
+	"telesync": "TS",
+	"tc":       "TC",
+	"telecine": "TC",
+	"scr":      "SCR",
+	"dvdscr":   "SCR",
+	"screener": "SCR",
+	"vhsrip":   "VHS",
+}
+
+// VideoCodecs lists the canonical names of video codecs.
+var VideoCodecs = []string{"H.264", "H.265", "XviD", "DivX", "AV1", "VP9", "MPEG-2"}
+
+var videoCodecs = map[string]string{
+	"x264":  "H.264",
+	"h264":  "H.264",
+	"avc":   "H.264",
+	"x265":  "H.265",
+	"h265":  "H.265",
+	"hevc":  "H.265",
+	"xvid":  "XviD",
+	"divx":  "DivX",
+	"av1":   "AV1",
+	"vp9":   "VP9",
+	"mpeg2": "MPEG-2",
+}
+
+var audioCodecs = map[string]string{
+	"aac":    "AAC",
+	"ac3":    "AC3",
+	"dd":     "AC3",
+	"eac3":   "EAC3",
+	"ddp":    "EAC3",
+	"dd+":    "EAC3",
+	"dts":    "DTS",
+	"dts-hd": "DTS-HD",
+	"dtshd":  "DTS-HD",
+	"truehd": "TrueHD",
+	"atmos":  "Atmos",
+	"flac":   "FLAC",
+	"mp3":    "MP3",
+	"opus":   "Opus",
+	"lpcm":   "PCM",
+	"pcm":    "PCM",
+}
+
+// Languages lists the canonical names of languages, "Multi" standing for
+// releases with several audio tracks.
+var Languages = []string{"English", "French", "German", "Spanish", "Italian", "Russian", "Japanese",
+	"Korean", "Chinese", "Hindi", "Portuguese", "Dutch", "Swedish", "Polish", "Turkish", "Multi"}
+
+var languages = map[string]string{
+	"english":    "English",
+	"eng":        "English",
+	"french":     "French",
+	"truefrench": "French",
+	"fre":        "French",
+	"fra":        "French",
+	"vff":        "French",
+	"german":     "German",
+	"ger":        "German",
+	"deu":        "German",
+	"spanish":    "Spanish",
+	"spa":        "Spanish",
+	"esp":        "Spanish",
+	"castellano": "Spanish",
+	"latino":     "Spanish",
+	"italian":    "Italian",
+	"ita":        "Italian",
+	"russian":    "Russian",
+	"rus":        "Russian",
+	"japanese":   "Japanese",
+	"jpn":        "Japanese",
+	"korean":     "Korean",
+	"kor":        "Korean",
+	"chinese":    "Chinese",
+	"chi":        "Chinese",
+	"mandarin":   "Chinese",
+	"hindi":      "Hindi",
+	"hin":        "Hindi",
+	"portuguese": "Portuguese",
+	"por":        "Portuguese",
+	"dutch":      "Dutch",
+	"swedish":    "Swedish",
+	"swe":        "Swedish",
+	"polish":     "Polish",
+	"turkish":    "Turkish",
+	"tur":        "Turkish",
+	"multi":      "Multi",
+}
+
+// tags are words releases carry that say nothing this package extracts,
+// but end the title all the same.
+var tags = map[string]bool{
+	"proper":     true,
+	"repack":     true,
+	"internal":   true,
+	"limited":    true,
+	"extended":   true,
+	"unrated":    true,
+	"uncut":      true,
+	"remastered": true,
+	"imax":       true,
+	"remux":      true,
+	"hdr":        true,
+	"hdr10":      true,
+	"10bit":      true,
+	"hi10":       true,
+	"hi10p":      true,
+	"dual-audio": true,
+	"complete":   true,
+	"dubbed":     true,
+	"subbed":     true,
+	"vostfr":     true,
+	"readnfo":    true,
+}
+
+// Source returns the canonical name of the source word stands for, as in
+// Source("bdrip") == "BluRay".
+func Source(word string) (string, bool) {
+	source, ok := sources[strings.ToLower(word)]
+	return source, ok
+}
+
+// VideoCodec returns the canonical name of the video codec word stands
+// for, as in VideoCodec("x265") == "H.265".
+func VideoCodec(word string) (string, bool) {
+	word = strings.ToLower(strings.ReplaceAll(word, ".", ""))
+	codec, ok := videoCodecs[word]
+	return codec, ok
+}
+
+// Language returns the canonical name of the language word stands for, as
+// in Language("ger") == "German".
+func Language(word string) (string, bool) {
+	language, ok := languages[strings.ToLower(word)]
+	return language, ok
+}
+
+// audioCodec returns the canonical name of the audio codec word stands
+// for, ignoring a channel count glued to it as in "DD5" or "AAC2".
+func audioCodec(word string) (string, bool) {
+	if codec, ok := audioCodecs[word]; ok {
+		return codec, true
+	}
+	codec, ok := audioCodecs[strings.TrimRight(word, "0123456789")]
+	return codec, ok
+}
+
+// Lines returns the lines of a resolution, as in Lines("1080p") == 1080,
+// or 0 if resolution is not one.
+func Lines(resolution string) int {
+	resolution = strings.ToLower(resolution)
+	switch resolution {
+	case "4k", "uhd":
+		return 2160
+	case "8k":
+		return 4320
+	}
+	digits := strings.TrimRight(resolution, "pi")
+	if len(digits) < 3 || len(digits) > 4 || len(resolution)-len(digits) > 1 {
+		return 0
+	}
+	lines := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		lines = lines*10 + int(c-'0')
+	}
+	return lines
+}
@@ -0,0 +1,287 @@
+package search
+
+import (
+	"sort"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// evaluator finds the chunks matching a query. Terms are looked up in the
+// postings and filters in the attributes, which makes their matches exact.
+// Phrases and group filters need the items themselves: postings, if any,
+// only narrow down candidates that match then checks against the item.
+type evaluator struct {
+	ix     *Index
+	fields fieldSet // where terms are looked for
+
+	// The matches of the nodes whose candidates are exact, by increasing
+	// chunk ID.
+	lists map[queryNode][]Result
+}
+
+// candidates returns the chunks that may match node, by increasing ID,
+// with their score, and whether they all match. all reports that any item
+// may match, without a list. The caller must hold ix.mu.
+func (e *evaluator) candidates(node queryNode) (list []Result, all, exact bool) {
+	switch node := node.(type) {
+	case *termNode:
+		list, exact = e.ix.groupScores(e.ix.group(node), e.fields), true
+
+	case *phraseNode:
+		lists := make([][]Result, len(node.terms))
+		for i, term := range node.terms {
+			lists[i] = e.ix.groupScores(e.ix.group(&termNode{term: term, exact: true}), e.fields)
+		}
+		list = intersectAll(lists)
+
+	case *filterNode:
+		// Groups are not indexed.
+		if node.field == groupFilter {
+			return nil, true, false
+		}
+		for id, attrs := range e.ix.attrs {
+			if node.matches(attrs) {
+				list = append(list, Result{ID: id})
+			}
+		}
+		exact = true
+
+	case *allNode:
+		var lists, excluded [][]Result
+		exact = true
+		for _, child := range node.nodes {
+			// Exact exclusions are subtracted rather than listed.
+			if not, ok := child.(*notNode); ok {
+				childList, childAll, childExact := e.candidates(not.node)
+				if childExact && !childAll {
+					excluded = append(excluded, childList)
+				} else {
+					exact = false
+				}
+				continue
+			}
+
+			childList, childAll, childExact := e.candidates(child)
+			exact = exact && childExact
+			if !childAll {
+				lists = append(lists, childList)
+			}
+		}
+
+		if len(lists) > 0 {
+			list = intersectAll(lists)
+		} else if len(excluded) > 0 {
+			list = e.universe()
+		} else {
+			return nil, true, false
+		}
+		for _, exclusion := range excluded {
+			list = subtractResults(list, exclusion)
+		}
+
+	case *anyNode:
+		exact = true
+		for _, child := range node.nodes {
+			childList, childAll, childExact := e.candidates(child)
+			if childAll {
+				return nil, true, false
+			}
+			exact = exact && childExact
+			list = unionScores(list, childList)
+		}
+
+	case *notNode:
+		childList, childAll, childExact := e.candidates(node.node)
+		if childAll || !childExact {
+			return nil, true, false
+		}
+		list, exact = subtractResults(e.universe(), childList), true
+	}
+
+	if exact {
+		e.lists[node] = list
+	}
+	return list, all, exact
+}
+
+// universe returns every chunk holding an item, with a null score. The
+// caller must hold ix.mu.
+func (e *evaluator) universe() []Result {
+	var list []Result
+	for id, lengths := range e.ix.lengths {
+		if lengths != (fieldLengths{}) {
+			list = append(list, Result{ID: id})
+		}
+	}
+	return list
+}
+
+// match reports whether the item of chunk id matches node.
+func (e *evaluator) match(node queryNode, id int, doc *document) bool {
+	if list, ok := e.lists[node]; ok {
+		i := sort.Search(len(list), func(i int) bool { return list[i].ID >= id })
+		return i < len(list) && list[i].ID == id
+	}
+
+	switch node := node.(type) {
+	case *phraseNode:
+		return doc.load(e.ix, id) && doc.containsPhrase(e.ix, node.terms, e.fields)
+
+	case *filterNode:
+		return doc.load(e.ix, id) && node.matchesGroup(doc.item)
+
+	case *allNode:
+		for _, child := range node.nodes {
+			if !e.match(child, id, doc) {
+				return false
+			}
+		}
+		return true
+
+	case *anyNode:
+		for _, child := range node.nodes {
+			if e.match(child, id, doc) {
+				return true
+			}
+		}
+		return false
+
+	case *notNode:
+		return !e.match(node.node, id, doc)
+	}
+	return false
+}
+
+// document is an item read on demand while matching it.
+type document struct {
+	loaded, ok bool
+	item       catalog.Item
+	name, desc []string // desc is nil until descTerms is called
+	files      [][]string
+	filesRead  bool
+}
+
+func (doc *document) reset() {
+	*doc = document{}
+}
+
+// load reads the item of chunk id, reporting false if it cannot be read.
+func (doc *document) load(ix *Index, id int) bool {
+	if doc.loaded {
+		return doc.ok
+	}
+	doc.loaded = true
+
+	chunk, err := ix.storage.GetChunkById(id)
+	if err != nil {
+		return false
+	}
+	item, err := catalog.DecodeItem(chunk)
+	if err != nil {
+		return false
+	}
+
+	doc.item, doc.name = item, Tokenize(item.Name)
+	doc.ok = true
+	return true
+}
+
+// descTerms returns the terms of the description of the loaded item.
+func (doc *document) descTerms() []string {
+	if doc.desc == nil {
+		doc.desc = Tokenize(doc.item.Description)
+	}
+	return doc.desc
+}
+
+// fileTerms returns the terms of each cached file path of the loaded item.
+// It takes ix.mu.
+func (doc *document) fileTerms(ix *Index) [][]string {
+	if doc.filesRead {
+		return doc.files
+	}
+	doc.filesRead = true
+
+	hash, err := doc.item.InfoHash()
+	if err != nil {
+		return nil
+	}
+	ix.mu.RLock()
+	paths := ix.fileLists[hash]
+	ix.mu.RUnlock()
+	for _, path := range paths {
+		doc.files = append(doc.files, Tokenize(path))
+	}
+	return doc.files
+}
+
+// containsPhrase reports whether the loaded item holds terms in a row in
+// one of fields, file paths each on their own.
+func (doc *document) containsPhrase(ix *Index, terms []string, fields fieldSet) bool {
+	if fields&nameField != 0 && containsPhrase(doc.name, terms, false) {
+		return true
+	}
+	if fields&descField != 0 && containsPhrase(doc.descTerms(), terms, false) {
+		return true
+	}
+	if fields&filesField != 0 {
+		for _, path := range doc.fileTerms(ix) {
+			if containsPhrase(path, terms, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// intersectAll intersects lists, the shortest first to keep the work
+// bounded by the rarest term.
+func intersectAll(lists [][]Result) []Result {
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	list := lists[0]
+	for _, other := range lists[1:] {
+		if len(list) == 0 {
+			break
+		}
+		list = intersectScores(list, other)
+	}
+	return list
+}
+
+// unionScores returns the chunks present in either list, by increasing ID,
+// with their scores added.
+func unionScores(a, b []Result) []Result {
+	results := make([]Result, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i].ID < b[j].ID:
+			results = append(results, a[i])
+			i++
+		case a[i].ID > b[j].ID:
+			results = append(results, b[j])
+			j++
+		default:
+			results = append(results, Result{ID: a[i].ID, Score: a[i].Score + b[j].Score})
+			i++
+			j++
+		}
+	}
+	results = append(results, a[i:]...)
+	return append(results, b[j:]...)
+}
+
+// subtractResults returns the chunks of a missing from b, by increasing ID.
+func subtractResults(a, b []Result) []Result {
+	var results []Result
+	j := 0
+	for _, result := range a {
+		for j < len(b) && b[j].ID < result.ID {
+			j++
+		}
+		if j == len(b) || b[j].ID != result.ID {
+			results = append(results, result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,268 @@
+package search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// The file lists of torrents are learnt when their metadata is fetched,
+// not from the chunks, so they are cached apart from the derived files
+// that compaction removes, by info hash, in a log of records:
+//
+//	record:  length uvarint | hash [20]byte | paths uvarint |
+//	         (length uvarint | path)... | crc32c uint32 of what precedes it from hash on
+//
+// A record cut short by a crash is dropped on load, and a damaged one
+// skipped.
+const fileListsSuffix = ".files"
+
+// AddFiles records the paths of the files of the torrent with info hash
+// hash, which the query qualifier in:files searches, and caches them for
+// good unless the storage was opened read-only. The files of a torrent are
+// only recorded once.
+func (ix *Index) AddFiles(hash metainfo.Hash, paths []string) error {
+	ix.mu.Lock()
+	if _, ok := ix.fileLists[hash]; ok {
+		ix.mu.Unlock()
+		return nil
+	}
+	paths = append([]string(nil), paths...)
+	ix.fileLists[hash] = paths
+	if chunkid, ok := ix.storage.LookupByInfoHash(hash); ok {
+		ix.addFiles(chunkid, paths)
+		if ix.terms == nil {
+			ix.sortTerms()
+		}
+	}
+	ix.mu.Unlock()
+	if ix.storage.ReadOnly() {
+		return nil
+	}
+
+	ix.filesMu.Lock()
+	defer ix.filesMu.Unlock()
+	if err := appendFileList(ix.filesPath, hash, paths); err != nil {
+		return fmt.Errorf("caching file list: %v", err)
+	}
+	return nil
+}
+
+// addFiles indexes paths as the files of chunk chunkid, unless the chunk
+// is not indexed or its files already are. Like add, it leaves terms nil
+// if it indexes new terms. The caller must hold mu.
+func (ix *Index) addFiles(chunkid int, paths []string) {
+	if chunkid >= len(ix.lengths) || ix.lengths[chunkid] == (fieldLengths{}) || ix.lengths[chunkid].files != 0 {
+		return
+	}
+
+	counts := make(map[string]uint16)
+	length := 0
+	for _, path := range paths {
+		for _, term := range Tokenize(path) {
+			counts[term] = saturatingIncrement(counts[term])
+			length++
+		}
+	}
+	if length == 0 {
+		return
+	}
+
+	for term, count := range counts {
+		list, ok := ix.postings[term]
+		if !ok {
+			ix.terms = nil
+		}
+		i := searchPostings(list, chunkid)
+		if i < len(list) && int(list[i].id) == chunkid {
+			list[i].files = count
+			continue
+		}
+		list = append(list, posting{})
+		copy(list[i+1:], list[i:])
+		list[i] = posting{id: uint32(chunkid), files: count}
+		ix.postings[term] = list
+	}
+
+	ix.lengths[chunkid].files = clampLength(length)
+	ix.total.files += int64(ix.lengths[chunkid].files)
+	ix.total.withFiles++
+	ix.dirty = true
+}
+
+// addCachedFiles indexes the cached file lists of the chunks whose files
+// are not indexed yet. Like add, it leaves terms nil if it indexes new
+// terms. The caller must hold mu.
+func (ix *Index) addCachedFiles() {
+	for hash, paths := range ix.fileLists {
+		if chunkid, ok := ix.storage.LookupByInfoHash(hash); ok {
+			ix.addFiles(chunkid, paths)
+		}
+	}
+}
+
+// searchPostings returns the index of the first posting of list not below
+// chunkid.
+func searchPostings(list []posting, chunkid int) int {
+	lo, hi := 0, len(list)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if int(list[mid].id) < chunkid {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// loadFileLists reads the cached file lists. A damaged record is skipped
+// and the records after it kept, past its length if that holds up and at
+// the next valid record otherwise; the file is then rewritten without it.
+// A trailing record cut short is dropped. With readOnly, records are only
+// skipped in memory and the file is left as it is.
+func loadFileLists(filepath string, readOnly bool) (map[metainfo.Hash][]string, error) {
+	lists := make(map[metainfo.Hash][]string)
+	content, err := os.ReadFile(filepath)
+	if errors.Is(err, os.ErrNotExist) {
+		return lists, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading file lists: %v", err)
+	}
+
+	var kept [][]byte // the valid records, written back if any was skipped
+	b := content
+	valid := 0 // bytes of content up to the end of the last valid record
+	damaged := false
+	for len(b) > 0 {
+		start := b
+		hash, paths, ok := readFileList(&b)
+		if ok {
+			lists[hash] = paths
+			kept = append(kept, start[:len(start)-len(b)])
+			valid = len(content) - len(b)
+			continue
+		}
+		next := nextFileList(b)
+		if next < 0 {
+			break
+		}
+		b = b[next:]
+		damaged = true
+	}
+
+	if readOnly {
+		return lists, nil
+	}
+	if damaged {
+		tmp := filepath + ".tmp"
+		err = writeSynced(tmp, bytes.Join(kept, nil))
+		if err == nil {
+			err = os.Rename(tmp, filepath)
+		}
+		if err != nil {
+			_ = os.Remove(tmp)
+			return nil, fmt.Errorf("rewriting damaged file lists: %v", err)
+		}
+	} else if valid < len(content) {
+		if err := os.Truncate(filepath, int64(valid)); err != nil {
+			return nil, fmt.Errorf("dropping torn file list: %v", err)
+		}
+	}
+	return lists, nil
+}
+
+// nextFileList returns the offset in b of the first valid record after the
+// invalid one b starts with, or -1 if there is none.
+func nextFileList(b []byte) int {
+	rest := b
+	if length, ok := readUvarint(&rest); ok && length >= 20+4 && length <= uint64(len(rest)) {
+		skip := len(b) - len(rest) + int(length)
+		if next := rest[length:]; len(next) == 0 || isFileList(next) {
+			return skip
+		}
+	}
+	for i := 1; i < len(b); i++ {
+		if isFileList(b[i:]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isFileList tells whether b starts with a valid record.
+func isFileList(b []byte) bool {
+	_, _, ok := readFileList(&b)
+	return ok
+}
+
+// readFileList reads a record off b, which it leaves untouched if the
+// record is invalid.
+func readFileList(b *[]byte) (metainfo.Hash, []string, bool) {
+	rest := *b
+	length, ok := readUvarint(&rest)
+	if !ok || length < 20+4 || length > uint64(len(rest)) {
+		return metainfo.Hash{}, nil, false
+	}
+	record, checksum := rest[:length-4], rest[length-4:length]
+	if crc32.Checksum(record, castagnoli) != binary.LittleEndian.Uint32(checksum) {
+		return metainfo.Hash{}, nil, false
+	}
+
+	var hash metainfo.Hash
+	copy(hash[:], record)
+	record = record[20:]
+	count, ok := readUvarint(&record)
+	if !ok || count > uint64(len(record)) {
+		return metainfo.Hash{}, nil, false
+	}
+	paths := make([]string, count)
+	for i := range paths {
+		n, ok := readUvarint(&record)
+		if !ok || n > uint64(len(record)) {
+			return metainfo.Hash{}, nil, false
+		}
+		paths[i] = string(record[:n])
+		record = record[n:]
+	}
+
+	*b = rest[length:]
+	return hash, paths, true
+}
+
+// appendFileList appends a record to the cached file lists.
+func appendFileList(filepath string, hash metainfo.Hash, paths []string) error {
+	var record bytes.Buffer
+	record.Write(hash[:])
+	writeUvarint(&record, uint64(len(paths)))
+	for _, path := range paths {
+		writeUvarint(&record, uint64(len(path)))
+		record.WriteString(path)
+	}
+	checksum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(checksum, crc32.Checksum(record.Bytes(), castagnoli))
+	record.Write(checksum)
+
+	var buf bytes.Buffer
+	writeUvarint(&buf, uint64(record.Len()))
+	buf.Write(record.Bytes())
+
+	f, err := os.OpenFile(filepath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+func TestLoadFileLists(t *testing.T) {
+	const lists, damaged = 10, 4
+
+	hashOf := func(i int) metainfo.Hash { return metainfo.Hash{byte(i + 1)} }
+	pathsOf := func(i int) []string {
+		return []string{fmt.Sprintf("Movie.%d/Movie.%d.mkv", i, i), fmt.Sprintf("Movie.%d/Sample/sample.mkv", i)}
+	}
+
+	// Each test damages the file, given the offsets of its records, and
+	// lists the records lost.
+	for _, test := range []struct {
+		name   string
+		damage func(content []byte, offsets []int) []byte
+		lost   []int
+	}{
+		{"intact", func(content []byte, offsets []int) []byte { return content }, nil},
+		{"torn tail", func(content []byte, offsets []int) []byte {
+			return content[:len(content)-5]
+		}, []int{lists - 1}},
+		{"zero-filled tail", func(content []byte, offsets []int) []byte {
+			copy(content[offsets[lists-1]:], make([]byte, len(content)))
+			return content
+		}, []int{lists - 1}},
+		{"damaged path", func(content []byte, offsets []int) []byte {
+			content[offsets[damaged]+30] ^= 1
+			return content
+		}, []int{damaged}},
+		{"damaged length", func(content []byte, offsets []int) []byte {
+			content[offsets[damaged]] ^= 0x40
+			return content
+		}, []int{damaged}},
+		{"damaged final record", func(content []byte, offsets []int) []byte {
+			content[len(content)-1] ^= 1
+			return content
+		}, []int{lists - 1}},
+		{"damaged record and torn tail", func(content []byte, offsets []int) []byte {
+			content[offsets[damaged]+30] ^= 1
+			return content[:len(content)-5]
+		}, []int{damaged, lists - 1}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			filepath := path.Join(t.TempDir(), "w"+fileListsSuffix)
+			var offsets []int
+			for i := 0; i < lists; i++ {
+				if info, err := os.Stat(filepath); err == nil {
+					offsets = append(offsets, int(info.Size()))
+				} else {
+					offsets = append(offsets, 0)
+				}
+				if err := appendFileList(filepath, hashOf(i), pathsOf(i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			content, err := os.ReadFile(filepath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath, test.damage(append([]byte(nil), content...), offsets), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			want := make(map[metainfo.Hash][]string)
+			var wantContent []byte
+			for i := 0; i < lists; i++ {
+				lost := false
+				for _, j := range test.lost {
+					lost = lost || i == j
+				}
+				if lost {
+					continue
+				}
+				want[hashOf(i)] = pathsOf(i)
+				end := len(content)
+				if i+1 < lists {
+					end = offsets[i+1]
+				}
+				wantContent = append(wantContent, content[offsets[i]:end]...)
+			}
+
+			// A read-only load skips the same records and leaves the file
+			// alone.
+			damagedContent, err := os.ReadFile(filepath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadFileLists(filepath, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("read-only load: got %d lists, want %d", len(got), len(want))
+			}
+			if after, err := os.ReadFile(filepath); err != nil || !bytes.Equal(after, damagedContent) {
+				t.Fatalf("read-only load changed the file: %v", err)
+			}
+
+			// The second load finds the file as the first left it.
+			for load := 0; load < 2; load++ {
+				got, err := loadFileLists(filepath, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("load %d: got %d lists, want %d", load, len(got), len(want))
+				}
+				after, err := os.ReadFile(filepath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(after, wantContent) {
+					t.Fatalf("load %d: file of %d bytes, want %d", load, len(after), len(wantContent))
+				}
+			}
+		})
+	}
+}
+
+// TestReadOnlyFileLists opens the index of a read-only storage whose cached
+// file lists end in a torn record, and checks that neither opening it nor
+// adding files writes to them.
+func TestReadOnlyFileLists(t *testing.T) {
+	ix := openTestIndex(t, []catalog.Item{{Name: "Sintel.2010.720p"}})
+	hash := metainfo.Hash{19: 1}
+	if err := ix.AddFiles(hash, []string{"Sintel/Sintel.mkv"}); err != nil {
+		t.Fatal(err)
+	}
+	storage := ix.storage
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(ix.filesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = append(content, content[:len(content)-5]...)
+	if err := os.WriteFile(ix.filesPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, prefix := path.Split(storage.Path)
+	storage, err = chunk_storage.New(dir, prefix, chunk_storage.Options{InfoHashFunc: catalog.ChunkInfoHash, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	ix, err = Open(storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.AddFiles(metainfo.Hash{19: 2}, []string{"Other/Other.mkv"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ix.fileLists[hash]; !reflect.DeepEqual(got, []string{"Sintel/Sintel.mkv"}) {
+		t.Errorf("cached files %q", got)
+	}
+	if _, ok := ix.fileLists[metainfo.Hash{19: 2}]; !ok {
+		t.Error("files added to a read-only index not recorded")
+	}
+	if after, err := os.ReadFile(ix.filesPath); err != nil || !bytes.Equal(after, content) {
+		t.Errorf("file lists of a read-only storage written: %v", err)
+	}
+}
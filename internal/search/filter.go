@@ -0,0 +1,275 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/release"
+)
+
+type filterField int
+
+const (
+	yearFilter filterField = iota
+	resolutionFilter
+	codecFilter
+	sourceFilter
+	languageFilter
+	seasonFilter
+	episodeFilter
+	sizeFilter
+	filesFilter
+	groupFilter
+)
+
+var filterFields = map[string]filterField{
+	"year":       yearFilter,
+	"res":        resolutionFilter,
+	"resolution": resolutionFilter,
+	"codec":      codecFilter,
+	"source":     sourceFilter,
+	"lang":       languageFilter,
+	"season":     seasonFilter,
+	"episode":    episodeFilter,
+	"ep":         episodeFilter,
+	"size":       sizeFilter,
+	"files":      filesFilter,
+	"group":      groupFilter,
+}
+
+// filterNode matches items whose attribute compares to value with op, one
+// of "=", "<", "<=", ">" and ">=". Attributes come from the fields of the
+// item and from what release.Parse finds in its name. Years, seasons,
+// episodes and file counts are compared as numbers, resolutions by their
+// lines (1080p and 1080i are both 1080, 4k is 2160) and sizes in bytes.
+// Codecs, sources, languages and groups are only compared for equality,
+// by their canonical names: x264, h264 and avc are the same codec. Items
+// missing the attribute never match.
+type filterNode struct {
+	field filterField
+	op    string
+	value int64  // for codecs, sources and languages, as in itemAttributes
+	group string // for groupFilter
+}
+
+// parseFilter parses word as a filter, reporting false if it is not one:
+// a known attribute followed by ':' or a comparison, as in "year:1999",
+// "year>=1990" or "year:>=1990".
+func parseFilter(word string) (*filterNode, bool, error) {
+	i := strings.IndexAny(word, ":<>=")
+	if i < 0 {
+		return nil, false, nil
+	}
+	name := strings.ToLower(word[:i])
+	field, ok := filterFields[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	rest := strings.TrimPrefix(word[i:], ":")
+	op := "="
+	for _, candidate := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(rest, candidate) {
+			op, rest = candidate, rest[len(candidate):]
+			break
+		}
+	}
+	if rest == "" {
+		return nil, false, fmt.Errorf("%s: missing value", name)
+	}
+
+	filter := &filterNode{field: field, op: op}
+	switch field {
+	case codecFilter, sourceFilter, languageFilter, groupFilter:
+		if op != "=" {
+			return nil, false, fmt.Errorf("%s: only ':' applies", name)
+		}
+	}
+
+	switch field {
+	case yearFilter:
+		year, err := strconv.Atoi(rest)
+		if err != nil || year < 1000 || year > 9999 {
+			return nil, false, fmt.Errorf("year: '%s' is not a year", rest)
+		}
+		filter.value = int64(year)
+
+	case resolutionFilter:
+		lines := release.Lines(rest)
+		if lines == 0 {
+			return nil, false, fmt.Errorf("%s: '%s' is not a resolution such as 720p or 4k", name, rest)
+		}
+		filter.value = int64(lines)
+
+	case codecFilter:
+		codec, ok := release.VideoCodec(rest)
+		if !ok {
+			return nil, false, fmt.Errorf("codec: unknown codec '%s'", rest)
+		}
+		filter.value = int64(indexOf(release.VideoCodecs, codec) + 1)
+
+	case sourceFilter:
+		source, ok := release.Source(rest)
+		if !ok {
+			return nil, false, fmt.Errorf("source: unknown source '%s'", rest)
+		}
+		filter.value = int64(indexOf(release.Sources, source) + 1)
+
+	case languageFilter:
+		language, ok := release.Language(rest)
+		if !ok {
+			return nil, false, fmt.Errorf("lang: unknown language '%s'", rest)
+		}
+		filter.value = 1 << indexOf(release.Languages, language)
+
+	case seasonFilter, episodeFilter, filesFilter:
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, false, fmt.Errorf("%s: '%s' is not a number", name, rest)
+		}
+		filter.value = int64(n)
+
+	case sizeFilter:
+		size, ok := parseSize(rest)
+		if !ok {
+			return nil, false, fmt.Errorf("size: '%s' is not a size such as 700MB or 4GB", rest)
+		}
+		filter.value = size
+
+	case groupFilter:
+		filter.group = rest
+	}
+	return filter, true, nil
+}
+
+// sizeUnits are binary whatever their spelling: 1GB is 1GiB, as torrent
+// clients show sizes.
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"t":   1 << 40,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// parseSize returns the bytes of a size such as "700MB" or "1.5g".
+func parseSize(s string) (int64, bool) {
+	number := strings.TrimRightFunc(s, func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+	})
+	unit, ok := sizeUnits[strings.ToLower(s[len(number):])]
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value < 0 || value*unit >= 1<<63 {
+		return 0, false
+	}
+	return int64(value * unit), true
+}
+
+// itemAttributes are what filters compare, 0 when unknown. Codecs and
+// sources are numbered from 1 after their place in release.VideoCodecs and
+// release.Sources, and bit i of languages stands for release.Languages[i].
+// Snapshots store them as such, so reordering these lists calls for a new
+// snapshot version.
+type itemAttributes struct {
+	year, resolution, season, episode uint16
+	codec, source                     uint8
+	languages                         uint32
+	files                             uint32
+	size                              int64
+}
+
+// attributesOf finds the attributes of an item in its fields and name.
+func attributesOf(item catalog.Item) itemAttributes {
+	info := release.Parse(item.Name)
+	attrs := itemAttributes{
+		year:       clampLength(info.Year),
+		resolution: clampLength(release.Lines(info.Resolution)),
+		season:     clampLength(info.Season),
+		episode:    clampLength(info.Episode),
+		codec:      uint8(indexOf(release.VideoCodecs, info.VideoCodec) + 1),
+		source:     uint8(indexOf(release.Sources, info.Source) + 1),
+	}
+	for _, language := range info.Languages {
+		if i := indexOf(release.Languages, language); i >= 0 {
+			attrs.languages |= 1 << i
+		}
+	}
+	if item.FileCount > 0 && item.FileCount <= math.MaxUint32 {
+		attrs.files = uint32(item.FileCount)
+	}
+	if item.Size > 0 {
+		attrs.size = item.Size
+	}
+	return attrs
+}
+
+func indexOf(list []string, s string) int {
+	for i, e := range list {
+		if e == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// matches reports whether an item with attrs passes the filter, which
+// must not be on the group.
+func (f *filterNode) matches(attrs itemAttributes) bool {
+	var value int64
+	switch f.field {
+	case codecFilter:
+		return int64(attrs.codec) == f.value
+	case sourceFilter:
+		return int64(attrs.source) == f.value
+	case languageFilter:
+		return int64(attrs.languages)&f.value != 0
+	case yearFilter:
+		value = int64(attrs.year)
+	case resolutionFilter:
+		value = int64(attrs.resolution)
+	case seasonFilter:
+		value = int64(attrs.season)
+	case episodeFilter:
+		value = int64(attrs.episode)
+	case sizeFilter:
+		value = attrs.size
+	case filesFilter:
+		value = int64(attrs.files)
+	}
+	if value == 0 {
+		return false
+	}
+
+	switch f.op {
+	case "<":
+		return value < f.value
+	case "<=":
+		return value <= f.value
+	case ">":
+		return value > f.value
+	case ">=":
+		return value >= f.value
+	}
+	return value == f.value
+}
+
+// matchesGroup reports whether item was released by the group of a group
+// filter, whatever the case.
+func (f *filterNode) matchesGroup(item catalog.Item) bool {
+	return strings.EqualFold(release.Parse(item.Name).Group, f.group)
+}
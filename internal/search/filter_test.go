@@ -0,0 +1,67 @@
+package search
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+var filterCatalog = []catalog.Item{
+	{Name: "The.Matrix.1999.1080p.BluRay.x264-GRP", Size: 8 << 30, FileCount: 3},
+	{Name: "The.Matrix.1999.720p.WEB-DL.x265.FRENCH-Other", Size: 2 << 30, FileCount: 1},
+	{Name: "Matrix.Show.S02E05.2160p.WEBRip.x265-grp"},
+	{Name: "Matrix.Show.S01E10.480p.HDTV.XviD.GERMAN"},
+	{Name: "Matrix.Documentary", Size: 700 << 20},
+}
+
+func TestFilters(t *testing.T) {
+	ix := openTestIndex(t, filterCatalog)
+
+	for _, test := range []struct {
+		query string
+		want  []int // chunk IDs, in any order
+	}{
+		{"matrix year:1999", []int{0, 1}},
+		{"matrix year>1999", nil},
+		{"matrix year<=1999", []int{0, 1}},
+		{"matrix res:1080p", []int{0}},
+		{"matrix res>=720p", []int{0, 1, 2}},
+		{"matrix res<720", []int{3}},
+		{"matrix resolution:4k", []int{2}},
+		{"matrix codec:h264", []int{0}},
+		{"matrix codec:hevc", []int{1, 2}},
+		{"matrix codec:xvid", []int{3}},
+		{"matrix source:bluray", []int{0}},
+		{"matrix source:web", []int{1}},
+		{"matrix source:webrip", []int{2}},
+		{"matrix -source:hdtv", []int{0, 1, 2, 4}},
+		{"matrix lang:french", []int{1}},
+		{"matrix lang:german OR lang:french", []int{1, 3}},
+		{"matrix season:2", []int{2}},
+		{"matrix season<2", []int{3}},
+		{"matrix episode:5", []int{2}},
+		{"matrix ep>5", []int{3}},
+		{"matrix size>4GB", []int{0}},
+		{"matrix size<=2g", []int{1, 4}},
+		{"matrix size:700MB", []int{4}},
+		{"matrix files:1", []int{1}},
+		{"matrix files>=1", []int{0, 1}},
+		{"matrix group:grp", []int{0, 2}},
+		{"matrix group:Other", []int{1}},
+		{"matrix year:1999 res:720p", []int{1}},
+		{"matrix -year:1999", []int{2, 3, 4}},
+		{"year:1999 OR season:1", []int{0, 1, 3}},
+	} {
+		query, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%q: %v", test.query, err)
+		}
+		got := ix.Search(query)
+		sort.Ints(got)
+		if len(got) != len(test.want) || (len(got) > 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
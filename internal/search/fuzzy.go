@@ -0,0 +1,148 @@
+package search
+
+import (
+	"math/bits"
+	"unicode"
+)
+
+// fuzzyPenalty scales the score of a term matched with edits, once per
+// edit, so that exact matches rank above typo corrections.
+const fuzzyPenalty = 0.5
+
+// maxEdits returns how many edits a query term tolerates: none for short
+// terms, where a single edit gives an unrelated word, and for terms with
+// digits, where it gives another year or resolution.
+func maxEdits(term []rune) int {
+	for _, r := range term {
+		if unicode.IsDigit(r) {
+			return 0
+		}
+	}
+	switch {
+	case len(term) < 4:
+		return 0
+	case len(term) < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// fuzzyMatcher measures the edit distance from a query term to indexed
+// terms, reusing its buffers from one term to the next.
+type fuzzyMatcher struct {
+	term  []rune
+	mask  uint64
+	max   int
+	runes []rune
+	rows  [3][]int
+}
+
+func newFuzzyMatcher(term string) *fuzzyMatcher {
+	m := &fuzzyMatcher{term: []rune(term), mask: characterMask(term)}
+	m.max = maxEdits(m.term)
+	return m
+}
+
+// characterMask returns the set of characters of s, folded into 64 bits.
+func characterMask(s string) uint64 {
+	var mask uint64
+	for _, r := range s {
+		mask |= 1 << (uint(r) % 64)
+	}
+	return mask
+}
+
+// fuzzyTerm is an indexed term along with its characterMask.
+type fuzzyTerm struct {
+	term string
+	mask uint64
+}
+
+// distance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent characters turning the query term into
+// indexed, or max+1 if it exceeds max. Checking characters first spares
+// reading most terms, since their masks are computed on indexing.
+func (m *fuzzyMatcher) distance(indexed fuzzyTerm) int {
+	// Every character of one term missing from the other takes an edit of
+	// its own, which bounds the distance from below.
+	if bits.OnesCount64(m.mask&^indexed.mask) > m.max || bits.OnesCount64(indexed.mask&^m.mask) > m.max {
+		return m.max + 1
+	}
+	m.runes = append(m.runes[:0], []rune(indexed.term)...)
+	if abs(len(m.runes)-len(m.term)) > m.max {
+		return m.max + 1
+	}
+	a, b := m.term, m.runes
+
+	// Three rows of the optimal string alignment distance matrix.
+	for i := range m.rows {
+		if cap(m.rows[i]) < len(b)+1 {
+			m.rows[i] = make([]int, len(b)+1)
+		}
+		m.rows[i] = m.rows[i][:len(b)+1]
+	}
+	previous2, previous, current := m.rows[0], m.rows[1], m.rows[2]
+	for j := range previous {
+		previous[j] = j
+	}
+
+	// Cells further than max from the diagonal exceed max, so each row only
+	// computes the band around it and marks its edges as out of reach.
+	for i := 1; i <= len(a); i++ {
+		lo, hi := i-m.max, i+m.max
+		if lo < 1 {
+			lo = 1
+		}
+		if hi > len(b) {
+			hi = len(b)
+		}
+		if lo == 1 {
+			current[0] = i
+		} else {
+			current[lo-1] = m.max + 1
+		}
+		if hi < len(b) {
+			current[hi+1] = m.max + 1
+		}
+
+		rowMin := m.max + 1
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := previous[j-1] + cost
+			if previous[j]+1 < d {
+				d = previous[j] + 1
+			}
+			if current[j-1]+1 < d {
+				d = current[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && previous2[j-2]+1 < d {
+				d = previous2[j-2] + 1
+			}
+			current[j] = d
+			if d < rowMin {
+				rowMin = d
+			}
+		}
+		// Transpositions cannot bring a later row below this one's minimum.
+		if rowMin > m.max {
+			return m.max + 1
+		}
+		previous2, previous, current = previous, current, previous2
+	}
+
+	if previous[len(b)] > m.max {
+		return m.max + 1
+	}
+	return previous[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
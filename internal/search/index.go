@@ -0,0 +1,281 @@
+// Package search keeps a full-text index of the items of a catalog.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/anacrolix/torrent/metainfo"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// Index is an inverted index from the terms of item names, descriptions
+// and file lists to the IDs of the chunks holding them, along with the
+// attributes of each item that filters compare, found at index time. It is
+// persisted next to the storage it indexes and catches up with chunks
+// appended since on Update. File lists, which items do not carry, are
+// added with AddFiles. An Index is safe for concurrent use.
+type Index struct {
+	storage   *chunk_storage.ChunkStorage
+	path      string
+	filesPath string
+
+	mu        sync.RWMutex
+	postings  map[string][]posting // by increasing chunk ID
+	terms     []string             // sorted keys of postings, nil while Update runs
+	byLength  [][]fuzzyTerm        // terms by number of characters, for typos
+	lengths   []fieldLengths       // by chunk ID, zero for chunks without an item
+	attrs     []itemAttributes     // by chunk ID
+	total     fieldTotals
+	fileLists map[metainfo.Hash][]string // cached file lists, by info hash
+	dirty     bool                       // changed since the last Save
+
+	filesMu sync.Mutex // serializes appends to the cached file lists
+}
+
+// posting records the occurrences of a term in one item.
+type posting struct {
+	id    uint32
+	name  uint16 // occurrences in the name
+	desc  uint16 // occurrences in the description
+	files uint16 // occurrences in the file paths
+}
+
+// fieldLengths counts the terms of each field of an item.
+type fieldLengths struct {
+	name, desc, files uint16
+}
+
+// fieldTotals sums fieldLengths over the indexed items. Only items whose
+// files are known count towards the average length of file lists.
+type fieldTotals struct {
+	items, withFiles  int
+	name, desc, files int64
+}
+
+// Open loads the index of storage, or starts an empty one if it has none
+// or it does not match the storage any more, and indexes the chunks it is
+// missing.
+func Open(storage *chunk_storage.ChunkStorage) (*Index, error) {
+	ix := &Index{
+		storage:   storage,
+		path:      storage.DerivedPath("search"),
+		filesPath: storage.Path + fileListsSuffix,
+		postings:  make(map[string][]posting),
+	}
+
+	var err error
+	if ix.fileLists, err = loadFileLists(ix.filesPath, storage.ReadOnly()); err != nil {
+		return nil, err
+	}
+
+	if err := ix.load(); err != nil {
+		ix.postings, ix.lengths, ix.attrs, ix.total = make(map[string][]posting), nil, nil, fieldTotals{}
+	}
+
+	if _, err := ix.Update(); err != nil {
+		return nil, err
+	}
+
+	// Lists cached since the snapshot was saved.
+	ix.mu.Lock()
+	ix.addCachedFiles()
+	if ix.terms == nil {
+		ix.sortTerms()
+	}
+	ix.mu.Unlock()
+	return ix, nil
+}
+
+// Update indexes the chunks appended to the storage since the last call and
+// returns how many it went through.
+func (ix *Index) Update() (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	numberOfChunks := ix.storage.NumberOfChunks()
+	covered := len(ix.lengths)
+	if numberOfChunks < covered {
+		return 0, fmt.Errorf("search index covers %d chunks, storage has %d", covered, numberOfChunks)
+	}
+
+	it := ix.storage.Scan(covered, numberOfChunks, false)
+	for {
+		for it.Next() {
+			if item, err := catalog.DecodeItem(it.Bytes()); err == nil {
+				ix.add(it.ID(), item)
+			}
+		}
+
+		err := it.Err()
+		if err == nil {
+			break
+		} else if errors.Is(err, chunk_storage.ErrCompacted) {
+			return len(ix.lengths) - covered, fmt.Errorf("updating search index: %v", err)
+		}
+		it = ix.storage.Scan(it.ID()+1, numberOfChunks, false)
+	}
+
+	// Chunks deleted or without an item take no part in the index.
+	ix.pad(numberOfChunks)
+
+	if covered != numberOfChunks {
+		ix.dirty = true
+		ix.addCachedFiles()
+	}
+	if ix.terms == nil {
+		ix.sortTerms()
+	}
+	return numberOfChunks - covered, nil
+}
+
+// sortTerms rebuilds the sorted list of terms and their grouping by
+// length. The caller must hold mu.
+func (ix *Index) sortTerms() {
+	ix.terms = make([]string, 0, len(ix.postings))
+	for term := range ix.postings {
+		ix.terms = append(ix.terms, term)
+	}
+	sort.Strings(ix.terms)
+
+	ix.byLength = nil
+	for _, term := range ix.terms {
+		n := utf8.RuneCountInString(term)
+		for len(ix.byLength) <= n {
+			ix.byLength = append(ix.byLength, nil)
+		}
+		ix.byLength[n] = append(ix.byLength[n], fuzzyTerm{term: term, mask: characterMask(term)})
+	}
+}
+
+// add indexes item as chunk chunkid, which follows every chunk indexed so
+// far. The caller must hold mu.
+func (ix *Index) add(chunkid int, item catalog.Item) {
+	ix.pad(chunkid)
+
+	counts := make(map[string]*posting)
+	var order []string
+
+	nameTerms, descTerms := Tokenize(item.Name), Tokenize(item.Description)
+	for field, terms := range [][]string{nameTerms, descTerms} {
+		for _, term := range terms {
+			p, ok := counts[term]
+			if !ok {
+				p = &posting{id: uint32(chunkid)}
+				counts[term] = p
+				order = append(order, term)
+			}
+			if field == 0 {
+				p.name = saturatingIncrement(p.name)
+			} else {
+				p.desc = saturatingIncrement(p.desc)
+			}
+		}
+	}
+
+	for _, term := range order {
+		if _, ok := ix.postings[term]; !ok {
+			ix.terms = nil
+		}
+		ix.postings[term] = append(ix.postings[term], *counts[term])
+	}
+
+	lengths := fieldLengths{name: clampLength(len(nameTerms)), desc: clampLength(len(descTerms))}
+	ix.lengths = append(ix.lengths, lengths)
+	ix.attrs = append(ix.attrs, attributesOf(item))
+	ix.total.items++
+	ix.total.name += int64(lengths.name)
+	ix.total.desc += int64(lengths.desc)
+}
+
+// pad covers chunks up to numberOfChunks without indexing them. The caller
+// must hold mu.
+func (ix *Index) pad(numberOfChunks int) {
+	for len(ix.lengths) < numberOfChunks {
+		ix.lengths = append(ix.lengths, fieldLengths{})
+		ix.attrs = append(ix.attrs, itemAttributes{})
+	}
+}
+
+func saturatingIncrement(n uint16) uint16 {
+	if n == math.MaxUint16 {
+		return n
+	}
+	return n + 1
+}
+
+func clampLength(n int) uint16 {
+	if n > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(n)
+}
+
+// Search returns the IDs of the chunks whose item matches q, best match
+// first, as ranked by Rank.
+func (ix *Index) Search(q *Query) []int {
+	results := ix.Rank(q)
+	ids := make([]int, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+// matchGroup is the set of indexed terms a query term matches: the term
+// itself, every term it is a prefix of if it matches as a prefix, and the
+// terms within maxEdits of it unless it must match exactly.
+type matchGroup []matchTerm
+
+// matchTerm is an indexed term matching a query term with edits edits.
+type matchTerm struct {
+	term  string
+	edits int
+}
+
+// group resolves a term of a query. The caller must hold mu.
+func (ix *Index) group(node *termNode) matchGroup {
+	var group matchGroup
+	if node.prefix {
+		first := sort.SearchStrings(ix.terms, node.term)
+		for _, indexed := range ix.terms[first:] {
+			if !strings.HasPrefix(indexed, node.term) {
+				break
+			}
+			group = append(group, matchTerm{term: indexed})
+		}
+	} else if _, ok := ix.postings[node.term]; ok {
+		group = append(group, matchTerm{term: node.term})
+	}
+	if node.exact {
+		return group
+	}
+
+	// Terms whose length differs by more than max characters are more than
+	// max edits away, so looking for typos only goes through the terms of
+	// similar length.
+	matcher := newFuzzyMatcher(node.term)
+	if matcher.max == 0 {
+		return group
+	}
+	for length := len(matcher.term) - matcher.max; length <= len(matcher.term)+matcher.max; length++ {
+		if length < 0 || length >= len(ix.byLength) {
+			continue
+		}
+		for _, indexed := range ix.byLength[length] {
+			edits := matcher.distance(indexed)
+			if edits == 0 || edits > matcher.max || node.prefix && strings.HasPrefix(indexed.term, node.term) {
+				continue // the term itself, too far, or matched as a prefix
+			}
+			group = append(group, matchTerm{term: indexed.term, edits: edits})
+		}
+	}
+	return group
+}
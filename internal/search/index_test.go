@@ -0,0 +1,260 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+	"github.com/wetorrent/wetorrent/internal/chunk_storage"
+)
+
+// openTestIndex imports items into a new storage in a temporary directory
+// and opens its search index.
+func openTestIndex(tb testing.TB, items []catalog.Item) *Index {
+	tb.Helper()
+
+	storage, err := chunk_storage.New(tb.TempDir(), "w", chunk_storage.Options{InfoHashFunc: catalog.ChunkInfoHash})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { storage.Close() })
+
+	importer, err := catalog.NewImporter(storage)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i, item := range items {
+		if item.Magnet == "" {
+			item.Magnet = fmt.Sprintf("magnet:?xt=urn:btih:%040x", i+1)
+		}
+		if err := importer.Add(item); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	ix, err := Open(storage)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return ix
+}
+
+// benchItems is the size of the catalog the benchmarks search.
+const benchItems = 100000
+
+var benchWords = []string{
+	"ocean", "river", "night", "city", "dragon", "winter", "silent", "empire",
+	"shadow", "garden", "storm", "island", "machine", "king", "road", "star",
+}
+
+// benchCatalog returns n items named like releases, built from benchWords,
+// one in a thousand of them holding the word "sintel".
+func benchCatalog(n int) []catalog.Item {
+	items := make([]catalog.Item, n)
+	for i := range items {
+		title := benchWords[i%len(benchWords)] + "." + benchWords[i/len(benchWords)%len(benchWords)]
+		if i%1000 == 0 {
+			title = "Sintel." + title
+		}
+		items[i] = catalog.Item{
+			Name:        fmt.Sprintf("%s.%d.1080p.BluRay.x264-GRP%d", title, 1950+i%70, i%50),
+			Description: benchWords[i*7%len(benchWords)] + " " + benchWords[i*11%len(benchWords)],
+		}
+	}
+	return items
+}
+
+// benchQueries are single words, so that the substring match of the scan
+// finds what the index does.
+var benchQueries = []string{"sintel", "dragon", "winter"}
+
+// BenchmarkSearch times the search index; compare with BenchmarkScan.
+func BenchmarkSearch(b *testing.B) {
+	ix := openTestIndex(b, benchCatalog(benchItems))
+
+	for _, query := range benchQueries {
+		b.Run(query, func(b *testing.B) {
+			parsed, err := ParseQuery(query)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if len(ix.Search(parsed)) == 0 {
+					b.Fatal("no match")
+				}
+			}
+		})
+	}
+}
+
+// fuzzyCatalog returns n items named after made-up words of 4 to 11
+// letters, for a vocabulary as large as that of a real catalog, along
+// with the words of benchWords.
+func fuzzyCatalog(n int) []catalog.Item {
+	rng := rand.New(rand.NewSource(1))
+	word := func() string {
+		b := make([]byte, 4+rng.Intn(8))
+		for i := range b {
+			b[i] = byte('a' + rng.Intn(26))
+		}
+		return string(b)
+	}
+
+	items := make([]catalog.Item, n)
+	for i := range items {
+		items[i] = catalog.Item{
+			Name:        fmt.Sprintf("%s.%s.%s.%d.1080p", benchWords[i%len(benchWords)], word(), word(), 1950+i%70),
+			Description: word() + " " + word(),
+		}
+	}
+	return items
+}
+
+// BenchmarkFuzzy times queries with typos, which are compared to the
+// indexed terms of similar length.
+func BenchmarkFuzzy(b *testing.B) {
+	ix := openTestIndex(b, fuzzyCatalog(benchItems))
+	b.Logf("%d indexed terms", len(ix.terms))
+
+	for _, query := range []string{"dragon", "dragn", "wintre", "mahcine", "slient"} {
+		b.Run(query, func(b *testing.B) {
+			parsed, err := ParseQuery(query)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if len(ix.Search(parsed)) == 0 {
+					b.Fatal("no match")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkScan times the linear scan of the catalog that the search index
+// replaced, a substring match on the item names.
+func BenchmarkScan(b *testing.B) {
+	ix := openTestIndex(b, benchCatalog(benchItems))
+
+	for _, query := range benchQueries {
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var matches []int
+				it := ix.storage.Scan(0, -1, true)
+				for it.Next() {
+					item, err := catalog.DecodeItem(it.Bytes())
+					if err == nil && strings.Contains(strings.ToLower(item.Name), query) {
+						matches = append(matches, it.ID())
+					}
+				}
+				if it.Err() != nil {
+					b.Fatal(it.Err())
+				}
+				if len(matches) == 0 {
+					b.Fatal("no match")
+				}
+			}
+		})
+	}
+}
+
+// multilingualCatalog is a fixture of items named in several scripts and
+// normalization forms.
+var multilingualCatalog = []catalog.Item{
+	{Name: "Le.Fabuleux.Destin.d'Amélie.Poulain.2001.1080p.BluRay.x264-GRP", Description: "Comédie romantique"},
+	{Name: "Ame\u0301lie.2001.DVDRip.XviD", Description: "Décomposé"},
+	{Name: "Die.Straße.2019.German.1080p.WEB.h264"},
+	{Name: "Łódź.Øresund.Æble.2015.720p"},
+	{Name: "千と千尋の神隠し.Spirited.Away.2001.1080p.BluRay", Description: "宮崎駿"},
+	{Name: "Crouching.Tiger.Hidden.Dragon.卧虎藏龙.2000.1080p"},
+	{Name: "기생충.Parasite.2019.1080p.WEB-DL"},
+	{Name: "Щелкунчик.Nutcracker.1993.DVDRip"},
+	{Name: "ＡＫＩＲＡ.1988.ﬁlm.Remastered"},
+}
+
+func TestSearchMultilingual(t *testing.T) {
+	ix := openTestIndex(t, multilingualCatalog)
+
+	for _, test := range []struct {
+		query string
+		want  []int // chunk IDs, in any order
+	}{
+		// Accents fold away on both sides, whatever their normalization.
+		{"amelie", []int{0, 1}},
+		{"AMÉLIE", []int{0, 1}},
+		{"Ame\u0301lie", []int{0, 1}},
+		{"comedie", []int{0}},
+		{"decompose", []int{1}},
+		{"strasse", []int{2}},
+		{"straße", []int{2}},
+		{"lodz oresund aeble", []int{3}},
+
+		// Compatibility forms match their plain equivalents.
+		{"akira film", []int{8}},
+		{"ａｋｉｒａ", []int{8}},
+		{"ﬁlm", []int{8}},
+
+		// Runs of CJK letters are single terms, matched whole or, as the
+		// last word of the query, by prefix.
+		{"千と千尋の神隠し", []int{4}},
+		{"千と千尋", []int{4}},
+		{"卧虎藏龙 2000", []int{5}},
+		{"宮崎駿", []int{4}},
+		{"기생충", []int{6}},
+		{"щелкунчик", []int{7}},
+		{"ЩЕЛКУНЧИК 1993", []int{7}},
+
+		{"千尋", nil},
+		{"amelie 2019", nil},
+	} {
+		query, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%q: %v", test.query, err)
+		}
+		got := ix.Search(query)
+		sort.Ints(got)
+		if len(got) != len(test.want) || (len(got) > 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestSearchTypos(t *testing.T) {
+	ix := openTestIndex(t, []catalog.Item{
+		{Name: "Spirited.Away.2001"},
+		{Name: "The.Nutcracker.1993"},
+		{Name: "Dragon.Tales"},
+	})
+
+	for _, test := range []struct {
+		query string
+		want  []int // chunk IDs, in any order
+	}{
+		{"spirted away", []int{0}},   // one deletion
+		{"spiritted away", []int{0}}, // one insertion
+		{"sipirted away", []int{0}},  // a transposition and a deletion
+		{"nutcraker", []int{1}},
+		{"nuttcrakker", []int{1}}, // two insertions, a term two longer
+		{"ntcrcker", []int{1}},    // two deletions, a term two shorter
+		{"nutcrkr", nil},          // three deletions
+		{"dargon tales", []int{2}},
+		{"drgn tales", nil},         // two edits on a short term
+		{"spirited away 2011", nil}, // digits take no edits
+	} {
+		query, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%q: %v", test.query, err)
+		}
+		got := ix.Search(query)
+		sort.Ints(got)
+		if len(got) != len(test.want) || (len(got) > 0 && !reflect.DeepEqual(got, test.want)) {
+			t.Errorf("%q: got %v, want %v", test.query, got, test.want)
+		}
+	}
+}
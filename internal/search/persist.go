@@ -0,0 +1,282 @@
+package search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+)
+
+// The index is saved as a snapshot:
+//
+//	header:  magic "W64F" | version uint16 | reserved uint16 |
+//	         covered uint32 | fingerprint of chunk covered-1 uint32
+//	lengths: items uvarint | (name terms uvarint | description terms uvarint |
+//	         file terms uvarint)... for each covered chunk
+//	attrs:   (year | resolution | season | episode | codec | source |
+//	         languages | files | size, uvarints)... for each covered chunk
+//	terms:   count uvarint | (length uvarint | term | postings uvarint |
+//	         (id delta uvarint | name occurrences uvarint | description occurrences uvarint |
+//	         file occurrences uvarint)...)...
+//	footer:  crc32c uint32 of everything before it
+//
+// Compaction removes the snapshot. The fingerprint of the last covered
+// chunk catches the storage having been cut short and appended to by a
+// program that does not maintain the index, and stays the same when that
+// chunk is deleted.
+const (
+	snapshotMagic      = "W64F"
+	snapshotVersion    = 6
+	snapshotHeaderSize = 16
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var errBadSnapshot = errors.New("invalid search index snapshot")
+
+// load reads the snapshot into ix. The caller must have exclusive use of ix.
+func (ix *Index) load() error {
+	content, err := os.ReadFile(ix.path)
+	if err != nil {
+		return err
+	}
+
+	if len(content) < snapshotHeaderSize+4 || string(content[:4]) != snapshotMagic ||
+		binary.LittleEndian.Uint16(content[4:]) != snapshotVersion {
+		return errBadSnapshot
+	}
+	body, footer := content[:len(content)-4], content[len(content)-4:]
+	if crc32.Checksum(body, castagnoli) != binary.LittleEndian.Uint32(footer) {
+		return errBadSnapshot
+	}
+
+	covered := int(binary.LittleEndian.Uint32(content[8:]))
+	if covered > ix.storage.NumberOfChunks() {
+		return errBadSnapshot
+	}
+	if fingerprint, err := ix.fingerprint(covered); err != nil || fingerprint != binary.LittleEndian.Uint32(content[12:]) {
+		return errBadSnapshot
+	}
+
+	b := body[snapshotHeaderSize:]
+	var total fieldTotals
+	items, ok := readUvarint(&b)
+	if !ok || items > uint64(covered) {
+		return errBadSnapshot
+	}
+	total.items = int(items)
+
+	lengths := make([]fieldLengths, covered)
+	for i := range lengths {
+		name, ok1 := readUvarint(&b)
+		desc, ok2 := readUvarint(&b)
+		files, ok3 := readUvarint(&b)
+		if !ok1 || !ok2 || !ok3 || name > math.MaxUint16 || desc > math.MaxUint16 || files > math.MaxUint16 {
+			return errBadSnapshot
+		}
+		lengths[i] = fieldLengths{name: uint16(name), desc: uint16(desc), files: uint16(files)}
+		total.name += int64(name)
+		total.desc += int64(desc)
+		total.files += int64(files)
+		if files > 0 {
+			total.withFiles++
+		}
+	}
+
+	attrs := make([]itemAttributes, covered)
+	for i := range attrs {
+		var fields [9]uint64
+		for j := range fields {
+			if fields[j], ok = readUvarint(&b); !ok {
+				return errBadSnapshot
+			}
+		}
+		for _, field := range fields[:4] {
+			if field > math.MaxUint16 {
+				return errBadSnapshot
+			}
+		}
+		if fields[4] > math.MaxUint8 || fields[5] > math.MaxUint8 || fields[6] > math.MaxUint32 ||
+			fields[7] > math.MaxUint32 || fields[8] > math.MaxInt64 {
+			return errBadSnapshot
+		}
+		attrs[i] = itemAttributes{
+			year:       uint16(fields[0]),
+			resolution: uint16(fields[1]),
+			season:     uint16(fields[2]),
+			episode:    uint16(fields[3]),
+			codec:      uint8(fields[4]),
+			source:     uint8(fields[5]),
+			languages:  uint32(fields[6]),
+			files:      uint32(fields[7]),
+			size:       int64(fields[8]),
+		}
+	}
+
+	count, ok := readUvarint(&b)
+	if !ok {
+		return errBadSnapshot
+	}
+
+	postings := make(map[string][]posting, count)
+	for ; count > 0; count-- {
+		length, ok := readUvarint(&b)
+		if !ok || uint64(len(b)) < length {
+			return errBadSnapshot
+		}
+		term := string(b[:length])
+		b = b[length:]
+
+		n, ok := readUvarint(&b)
+		if !ok || n > uint64(len(b)) {
+			return errBadSnapshot
+		}
+		list := make([]posting, n)
+		var id uint64
+		for i := range list {
+			delta, ok1 := readUvarint(&b)
+			name, ok2 := readUvarint(&b)
+			desc, ok3 := readUvarint(&b)
+			files, ok4 := readUvarint(&b)
+			if !ok1 || !ok2 || !ok3 || !ok4 || name > math.MaxUint16 || desc > math.MaxUint16 || files > math.MaxUint16 {
+				return errBadSnapshot
+			}
+			id += delta
+			if id >= uint64(covered) {
+				return errBadSnapshot
+			}
+			list[i] = posting{id: uint32(id), name: uint16(name), desc: uint16(desc), files: uint16(files)}
+		}
+		postings[term] = list
+	}
+
+	ix.postings, ix.lengths, ix.attrs, ix.total = postings, lengths, attrs, total
+	ix.sortTerms()
+	return nil
+}
+
+// Save writes the index to disk if it changed since it was loaded or last
+// saved. The snapshot replaces the previous one atomically.
+func (ix *Index) Save() error {
+	ix.mu.Lock()
+	if !ix.dirty {
+		ix.mu.Unlock()
+		return nil
+	}
+	snapshot, err := ix.encode()
+	ix.dirty = err != nil
+	ix.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := ix.path + ".tmp"
+	err = writeSynced(tmp, snapshot)
+	if err == nil {
+		err = os.Rename(tmp, ix.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		ix.mu.Lock()
+		ix.dirty = true
+		ix.mu.Unlock()
+		return fmt.Errorf("writing search index: %v", err)
+	}
+	return nil
+}
+
+// encode serializes the index. The caller must hold mu.
+func (ix *Index) encode() ([]byte, error) {
+	covered := len(ix.lengths)
+	fingerprint, err := ix.fingerprint(covered)
+	if err != nil {
+		return nil, fmt.Errorf("saving search index: %v", err)
+	}
+
+	var buf bytes.Buffer
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic)
+	binary.LittleEndian.PutUint16(header[4:], snapshotVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(covered))
+	binary.LittleEndian.PutUint32(header[12:], fingerprint)
+	buf.Write(header)
+
+	writeUvarint(&buf, uint64(ix.total.items))
+	for _, lengths := range ix.lengths {
+		writeUvarint(&buf, uint64(lengths.name))
+		writeUvarint(&buf, uint64(lengths.desc))
+		writeUvarint(&buf, uint64(lengths.files))
+	}
+
+	for _, attrs := range ix.attrs {
+		for _, field := range []uint64{uint64(attrs.year), uint64(attrs.resolution), uint64(attrs.season),
+			uint64(attrs.episode), uint64(attrs.codec), uint64(attrs.source), uint64(attrs.languages),
+			uint64(attrs.files), uint64(attrs.size)} {
+			writeUvarint(&buf, field)
+		}
+	}
+
+	writeUvarint(&buf, uint64(len(ix.terms)))
+	for _, term := range ix.terms {
+		writeUvarint(&buf, uint64(len(term)))
+		buf.WriteString(term)
+
+		list := ix.postings[term]
+		writeUvarint(&buf, uint64(len(list)))
+		var previous uint32
+		for _, p := range list {
+			writeUvarint(&buf, uint64(p.id-previous))
+			writeUvarint(&buf, uint64(p.name))
+			writeUvarint(&buf, uint64(p.desc))
+			writeUvarint(&buf, uint64(p.files))
+			previous = p.id
+		}
+	}
+
+	footer := make([]byte, 4)
+	binary.LittleEndian.PutUint32(footer, crc32.Checksum(buf.Bytes(), castagnoli))
+	buf.Write(footer)
+	return buf.Bytes(), nil
+}
+
+// fingerprint returns the fingerprint of chunk covered-1, 0 for an empty
+// index.
+func (ix *Index) fingerprint(covered int) (uint32, error) {
+	if covered == 0 {
+		return 0, nil
+	}
+	return ix.storage.ChunkFingerprint(covered - 1)
+}
+
+func readUvarint(b *[]byte) (uint64, bool) {
+	v, n := binary.Uvarint(*b)
+	if n <= 0 {
+		return 0, false
+	}
+	*b = (*b)[n:]
+	return v, true
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func writeSynced(filepath string, content []byte) error {
+	f, err := os.Create(filepath)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
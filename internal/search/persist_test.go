@@ -0,0 +1,41 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// TestSnapshotAfterDeletion deletes the last chunk the snapshot covers,
+// which must leave the snapshot valid.
+func TestSnapshotAfterDeletion(t *testing.T) {
+	ix := openTestIndex(t, []catalog.Item{
+		{Name: "Spirited.Away.2001"},
+		{Name: "The.Nutcracker.1993"},
+		{Name: "Dragon.Tales"},
+	})
+	if err := ix.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.storage.DeleteChunk(2); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := &Index{storage: ix.storage, path: ix.path, postings: make(map[string][]posting)}
+	if err := reopened.load(); err != nil {
+		t.Fatalf("loading the snapshot: %v", err)
+	}
+	if n, err := reopened.Update(); err != nil || n != 0 {
+		t.Errorf("Update went through %d chunks (%v), want none", n, err)
+	}
+
+	for query, want := range map[string]int{"spirited": 1, "dragon": 0} {
+		q, err := ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results := reopened.Rank(q); len(results) != want {
+			t.Errorf("%q: %d results, want %d", query, len(results), want)
+		}
+	}
+}
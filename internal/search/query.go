@@ -0,0 +1,417 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed search query, as returned by ParseQuery.
+type Query struct {
+	root   queryNode // nil if the query has nothing to look for
+	fields fieldSet  // where terms and phrases are looked for
+
+	// The terms the query looks for outside of negations and alternatives,
+	// in order, and whether the last of them matches as a prefix. Ranking
+	// rewards items holding them as a title prefix or a phrase.
+	terms  []string
+	prefix bool
+}
+
+// fieldSet is a set of the fields of items.
+type fieldSet uint8
+
+const (
+	nameField fieldSet = 1 << iota
+	descField
+	filesField
+
+	allFields = nameField | descField | filesField
+)
+
+var fieldNames = map[string]fieldSet{
+	"name":        nameField,
+	"desc":        descField,
+	"description": descField,
+	"files":       filesField,
+	"file":        filesField,
+}
+
+// queryNode is one of *termNode, *phraseNode, *filterNode, *allNode,
+// *anyNode and *notNode.
+type queryNode interface{}
+
+// termNode matches items holding term in one of the fields of the query.
+type termNode struct {
+	term   string
+	prefix bool // also match the terms term is a prefix of
+	exact  bool // do not match terms with typos
+}
+
+// phraseNode matches items holding terms in a row in one of the fields of
+// the query, or in a single file path.
+type phraseNode struct {
+	terms []string
+}
+
+// allNode matches items matched by all of its nodes, anyNode by any.
+type allNode struct {
+	nodes []queryNode
+}
+
+type anyNode struct {
+	nodes []queryNode
+}
+
+// notNode matches items not matched by node.
+type notNode struct {
+	node queryNode
+}
+
+// QueryError reports a malformed query.
+type QueryError struct {
+	Offset int // in bytes from the start of the query
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("character %d: %s", e.Offset+1, e.Msg)
+}
+
+// ParseQuery parses a search query. A query is a list of words which items
+// must all match, in their name, description or file paths:
+//
+//	matrix          items holding the term, or one a typo or two away
+//	"the matrix"    items holding the terms in a row
+//	-cam            items not matching the word
+//	dvd OR bluray   items matching either word
+//	(a OR b) -c     grouping
+//	year:1999       items passing a filter on one of their attributes
+//	in:files        look for terms in file paths only
+//
+// The in: qualifier takes name, desc or files, or several of them as in
+// in:name,desc, and applies to every term and phrase of the query. File
+// paths are only known for the torrents added with Index.AddFiles.
+//
+// Filters apply to year, res (resolution), codec, source, lang, season,
+// episode (or ep), size, files (the file count) and group, as in
+// year>=1990, res:1080p, codec:x265, source:bluray, lang:french, season:2,
+// size<4GB, files:1 or group:GRP; see filterNode. The last word of a query
+// also matches the terms it is a prefix of, so that results follow the
+// user as they type. Punctuation within words separates terms, as in
+// The.Matrix.1999.
+//
+// ParseQuery returns a *QueryError if the query is malformed.
+func ParseQuery(query string) (*Query, error) {
+	p := &queryParser{query: query}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+
+	root, err := p.parseSequence()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		return nil, &QueryError{p.tokens[p.next].offset, "unexpected ')'"}
+	}
+
+	fields := p.fields
+	if fields == 0 {
+		fields = allFields
+	}
+	return &Query{root: root, fields: fields, terms: p.terms, prefix: p.prefix}, nil
+}
+
+type queryTokenKind int
+
+const (
+	wordToken queryTokenKind = iota
+	phraseToken
+	orToken
+	minusToken
+	openToken
+	closeToken
+)
+
+type queryToken struct {
+	kind   queryTokenKind
+	text   string
+	offset int
+}
+
+type queryParser struct {
+	query  string
+	tokens []queryToken
+	next   int
+
+	negated      int // depth of negations around the token being parsed
+	alternatives int // depth of OR groups around it
+
+	terms  []string
+	prefix bool
+	fields fieldSet // given by in: qualifiers
+}
+
+// lex splits the query into tokens.
+func (p *queryParser) lex() error {
+	query := p.query
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == '(':
+			p.tokens = append(p.tokens, queryToken{openToken, "(", i})
+			i++
+
+		case r == ')':
+			p.tokens = append(p.tokens, queryToken{closeToken, ")", i})
+			i++
+
+		case r == '"':
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return &QueryError{i, "missing closing quote"}
+			}
+			p.tokens = append(p.tokens, queryToken{phraseToken, query[i+1 : i+1+end], i})
+			i += end + 2
+
+		// A dash excludes what follows it, unless it stands alone.
+		case r == '-' && i+1 < len(query) && !strings.ContainsAny(query[i+1:i+2], " \t\r\n)"):
+			p.tokens = append(p.tokens, queryToken{minusToken, "-", i})
+			i++
+
+		default:
+			end := strings.IndexFunc(query[i:], func(r rune) bool {
+				return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+			})
+			if end < 0 {
+				end = len(query) - i
+			}
+			word := query[i : i+end]
+			if word == "OR" {
+				p.tokens = append(p.tokens, queryToken{orToken, word, i})
+			} else {
+				p.tokens = append(p.tokens, queryToken{wordToken, word, i})
+			}
+			i += end
+		}
+	}
+	return nil
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.next == len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+// parseSequence parses clauses up to the end of the query or a closing
+// parenthesis, which it leaves for the caller.
+func (p *queryParser) parseSequence() (queryNode, error) {
+	var nodes []queryNode
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind == closeToken {
+			break
+		}
+		node, err := p.parseAlternatives()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	} else if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &allNode{nodes}, nil
+}
+
+// parseAlternatives parses clauses separated by OR.
+func (p *queryParser) parseAlternatives() (queryNode, error) {
+	if token, _ := p.peek(); token.kind == orToken {
+		return nil, &QueryError{token.offset, "missing search terms before OR"}
+	}
+
+	terms, prefix := len(p.terms), p.prefix
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if token, ok := p.peek(); !ok || token.kind != orToken {
+		return node, nil
+	}
+
+	// The terms of the first alternative turn out not to be required.
+	p.terms, p.prefix = p.terms[:terms], prefix
+	p.alternatives++
+	defer func() { p.alternatives-- }()
+
+	var nodes []queryNode
+	if node != nil {
+		nodes = append(nodes, node)
+	}
+	for {
+		token, ok := p.peek()
+		if !ok || token.kind != orToken {
+			break
+		}
+		p.next++
+		if next, ok := p.peek(); !ok || next.kind == orToken || next.kind == closeToken {
+			return nil, &QueryError{token.offset, "missing search terms after OR"}
+		}
+
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			nodes = append(nodes, node)
+		}
+	}
+
+	if len(nodes) == 0 {
+		return nil, nil
+	} else if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &anyNode{nodes}, nil
+}
+
+// parseUnary parses a clause, possibly negated.
+func (p *queryParser) parseUnary() (queryNode, error) {
+	token, ok := p.peek()
+	if !ok || token.kind != minusToken {
+		return p.parsePrimary()
+	}
+	p.next++
+
+	p.negated++
+	node, err := p.parseUnary()
+	p.negated--
+	if err != nil || node == nil {
+		return nil, err
+	}
+	return &notNode{node}, nil
+}
+
+// parsePrimary parses a word, a phrase, a filter or a group in
+// parentheses. Words without any term, such as "&", give a nil node.
+func (p *queryParser) parsePrimary() (queryNode, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, &QueryError{len(p.query), "unexpected end of query"}
+	}
+	p.next++
+
+	switch token.kind {
+	case openToken:
+		node, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != closeToken {
+			return nil, &QueryError{token.offset, "missing ')'"}
+		}
+		p.next++
+		if node == nil {
+			return nil, &QueryError{token.offset, "nothing to search for in parentheses"}
+		}
+		return node, nil
+
+	case closeToken:
+		return nil, &QueryError{token.offset, "unexpected ')'"}
+
+	case orToken:
+		return nil, &QueryError{token.offset, "missing search terms before OR"}
+
+	case phraseToken:
+		terms := Tokenize(token.text)
+		p.addTerms(terms)
+		switch len(terms) {
+		case 0:
+			return nil, &QueryError{token.offset, "nothing to search for in quotes"}
+		case 1:
+			return &termNode{term: terms[0], exact: true}, nil
+		}
+		return &phraseNode{terms}, nil
+	}
+
+	if fields, ok, err := parseScope(token.text); err != nil {
+		return nil, &QueryError{token.offset, err.Error()}
+	} else if ok {
+		if p.negated > 0 || p.alternatives > 0 {
+			return nil, &QueryError{token.offset, "in: applies to the whole query"}
+		}
+		p.fields |= fields
+		return nil, nil
+	}
+
+	if filter, ok, err := parseFilter(token.text); err != nil {
+		return nil, &QueryError{token.offset, err.Error()}
+	} else if ok {
+		return filter, nil
+	}
+
+	terms := Tokenize(token.text)
+	nodes := make([]queryNode, len(terms))
+	for i, term := range terms {
+		nodes[i] = &termNode{term: term}
+	}
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// The last word of the query is likely still being typed.
+	last := nodes[len(nodes)-1].(*termNode)
+	last.prefix = p.next == len(p.tokens) && p.negated == 0
+	p.addTerms(terms)
+	if p.negated == 0 && p.alternatives == 0 {
+		p.prefix = last.prefix
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &allNode{nodes}, nil
+}
+
+// parseScope parses word as an in: qualifier, reporting false if it is not
+// one.
+func parseScope(word string) (fieldSet, bool, error) {
+	if len(word) < 3 || !strings.EqualFold(word[:3], "in:") {
+		return 0, false, nil
+	}
+	if word[3:] == "" {
+		return 0, false, fmt.Errorf("in: missing field")
+	}
+
+	var fields fieldSet
+	for _, name := range strings.Split(word[3:], ",") {
+		field, ok := fieldNames[strings.ToLower(name)]
+		if !ok {
+			return 0, false, fmt.Errorf("in: unknown field '%s', expected name, desc or files", name)
+		}
+		fields |= field
+	}
+	return fields, true, nil
+}
+
+// addTerms records terms the query looks for, unless they are negated or
+// part of alternatives.
+func (p *queryParser) addTerms(terms []string) {
+	if p.negated == 0 && p.alternatives == 0 {
+		p.terms = append(p.terms, terms...)
+		p.prefix = false
+	}
+}
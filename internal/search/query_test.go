@@ -0,0 +1,208 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/release"
+)
+
+// formatNode writes a query tree compactly: terms as they are, with '*'
+// when they match as a prefix and in quotes when they must match exactly,
+// phrases in quotes, and groups in parentheses.
+func formatNode(node queryNode) string {
+	join := func(nodes []queryNode, sep string) string {
+		parts := make([]string, len(nodes))
+		for i, node := range nodes {
+			parts[i] = formatNode(node)
+		}
+		return "(" + strings.Join(parts, sep) + ")"
+	}
+
+	switch node := node.(type) {
+	case nil:
+		return ""
+	case *termNode:
+		switch {
+		case node.exact:
+			return `"` + node.term + `"`
+		case node.prefix:
+			return node.term + "*"
+		}
+		return node.term
+	case *phraseNode:
+		return `"` + strings.Join(node.terms, " ") + `"`
+	case *allNode:
+		return join(node.nodes, " ")
+	case *anyNode:
+		return join(node.nodes, " OR ")
+	case *notNode:
+		return "-" + formatNode(node.node)
+	case *filterNode:
+		name := []string{"year", "res", "codec", "source", "lang", "season", "episode", "size", "files", "group"}[node.field]
+		value := fmt.Sprint(node.value)
+		switch node.field {
+		case codecFilter:
+			value = release.VideoCodecs[node.value-1]
+		case sourceFilter:
+			value = release.Sources[node.value-1]
+		case languageFilter:
+			value = release.Languages[bits.TrailingZeros64(uint64(node.value))]
+		case groupFilter:
+			value = node.group
+		}
+		return "{" + name + node.op + value + "}"
+	}
+	return fmt.Sprintf("%T", node)
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, test := range []struct {
+		query  string
+		tree   string
+		terms  []string
+		prefix bool
+		fields fieldSet // allFields if 0
+	}{
+		// Words must all match; the last one also matches as a prefix.
+		{"matrix", "matrix*", []string{"matrix"}, true, 0},
+		{"the matrix", "(the matrix*)", []string{"the", "matrix"}, true, 0},
+		{"The.Matrix.1999", "(the matrix 1999*)", []string{"the", "matrix", "1999"}, true, 0},
+		{"& matrix", "matrix*", []string{"matrix"}, true, 0},
+		{"", "", nil, false, 0},
+
+		// Phrases match exactly and never as a prefix.
+		{`"the matrix"`, `"the matrix"`, []string{"the", "matrix"}, false, 0},
+		{`"matrix"`, `"matrix"`, []string{"matrix"}, false, 0},
+		{`"the matrix" 1999`, `("the matrix" 1999*)`, []string{"the", "matrix", "1999"}, true, 0},
+		{`matrix "1999"`, `(matrix "1999")`, []string{"matrix", "1999"}, false, 0},
+
+		// Negations are neither required terms nor prefixes.
+		{"-cam matrix", "(-cam matrix*)", []string{"matrix"}, true, 0},
+		{"matrix -cam", "(matrix -cam)", []string{"matrix"}, false, 0},
+		{`matrix -"behind the scenes"`, `(matrix -"behind the scenes")`, []string{"matrix"}, false, 0},
+		{"matrix --cam", "(matrix --cam)", []string{"matrix"}, false, 0},
+		{"a - b", "(a b*)", []string{"a", "b"}, true, 0},
+
+		// OR binds tighter than the implicit AND, and its terms are not
+		// required.
+		{"a OR b", "(a OR b*)", nil, false, 0},
+		{"a b OR c d", "(a (b OR c) d*)", []string{"a", "d"}, true, 0},
+		{"a OR b c", "((a OR b) c*)", []string{"c"}, true, 0},
+		{"a OR b OR c", "(a OR b OR c*)", nil, false, 0},
+		{"(a OR b) -c", "((a OR b) -c)", nil, false, 0},
+		{"-(a OR b) c", "(-(a OR b) c*)", []string{"c"}, true, 0},
+		{"(a b) OR c", "((a b) OR c*)", nil, false, 0},
+		{"a or b", "(a or b*)", []string{"a", "or", "b"}, true, 0},
+
+		// in: applies to the whole query.
+		{"in:name matrix", "matrix*", []string{"matrix"}, true, nameField},
+		{"matrix IN:desc", "matrix", []string{"matrix"}, false, descField},
+		{"in:name,files matrix", "matrix*", []string{"matrix"}, true, nameField | filesField},
+		{"in:name in:description matrix", "matrix*", []string{"matrix"}, true, nameField | descField},
+
+		// Filters, on every attribute.
+		{"year:1999", "{year=1999}", nil, false, 0},
+		{"YEAR>=1990 matrix", "({year>=1990} matrix*)", []string{"matrix"}, true, 0},
+		{"year:<2000", "{year<2000}", nil, false, 0},
+		{"res:1080p", "{res=1080}", nil, false, 0},
+		{"resolution>=4k", "{res>=2160}", nil, false, 0},
+		{"res<720", "{res<720}", nil, false, 0},
+		{"codec:h264", "{codec=H.264}", nil, false, 0},
+		{"codec:x265", "{codec=H.265}", nil, false, 0},
+		{"source:bluray", "{source=BluRay}", nil, false, 0},
+		{"source:web-dl", "{source=WEB-DL}", nil, false, 0},
+		{"lang:french", "{lang=French}", nil, false, 0},
+		{"season:2", "{season=2}", nil, false, 0},
+		{"episode>10", "{episode>10}", nil, false, 0},
+		{"ep:5", "{episode=5}", nil, false, 0},
+		{"size<4GB", "{size<4294967296}", nil, false, 0},
+		{"size>=1.5g", "{size>=1610612736}", nil, false, 0},
+		{"size:700mb", "{size=734003200}", nil, false, 0},
+		{"files:1", "{files=1}", nil, false, 0},
+		{"group:GRP", "{group=GRP}", nil, false, 0},
+		{"-source:cam year:2020", "(-{source=CAM} {year=2020})", nil, false, 0},
+		{"lang:french OR lang:german", "({lang=French} OR {lang=German})", nil, false, 0},
+
+		// Unknown attributes are words.
+		{"genre:drama", "(genre drama*)", []string{"genre", "drama"}, true, 0},
+	} {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+		fields := test.fields
+		if fields == 0 {
+			fields = allFields
+		}
+		if tree := formatNode(q.root); tree != test.tree {
+			t.Errorf("%q: parsed as %s, want %s", test.query, tree, test.tree)
+		}
+		sameTerms := len(q.terms) == 0 && len(test.terms) == 0 || reflect.DeepEqual(q.terms, test.terms)
+		if !sameTerms || q.prefix != test.prefix {
+			t.Errorf("%q: terms %q, prefix %v, want %q, %v", test.query, q.terms, q.prefix, test.terms, test.prefix)
+		}
+		if q.fields != fields {
+			t.Errorf("%q: fields %b, want %b", test.query, q.fields, fields)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, test := range []struct {
+		query  string
+		offset int
+		msg    string // part of the message
+	}{
+		{`"the matrix`, 0, "missing closing quote"},
+		{`matrix "the`, 7, "missing closing quote"},
+		{`""`, 0, "nothing to search for in quotes"},
+		{`a "&"`, 2, "nothing to search for in quotes"},
+		{"OR matrix", 0, "before OR"},
+		{"matrix OR", 7, "after OR"},
+		{"a OR OR b", 2, "after OR"},
+		{"(a OR) b", 3, "after OR"},
+		{"(matrix", 0, "missing ')'"},
+		{"a (b (c)", 2, "missing ')'"},
+		{"matrix)", 6, "unexpected ')'"},
+		{"a ) b", 2, "unexpected ')'"},
+		{"()", 0, "nothing to search for in parentheses"},
+		{"a (&)", 2, "nothing to search for in parentheses"},
+		{"in:", 0, "missing field"},
+		{"matrix in:title", 7, "unknown field 'title'"},
+		{"-in:name matrix", 1, "applies to the whole query"},
+		{"a OR in:name", 5, "applies to the whole query"},
+		{"year:", 0, "missing value"},
+		{"matrix year:abc", 7, "not a year"},
+		{"year:99", 0, "not a year"},
+		{"res:huge", 0, "not a resolution"},
+		{"codec:foo", 0, "unknown codec"},
+		{"codec>x264", 0, "only ':' applies"},
+		{"source:vinyl", 0, "unknown source"},
+		{"lang:klingon", 0, "unknown language"},
+		{"group>GRP", 0, "only ':' applies"},
+		{"season:-1", 0, "not a number"},
+		{"ep:two", 0, "not a number"},
+		{"files:many", 0, "not a number"},
+		{"size:4XB", 0, "not a size"},
+		{"size<-1GB", 0, "not a size"},
+	} {
+		_, err := ParseQuery(test.query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%q: got %v, want a *QueryError", test.query, err)
+			continue
+		}
+		if queryErr.Offset != test.offset || !strings.Contains(queryErr.Msg, test.msg) {
+			t.Errorf("%q: error at %d: %s, want at %d: %s", test.query, queryErr.Offset, queryErr.Msg, test.offset, test.msg)
+		}
+		if want := fmt.Sprintf("character %d: ", test.offset+1); !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%q: error %q does not start with %q", test.query, err, want)
+		}
+	}
+}
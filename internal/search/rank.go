@@ -0,0 +1,231 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// Result is a chunk matching a query along with its relevance.
+type Result struct {
+	ID    int
+	Score float64
+}
+
+// Ranking parameters. Terms are scored with BM25F: each field's term
+// frequency is normalized by the field length, the name weighs more than
+// the description, which weighs more than file paths, and the weighted sum
+// saturates through k1.
+const (
+	k1          = 1.2
+	nameB       = 0.75
+	descB       = 0.75
+	filesB      = 0.75
+	nameWeight  = 3.0
+	descWeight  = 1.0
+	filesWeight = 0.5
+
+	// The best rerankDepth results are read back to reward items whose name
+	// starts with the query, and items holding the query terms in order.
+	rerankDepth      = 100
+	titlePrefixBoost = 1.5
+	phraseBoost      = 1.25
+)
+
+// Rank returns the chunks whose item matches q, best first. Equal scores
+// put the newest chunk first. Deleted chunks are left out.
+func (ix *Index) Rank(q *Query) []Result {
+	if q.root == nil {
+		return nil
+	}
+
+	ix.mu.RLock()
+	e := &evaluator{ix: ix, fields: q.fields, lists: make(map[queryNode][]Result)}
+	candidates, all, exact := e.candidates(q.root)
+	if all {
+		candidates = e.universe()
+	}
+	ix.mu.RUnlock()
+
+	var doc document
+	results := make([]Result, 0, len(candidates))
+	for _, result := range candidates {
+		if ix.storage.IsDeleted(result.ID) {
+			continue
+		}
+		if !exact {
+			doc.reset()
+			if !e.match(q.root, result.ID, &doc) {
+				continue
+			}
+		}
+		results = append(results, result)
+	}
+
+	sortResults(results)
+	top := results
+	if len(top) > rerankDepth {
+		top = top[:rerankDepth]
+	}
+	ix.boost(top, q.terms, q.prefix)
+	sortResults(top)
+
+	return results
+}
+
+// groupScores returns the score of a match group for every chunk holding
+// one of its terms in fields, by increasing chunk ID. A chunk matching
+// several terms of a group scores as its best one. The caller must hold mu.
+func (ix *Index) groupScores(group matchGroup, fields fieldSet) []Result {
+	if len(group) == 1 && group[0].edits == 0 {
+		postings := ix.postings[group[0].term]
+		scores := make([]Result, 0, len(postings))
+		for _, p := range postings {
+			if p.in(fields) {
+				scores = append(scores, Result{ID: int(p.id), Score: ix.termScore(len(postings), p, fields)})
+			}
+		}
+		return scores
+	}
+
+	best := make(map[int]float64)
+	for _, term := range group {
+		penalty := math.Pow(fuzzyPenalty, float64(term.edits))
+		postings := ix.postings[term.term]
+		for _, p := range postings {
+			if !p.in(fields) {
+				continue
+			}
+			if score := penalty * ix.termScore(len(postings), p, fields); score > best[int(p.id)] {
+				best[int(p.id)] = score
+			}
+		}
+	}
+
+	scores := make([]Result, 0, len(best))
+	for id, score := range best {
+		scores = append(scores, Result{ID: id, Score: score})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].ID < scores[j].ID })
+	return scores
+}
+
+// termScore is the BM25F score over fields of a term found in df items,
+// for one of them. The caller must hold mu.
+func (ix *Index) termScore(df int, p posting, fields fieldSet) float64 {
+	n := float64(ix.total.items)
+	idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+
+	lengths := ix.lengths[p.id]
+	var tf float64
+	if fields&nameField != 0 {
+		tf += nameWeight * float64(p.name) / normalization(nameB, lengths.name, ix.total.name, ix.total.items)
+	}
+	if fields&descField != 0 {
+		tf += descWeight * float64(p.desc) / normalization(descB, lengths.desc, ix.total.desc, ix.total.items)
+	}
+	if fields&filesField != 0 {
+		tf += filesWeight * float64(p.files) / normalization(filesB, lengths.files, ix.total.files, ix.total.withFiles)
+	}
+
+	return idf * tf * (k1 + 1) / (tf + k1)
+}
+
+// in reports whether the term of p occurs in one of fields.
+func (p posting) in(fields fieldSet) bool {
+	return fields&nameField != 0 && p.name > 0 ||
+		fields&descField != 0 && p.desc > 0 ||
+		fields&filesField != 0 && p.files > 0
+}
+
+func normalization(b float64, length uint16, total int64, items int) float64 {
+	if total == 0 || items == 0 {
+		return 1
+	}
+	average := float64(total) / float64(items)
+	return 1 - b + b*float64(length)/average
+}
+
+// intersectScores returns the chunks present in both lists, by increasing
+// ID, with their scores added.
+func intersectScores(a, b []Result) []Result {
+	var results []Result
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].ID < b[j].ID:
+			i++
+		case a[i].ID > b[j].ID:
+			j++
+		default:
+			results = append(results, Result{ID: a[i].ID, Score: a[i].Score + b[j].Score})
+			i++
+			j++
+		}
+	}
+	return results
+}
+
+func sortResults(results []Result) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID > results[j].ID
+	})
+}
+
+// boost rewards results whose name starts with the terms of the query,
+// and, for queries of several terms, those holding the terms in order in
+// their name or description. Items that cannot be read keep their score.
+func (ix *Index) boost(results []Result, terms []string, prefix bool) {
+	if len(terms) == 0 {
+		return
+	}
+
+	for i := range results {
+		chunk, err := ix.storage.GetChunkById(results[i].ID)
+		if err != nil {
+			continue
+		}
+		item, err := catalog.DecodeItem(chunk)
+		if err != nil {
+			continue
+		}
+
+		nameTerms := Tokenize(item.Name)
+		if containsPhrase(nameTerms[:min(len(nameTerms), len(terms))], terms, prefix) {
+			results[i].Score *= titlePrefixBoost
+		}
+		if len(terms) > 1 && (containsPhrase(nameTerms, terms, prefix) || containsPhrase(Tokenize(item.Description), terms, prefix)) {
+			results[i].Score *= phraseBoost
+		}
+	}
+}
+
+// containsPhrase reports whether phrase appears in terms as a contiguous
+// run, its last term matching as a prefix if prefix is set.
+func containsPhrase(terms, phrase []string, prefix bool) bool {
+	last := len(phrase) - 1
+	for start := 0; start+len(phrase) <= len(terms); start++ {
+		match := true
+		for i, term := range phrase {
+			if terms[start+i] != term && !(prefix && i == last && strings.HasPrefix(terms[start+i], term)) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
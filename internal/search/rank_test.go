@@ -0,0 +1,70 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/wetorrent/wetorrent/internal/catalog"
+)
+
+// TestRankBM25F checks the order BM25F puts two items in. The item
+// expected first always has the lower ID, so that ties, which put the
+// newest chunk first, fail the test.
+func TestRankBM25F(t *testing.T) {
+	// harbors are items holding "harbor" that the queries do not match
+	// otherwise, to make it a common term.
+	var harbors []catalog.Item
+	for i := 0; i < 30; i++ {
+		harbors = append(harbors, catalog.Item{Name: fmt.Sprintf("Harbor Lights %d", i)})
+	}
+
+	for _, test := range []struct {
+		name  string
+		query string
+		items []catalog.Item // the first should rank above the second
+	}{
+		{"name hit beats description hit", "dragon", []catalog.Item{
+			{Name: "Old Dragon Tales", Description: "a film"},
+			{Name: "Old Tales", Description: "a dragon film"},
+		}},
+		{"name hit beats repeated description hits", "dragon", []catalog.Item{
+			{Name: "Old Dragon Tales", Description: "a film"},
+			{Name: "Old Tales", Description: "dragon film about a dragon"},
+		}},
+		{"shorter name wins at equal frequency", "dragon", []catalog.Item{
+			{Name: "The Dragon King"},
+			{Name: "The Dragon King of the Long Northern Mountain Range"},
+		}},
+		{"shorter description wins at equal frequency", "dragon", []catalog.Item{
+			{Name: "Old Tales", Description: "a dragon film"},
+			{Name: "Old Tales", Description: "a dragon film shot over three long winters in the far north"},
+		}},
+		{"rare term beats common term", "harbor OR dragon", append([]catalog.Item{
+			{Name: "Blue Dragon"},
+			{Name: "Blue Harbor"},
+		}, harbors...)},
+		{"rare term beats repeated common term", "harbor OR dragon", append([]catalog.Item{
+			{Name: "Blue Dragon"},
+			{Name: "Harbor to Harbor", Description: "harbor"},
+		}, harbors...)},
+	} {
+		ix := openTestIndex(t, test.items)
+		query, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		scores := make(map[int]float64)
+		for _, result := range ix.Rank(query) {
+			scores[result.ID] = result.Score
+		}
+		first, firstOK := scores[0]
+		second, secondOK := scores[1]
+		if !firstOK || !secondOK {
+			t.Errorf("%s: %q matched %v, want items 0 and 1", test.name, test.query, scores)
+		} else if first <= second {
+			t.Errorf("%s: %q scored %q %.3f, not above %q %.3f", test.name, test.query,
+				test.items[0].Name, first, test.items[1].Name, second)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Tokenize splits s into terms at every character that is not a letter or a
+// digit, so that release style names such as "The.Matrix.1999.1080p" yield
+// "the", "matrix", "1999" and "1080p". Terms are folded as by fold, so that
+// "Amélie" and "amelie" give the same term.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// foldings spells out the lower case letters that carry a diacritic or are
+// ligatures without decomposing into a base letter.
+var foldings = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'đ': "d",
+	'ð': "d",
+	'ħ': "h",
+	'ı': "i",
+	'ł': "l",
+	'þ': "th",
+}
+
+// fold lower cases s and reduces it to its compatibility decomposition
+// without diacritics: "Ａmélie" becomes "amelie", "ﬁlm" "film" and "Straße"
+// "strasse".
+func fold(s string) string {
+	ascii := true
+	for i := 0; i < len(s) && ascii; i++ {
+		ascii = s[i] < utf8.RuneSelf
+	}
+	if ascii {
+		return strings.ToLower(s)
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if folded, ok := foldings[r]; ok && r >= utf8.RuneSelf {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestTokenize(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want []string
+	}{
+		{"The.Matrix.1999.1080p.BluRay.x264-GRP", []string{"the", "matrix", "1999", "1080p", "bluray", "x264", "grp"}},
+
+		// Diacritics are dropped, whatever the normalization form of the
+		// input.
+		{"Le.Fabuleux.Destin.d'Amélie.Poulain", []string{"le", "fabuleux", "destin", "d", "amelie", "poulain"}},
+		{"Ame\u0301lie", []string{"amelie"}},
+		{"Señor Citroën Ça Öl", []string{"senor", "citroen", "ca", "ol"}},
+		{"Ὀδύσσεια", []string{"οδυσσεια"}},
+		{"Щелкунчик.Nutcracker", []string{"щелкунчик", "nutcracker"}},
+
+		// Compatibility forms fold to their plain equivalents.
+		{"Ｆｕｌｌｗｉｄｔｈ ﬁlm Ⅻ ²", []string{"fullwidth", "film", "xii", "2"}},
+
+		// Letters without a decomposition are spelled out.
+		{"Straße Łódź Øresund Ægir Œuvre Þór", []string{"strasse", "lodz", "oresund", "aegir", "oeuvre", "thor"}},
+
+		// Scripts without spaces between words give one term per run of
+		// letters.
+		{"千と千尋の神隠し.Spirited.Away.2001", []string{"千と千尋の神隠し", "spirited", "away", "2001"}},
+		{"Crouching.Tiger.Hidden.Dragon.卧虎藏龙.2000", []string{"crouching", "tiger", "hidden", "dragon", "卧虎藏龙", "2000"}},
+		{"ＡＫＩＲＡ．アキラ", []string{"akira", "アキラ"}},
+
+		{"", nil},
+		{"...---", nil},
+	} {
+		got := Tokenize(test.s)
+		if len(got) == 0 && len(test.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestFoldHangul(t *testing.T) {
+	// Hangul syllables decompose into their jamo, the same way for the
+	// names and the queries.
+	if fold("기생충") != fold(norm.NFD.String("기생충")) {
+		t.Error("precomposed and decomposed Hangul fold differently")
+	}
+	if got := Tokenize("기생충.Parasite"); len(got) != 2 || got[1] != "parasite" {
+		t.Errorf("got %q", got)
+	}
+}